@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +22,8 @@ func main() {
 	database.Connect()
 	database.AutoMigrate()
 	database.SeedDefaultUsers()
+	database.SeedDefaultRoles()
+	database.SeedDefaultWarehouses()
 	minioClient.InitMinio()
 
 	// Setup Gin
@@ -43,9 +46,9 @@ func main() {
 	log.Printf("[CORS] Allowed origins: %v", allowedOrigins)
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "X-API-Key", "X-Warehouse"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "ETag"},
 		AllowCredentials: true,
 	}))
 
@@ -60,41 +63,105 @@ func main() {
 	// Rate limiter for login: 5 attempts per minute per IP
 	loginLimiter := middleware.NewLoginRateLimiter(5, 1*time.Minute)
 	api.POST("/auth/login", loginLimiter.Middleware(), handlers.Login)
+	api.POST("/auth/login/2fa", loginLimiter.Middleware(), handlers.LoginTwoFactor)
+	api.POST("/auth/refresh", handlers.RefreshSession)
+	api.POST("/auth/logout", handlers.Logout)
 
 	// Public clock routes (no auth needed for clock in/out kiosk)
 	// NOTE: IP restriction temporarily disabled — re-enable clockGuard when ready
 	// clockGuard := middleware.IPWhitelist()
 	clockEmployees := handlers.NewResource[models.Employee]("employees")
 	clockAttendances := handlers.NewResource[models.Attendance]("attendances")
-	api.GET("/clock/employees", clockEmployees.List)
-	api.GET("/clock/attendances", clockAttendances.List)
-	api.POST("/clock/attendances", clockAttendances.Create)
-	api.PUT("/clock/attendances/:id", clockAttendances.Update)
+	clock := api.Group("/clock", middleware.PublicWarehouse())
+	clock.GET("/employees", clockEmployees.List)
+	clock.GET("/attendances", clockAttendances.List)
+	clock.POST("/attendances", clockAttendances.Create)
+	clock.PUT("/attendances/:id", clockAttendances.Update)
 
 	// Public read-only routes for Key Account pages (no auth needed)
 	publicSoh := handlers.NewResource[models.Soh]("soh")
 	publicLocations := handlers.NewResource[models.Location]("locations")
-	publicBeritaAcara := handlers.NewResource[models.BeritaAcara]("berita-acara")
-	api.GET("/public/soh", publicSoh.List)
-	api.GET("/public/locations", publicLocations.List)
-	api.GET("/public/berita-acara", publicBeritaAcara.List)
-	api.POST("/public/berita-acara", publicBeritaAcara.Create)
+	publicBeritaAcara := handlers.NewResource[models.BeritaAcara]("berita-acara").WithProtectedFields("doc_number").WithCreateHook(handlers.AssignDocNumber)
+	public := api.Group("/public", middleware.PublicWarehouse())
+	public.GET("/soh", publicSoh.List)
+	public.GET("/locations", publicLocations.List)
+	public.GET("/berita-acara", publicBeritaAcara.List)
+	public.POST("/berita-acara", publicBeritaAcara.Create)
 
 	// Protected routes
 	protected := api.Group("")
 	protected.Use(middleware.AuthRequired())
 	protected.GET("/auth/me", handlers.GetCurrentUser)
+	protected.PUT("/users/:id/password", handlers.ChangePassword)
+
+	// Everything below requires that a forced password change was done
+	protected.Use(middleware.PasswordChanged())
+	protected.POST("/auth/2fa/setup", handlers.SetupTwoFactor)
+	protected.POST("/auth/2fa/enable", handlers.EnableTwoFactor)
+
+	// Everything below requires 2FA enrollment when the role makes it mandatory
+	protected.Use(middleware.TwoFactorEnrolled())
+	protected.POST("/auth/2fa/disable", handlers.DisableTwoFactor)
+	protected.POST("/auth/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
 
 	// User management routes
 	protected.GET("/users", handlers.ListUsers)
 	protected.POST("/users", handlers.CreateUser)
-	protected.PUT("/users/:id/password", handlers.ChangePassword)
+	protected.POST("/users/:id/unlock", handlers.UnlockUser)
+	protected.POST("/users/:id/2fa/reset", handlers.ResetUserTwoFactor)
 	protected.PUT("/users/:id/role", handlers.ChangeRole)
+	protected.PUT("/users/:id/warehouses", handlers.SetUserWarehouses)
 	protected.DELETE("/users/:id", handlers.DeleteUser)
 
+	// Role and permission management (supervisor/leader only)
+	protected.GET("/roles", handlers.ListRoles)
+	protected.GET("/roles/options", handlers.RoleOptions)
+	protected.POST("/roles", handlers.CreateRole)
+	protected.PUT("/roles/:id", handlers.UpdateRole)
+	protected.DELETE("/roles/:id", handlers.DeleteRole)
+
+	// Warehouse master (supervisor/leader only)
+	protected.GET("/warehouses", handlers.ListWarehouses)
+	protected.POST("/warehouses", handlers.CreateWarehouse)
+	protected.PUT("/warehouses/:id", handlers.UpdateWarehouse)
+
+	// API keys for integrations (supervisor/leader only)
+	protected.GET("/api-keys", handlers.ListAPIKeys)
+	protected.POST("/api-keys", handlers.CreateAPIKey)
+	protected.DELETE("/api-keys/:id", handlers.RevokeAPIKey)
+	protected.GET("/api-keys/:id/usage", handlers.ListAPIKeyUsage)
+
+	// Audit log across all resources (supervisor/leader only)
+	protected.GET("/audit", handlers.ListAuditLogs)
+
+	// Resources below are scoped to the active warehouse (X-Warehouse header)
+	protected.Use(middleware.ActiveWarehouse())
+
 	// Register all resource routes using generic handler
 	arrivals := handlers.NewResource[models.Arrival]("arrivals")
 	arrivals.RegisterRoutes(protected.Group("/arrivals"))
+	protected.GET("/arrivals/status", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ListArrivalStatus(arrivals))
+	protected.GET("/arrivals/status/summary", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ArrivalStatusSummary(arrivals))
+	protected.GET("/arrivals/sla", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ListArrivalSla(arrivals))
+	protected.GET("/arrivals/sla/stats", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ArrivalSlaStats(arrivals))
+	protected.GET("/arrivals/reconciliation", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ListReconciliation(arrivals))
+	protected.GET("/arrivals/reconciliation/export", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ExportReconciliation(arrivals))
+	protected.GET("/arrivals/unloading", middleware.RequirePermission("arrivals", middleware.ActionList), handlers.ListArrivalUnloading(arrivals))
+
+	inboundSlas := handlers.NewResource[models.InboundSla]("inbound-slas").WithNaturalKey("brand", "urgensi")
+	inboundSlas.RegisterRoutes(protected.Group("/inbound-slas"))
+
+	docks := handlers.NewResource[models.Dock]("docks").WithNaturalKey("code")
+	docks.RegisterRoutes(protected.Group("/docks"))
+
+	dockSlots := handlers.NewResource[models.DockSlot]("dock-slots").WithValidator(handlers.DockSlotTimeRule)
+	dockSlots.RegisterRoutes(protected.Group("/dock-slots"))
+
+	handlers.NewDockAppointmentHandler(arrivals).RegisterRoutes(protected.Group("/dock-appointments"))
+
+	asnLines := handlers.NewResource[models.AsnLine]("asn-lines")
+	asnLines.RegisterRoutes(protected.Group("/asn-lines"))
+	handlers.NewAsnHandler(asnLines).RegisterRoutes(protected.Group("/asns"))
 
 	transactions := handlers.NewResource[models.Transaction]("transactions")
 	transactions.RegisterRoutes(protected.Group("/transactions"))
@@ -102,25 +169,25 @@ func main() {
 	vas := handlers.NewResource[models.Vas]("vas")
 	vas.RegisterRoutes(protected.Group("/vas"))
 
-	dcc := handlers.NewResource[models.Dcc]("dcc")
+	dcc := handlers.NewResource[models.Dcc]("dcc").WithValidator(handlers.DccVarianceRule)
 	dcc.RegisterRoutes(protected.Group("/dcc"))
 
 	damages := handlers.NewResource[models.Damage]("damages")
 	damages.RegisterRoutes(protected.Group("/damages"))
 
-	soh := handlers.NewResource[models.Soh]("soh")
+	soh := handlers.NewResource[models.Soh]("soh").WithNaturalKey("location", "sku", "batch_no")
 	soh.RegisterRoutes(protected.Group("/soh"))
 
 	qcReturns := handlers.NewResource[models.QcReturn]("qc-returns")
 	qcReturns.RegisterRoutes(protected.Group("/qc-returns"))
 
-	locations := handlers.NewResource[models.Location]("locations")
+	locations := handlers.NewResource[models.Location]("locations").WithNaturalKey("location")
 	locations.RegisterRoutes(protected.Group("/locations"))
 
 	attendances := handlers.NewResource[models.Attendance]("attendances")
 	attendances.RegisterRoutes(protected.Group("/attendances"))
 
-	employees := handlers.NewResource[models.Employee]("employees")
+	employees := handlers.NewResource[models.Employee]("employees").WithNaturalKey("nik")
 	employees.RegisterRoutes(protected.Group("/employees"))
 
 	productivity := handlers.NewResource[models.ProjectProductivity]("project-productivities")
@@ -128,28 +195,38 @@ func main() {
 
 	unloadings := handlers.NewResource[models.Unloading]("unloadings")
 	unloadings.RegisterRoutes(protected.Group("/unloadings"))
+	protected.GET("/unloadings/daily", middleware.RequirePermission("unloadings", middleware.ActionList), handlers.DailyUnloading)
+
+	unloadingEvents := handlers.NewResource[models.UnloadingEvent]("unloading-events").WithValidator(handlers.UnloadingEventTimeRule)
+	unloadingEvents.RegisterRoutes(protected.Group("/unloading-events"))
 
 	schedules := handlers.NewResource[models.Schedule]("schedules")
 	schedules.RegisterRoutes(protected.Group("/schedules"))
 
-	beritaAcara := handlers.NewResource[models.BeritaAcara]("berita-acara")
+	beritaAcara := handlers.NewResource[models.BeritaAcara]("berita-acara").WithProtectedFields("doc_number").WithCreateHook(handlers.AssignDocNumber)
 	beritaAcara.RegisterRoutes(protected.Group("/berita-acara"))
 
-	stockOpnames := handlers.NewResource[models.StockOpname]("stock-opnames")
+	docNumberFormats := handlers.NewResource[models.DocNumberFormat]("doc-number-formats").WithNaturalKey("doc_type").WithValidator(handlers.DocNumberFormatRule)
+	docNumberFormats.RegisterRoutes(protected.Group("/doc-number-formats"))
+
+	stockOpnames := handlers.NewResource[models.StockOpname]("stock-opnames").WithValidator(handlers.StockOpnameVarianceRule)
 	stockOpnames.RegisterRoutes(protected.Group("/stock-opnames"))
 
 	additionalMp := handlers.NewResource[models.AdditionalMp]("additional-mp")
 	additionalMp.RegisterRoutes(protected.Group("/additional-mp"))
 
-	masterItems := handlers.NewResource[models.MasterItem]("master-items")
+	masterItems := handlers.NewResource[models.MasterItem]("master-items").WithNaturalKey("sku", "owner")
 	masterItems.RegisterRoutes(protected.Group("/master-items"))
 
 	inboundRejections := handlers.NewResource[models.InboundRejection]("inbound-rejections")
 	inboundRejections.RegisterRoutes(protected.Group("/inbound-rejections"))
 
-	inboundCases := handlers.NewResource[models.InboundCase]("inbound-cases")
+	inboundCases := handlers.NewInboundCaseHandler()
 	inboundCases.RegisterRoutes(protected.Group("/inbound-cases"))
 
+	inboundCaseTypes := handlers.NewResource[models.InboundCaseType]("inbound-case-types").WithNaturalKey("code")
+	inboundCaseTypes.RegisterRoutes(protected.Group("/inbound-case-types"))
+
 	returnTransactions := handlers.NewResource[models.ReturnTransaction]("return-transactions")
 	returnTransactions.RegisterRoutes(protected.Group("/return-transactions"))
 
@@ -159,7 +236,7 @@ func main() {
 	rejectReturns := handlers.NewResource[models.RejectReturn]("reject-returns")
 	rejectReturns.RegisterRoutes(protected.Group("/reject-returns"))
 
-	orderPerBrands := handlers.NewResource[models.OrderPerBrand]("order-per-brands")
+	orderPerBrands := handlers.NewResource[models.OrderPerBrand]("order-per-brands").WithNaturalKey("month", "brand")
 	orderPerBrands.RegisterRoutes(protected.Group("/order-per-brands"))
 
 	workflows := handlers.NewResource[models.Workflow]("workflows")
@@ -168,9 +245,27 @@ func main() {
 	inventoryProjects := handlers.NewResource[models.InventoryProject]("inventory-projects")
 	inventoryProjects.RegisterRoutes(protected.Group("/inventory-projects"))
 
-	heatmapOverrides := handlers.NewResource[models.HeatmapOverride]("heatmap-overrides")
+	heatmapOverrides := handlers.NewResource[models.HeatmapOverride]("heatmap-overrides").WithNaturalKey("location")
 	heatmapOverrides.RegisterRoutes(protected.Group("/heatmap-overrides"))
 
+	// Hard-delete trash older than TRASH_RETENTION_DAYS (default 30, 0 disables)
+	retentionDays := 30
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			retentionDays = n
+		}
+	}
+	handlers.StartTrashRetention(retentionDays)
+
+	// Escalate overdue inbound cases every CASE_ESCALATION_MINUTES (default 60, 0 disables)
+	escalationMinutes := 60
+	if v := os.Getenv("CASE_ESCALATION_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			escalationMinutes = n
+		}
+	}
+	inboundCases.StartCaseEscalation(time.Duration(escalationMinutes) * time.Minute)
+
 	// Unboxing feature disabled per user request
 	// unboxingHandler := handlers.NewUnboxingHandler()
 	// unboxingHandler.RegisterRoutes(protected.Group("/unboxings"))
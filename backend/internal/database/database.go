@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"warehouse-report-monitoring/internal/models"
@@ -48,16 +49,25 @@ func Connect() {
 		}
 		log.Println("[DB] Connected to SQLite:", dbPath)
 	}
+
+	if err := RegisterWarehouseScope(DB); err != nil {
+		log.Fatalf("Failed to register warehouse scope: %v", err)
+	}
 }
 
 func AutoMigrate() {
-	// Deduplicate locations before auto-migrating to prevent unique constraint violation
+	// Deduplicate locations before auto-migrating to prevent unique constraint violation.
+	// Locations are unique per warehouse once the warehouse column exists.
+	groupBy := "location"
+	if DB.Migrator().HasColumn(&models.Location{}, "warehouse") {
+		groupBy = "warehouse, location"
+	}
 	if err := DB.Exec(`
 		DELETE FROM locations 
 		WHERE id NOT IN (
 			SELECT MIN(id) 
 			FROM locations 
-			GROUP BY location
+			GROUP BY ` + groupBy + `
 		)
 	`).Error; err != nil {
 		log.Printf("[DB] Warning: could not deduplicate locations: %v", err)
@@ -76,14 +86,20 @@ func AutoMigrate() {
 		&models.Employee{},
 		&models.ProjectProductivity{},
 		&models.Unloading{},
+		&models.UnloadingEvent{},
 		&models.User{},
 		&models.Schedule{},
 		&models.BeritaAcara{},
+		&models.DocNumberFormat{},
+		&models.DocNumberSequence{},
 		&models.StockOpname{},
 		&models.AdditionalMp{},
 		&models.MasterItem{},
 		&models.InboundRejection{},
 		&models.InboundCase{},
+		&models.InboundCaseType{},
+		&models.InboundCaseComment{},
+		&models.InboundCaseAttachment{},
 		&models.ReturnTransaction{},
 		&models.ReturnReceive{},
 		&models.RejectReturn{},
@@ -91,7 +107,25 @@ func AutoMigrate() {
 		&models.Workflow{},
 		&models.InventoryProject{},
 		&models.HeatmapOverride{},
+		&models.InboundSla{},
+		&models.Dock{},
+		&models.DockSlot{},
+		&models.DockAppointment{},
+		&models.Asn{},
+		&models.AsnLine{},
 		&models.ReturnUnboxing{},
+		&models.SyncSnapshot{},
+		&models.AuditLog{},
+		&models.Role{},
+		&models.Permission{},
+		&models.RefreshToken{},
+		&models.PasswordHistory{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.APIKeyScope{},
+		&models.APIKeyUsage{},
+		&models.Warehouse{},
+		&models.UserWarehouse{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to auto-migrate: %v", err)
@@ -102,6 +136,45 @@ func AutoMigrate() {
 	if err := DB.Exec("DROP INDEX IF EXISTS idx_berita_acaras_doc_number").Error; err != nil {
 		log.Printf("[DB] Warning: could not drop old unique index: %v", err)
 	}
+	// Document numbers are unique per warehouse among live records only, so a
+	// soft-deleted document does not block anything. Duplicates left by the
+	// old client-side numbering must be renumbered by hand before startup.
+	var duplicates []string
+	if err := DB.Raw(`SELECT warehouse || ' ' || doc_number FROM berita_acaras
+		WHERE deleted_at IS NULL AND doc_number <> ''
+		GROUP BY warehouse, doc_number HAVING COUNT(*) > 1`).Scan(&duplicates).Error; err != nil {
+		log.Fatalf("Failed to check berita_acaras for duplicate document numbers: %v", err)
+	}
+	if len(duplicates) > 0 {
+		log.Fatalf("berita_acaras has %d duplicate live document numbers, renumber or delete them first: %s",
+			len(duplicates), strings.Join(duplicates, ", "))
+	}
+	if err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_berita_acaras_live_doc_number
+		ON berita_acaras (warehouse, doc_number) WHERE deleted_at IS NULL AND doc_number <> ''`).Error; err != nil {
+		log.Fatalf("Failed to create unique index on berita_acaras.doc_number: %v", err)
+	}
+	// ASN numbers follow the same rule: one live ASN per number and warehouse
+	duplicates = nil
+	if err := DB.Raw(`SELECT warehouse || ' ' || asn_no FROM asns
+		WHERE deleted_at IS NULL AND asn_no <> ''
+		GROUP BY warehouse, asn_no HAVING COUNT(*) > 1`).Scan(&duplicates).Error; err != nil {
+		log.Fatalf("Failed to check asns for duplicate ASN numbers: %v", err)
+	}
+	if len(duplicates) > 0 {
+		log.Fatalf("asns has %d duplicate live ASN numbers, merge or delete them first: %s",
+			len(duplicates), strings.Join(duplicates, ", "))
+	}
+	if err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_asns_live_asn_no
+		ON asns (warehouse, asn_no) WHERE deleted_at IS NULL AND asn_no <> ''`).Error; err != nil {
+		log.Fatalf("Failed to create unique index on asns.asn_no: %v", err)
+	}
+
+	// Location codes are unique per warehouse now; drop the old global unique indexes
+	for _, idx := range []string{"idx_locations_location", "idx_heatmap_overrides_location"} {
+		if err := DB.Exec("DROP INDEX IF EXISTS " + idx).Error; err != nil {
+			log.Printf("[DB] Warning: could not drop old unique index %s: %v", idx, err)
+		}
+	}
 
 	// Normalize empty item_type to 'Barang Jual' for arrivals and vases
 	if res := DB.Exec("UPDATE arrivals SET item_type = 'Barang Jual' WHERE item_type IS NULL OR item_type = ''"); res.Error != nil {
@@ -149,16 +222,61 @@ func SeedDefaultUsers() {
 		}
 		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		DB.Create(&models.User{
-			Username: d.Username,
-			Password: string(hash),
-			Role:     d.Role,
+			Username:           d.Username,
+			Password:           string(hash),
+			Role:               d.Role,
+			MustChangePassword: true,
 		})
 	}
 
-	log.Println("[DB] Default users seeded — CHANGE PASSWORDS after first login!")
+	log.Println("[DB] Default users seeded — a password change is required on first login")
 	log.Println("═══════════════════════════════════════════════")
 }
 
+// SeedDefaultRoles creates the built-in roles if the roles table is empty.
+// Supervisor and leader are super roles; the other roles may read, create,
+// update and import on every resource, but not delete or sync. Roles already
+// assigned to users but not in the list get the same defaults.
+func SeedDefaultRoles() {
+	var count int64
+	DB.Model(&models.Role{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	roles := []models.Role{
+		{Name: "supervisor", Description: "Supervisor", IsSuper: true},
+		{Name: "leader", Description: "Leader", IsSuper: true},
+		{Name: "admin_inbound", Description: "Admin Inbound"},
+		{Name: "admin_inventory", Description: "Admin Inventory"},
+		{Name: "key_account", Description: "Key Account"},
+	}
+	known := map[string]bool{}
+	for _, r := range roles {
+		known[r.Name] = true
+	}
+	var userRoles []string
+	DB.Model(&models.User{}).Distinct().Pluck("role", &userRoles)
+	for _, name := range userRoles {
+		if name != "" && !known[name] {
+			roles = append(roles, models.Role{Name: name, Description: name})
+			known[name] = true
+		}
+	}
+
+	for i := range roles {
+		if !roles[i].IsSuper {
+			for _, action := range []string{"list", "get", "create", "update", "import"} {
+				roles[i].Permissions = append(roles[i].Permissions, models.Permission{Resource: "*", Action: action})
+			}
+		}
+		if err := DB.Create(&roles[i]).Error; err != nil {
+			log.Printf("[DB] Warning: could not seed role %s: %v", roles[i].Name, err)
+		}
+	}
+	log.Printf("[DB] Seeded %d default roles", len(roles))
+}
+
 // generateRandomPassword creates a random alphanumeric password of given length
 func generateRandomPassword(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$"
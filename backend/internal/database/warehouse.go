@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"log"
+	"reflect"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultWarehouse is the warehouse that existing data and users belong to
+// before any other warehouse is set up
+const DefaultWarehouse = "WH-JC"
+
+// warehouseColumn is the column that ties a record to a warehouse
+const warehouseColumn = "warehouse"
+
+type warehouseKey struct{}
+
+// WithWarehouse returns a context that confines every query run with it to
+// one warehouse: models with a warehouse column are filtered on read, update
+// and delete, and stamped with the warehouse on create and update.
+// An empty code leaves queries unscoped.
+func WithWarehouse(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, warehouseKey{}, code)
+}
+
+// WarehouseFrom returns the warehouse a context was confined to with
+// WithWarehouse, or "" when it is unscoped
+func WarehouseFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	code, _ := ctx.Value(warehouseKey{}).(string)
+	return code
+}
+
+// RegisterWarehouseScope installs the callbacks behind WithWarehouse
+func RegisterWarehouseScope(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("warehouse:scope", scopeToWarehouse); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("warehouse:scope", scopeToWarehouse); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("warehouse:scope", scopeWriteToWarehouse); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("warehouse:stamp", stampWarehouse); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("warehouse:scope", scopeWriteToWarehouse); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("warehouse:stamp", stampWarehouse)
+}
+
+// warehouseOf returns the statement's warehouse and the model's warehouse
+// field, or a nil field when the statement is not warehouse-scoped
+func warehouseOf(db *gorm.DB) (string, *schema.Field) {
+	if db.Statement.Schema == nil || db.Statement.Context == nil {
+		return "", nil
+	}
+	code := WarehouseFrom(db.Statement.Context)
+	if code == "" {
+		return "", nil
+	}
+	return code, db.Statement.Schema.LookUpField(warehouseColumn)
+}
+
+// scopeToWarehouse adds warehouse = <active warehouse> to a query
+func scopeToWarehouse(db *gorm.DB) {
+	code, field := warehouseOf(db)
+	if field == nil {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: code},
+	}})
+}
+
+// scopeWriteToWarehouse scopes an update or delete like scopeToWarehouse.
+// Statements without any condition are left alone so GORM still rejects
+// them as global unless AllowGlobalUpdate is set.
+func scopeWriteToWarehouse(db *gorm.DB) {
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok && !db.AllowGlobalUpdate && !hasPrimaryKey(db) {
+		return
+	}
+	scopeToWarehouse(db)
+}
+
+// hasPrimaryKey reports whether the statement targets records by primary key
+func hasPrimaryKey(db *gorm.DB) bool {
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	rv := db.Statement.ReflectValue
+	if pk == nil || !rv.IsValid() {
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if _, zero := pk.ValueOf(db.Statement.Context, reflect.Indirect(rv.Index(i))); !zero {
+				return true
+			}
+		}
+	case reflect.Struct:
+		_, zero := pk.ValueOf(db.Statement.Context, rv)
+		return !zero
+	}
+	return false
+}
+
+// stampWarehouse sets the warehouse field of the records being written, so a
+// record is always saved into the active warehouse whatever the body said
+func stampWarehouse(db *gorm.DB) {
+	code, field := warehouseOf(db)
+	if field == nil {
+		return
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			db.AddError(field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), code))
+		}
+	case reflect.Struct:
+		if rv.CanAddr() {
+			db.AddError(field.Set(db.Statement.Context, rv, code))
+		}
+	}
+	if m, ok := db.Statement.Dest.(map[string]interface{}); ok {
+		m[field.DBName] = code
+	}
+}
+
+// SeedDefaultWarehouses creates the default warehouse, plus any warehouse
+// already named on Berita Acara documents, if the warehouses table is empty.
+// Every existing user is assigned to all of them so no data goes out of sight.
+func SeedDefaultWarehouses() {
+	var count int64
+	DB.Model(&models.Warehouse{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	codes := []string{DefaultWarehouse}
+	var used []string
+	DB.Model(&models.BeritaAcara{}).Distinct().Where("warehouse <> ''").Pluck("warehouse", &used)
+	for _, code := range used {
+		if code != DefaultWarehouse {
+			codes = append(codes, code)
+		}
+	}
+
+	var userIDs []uint
+	DB.Model(&models.User{}).Pluck("id", &userIDs)
+	for _, code := range codes {
+		if err := DB.Create(&models.Warehouse{Code: code, Name: code, IsActive: true}).Error; err != nil {
+			log.Printf("[DB] Warning: could not seed warehouse %s: %v", code, err)
+			continue
+		}
+		for _, id := range userIDs {
+			DB.Create(&models.UserWarehouse{UserID: id, WarehouseCode: code})
+		}
+	}
+	log.Printf("[DB] Seeded %d warehouses and assigned %d users", len(codes), len(userIDs))
+}
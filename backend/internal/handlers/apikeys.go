@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyPrefixLen is how much of a key is kept in clear to tell keys apart
+const apiKeyPrefixLen = 12
+
+// ListAPIKeys returns every API key with its scopes (supervisor/leader only)
+func ListAPIKeys(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var keys []models.APIKey
+	if err := database.DB.Preload("Scopes").Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a new API key (supervisor/leader only). The key itself
+// is only returned in this response.
+// Body: {"name", "scopes": [{"resource", "action"}], "warehouse", "expires_in_days"};
+// the key works only in its warehouse (default WH-JC) and expires_in_days 0
+// means it never expires.
+func CreateAPIKey(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var req struct {
+		Name          string              `json:"name" binding:"required"`
+		Scopes        []models.Permission `json:"scopes" binding:"required,min=1"`
+		Warehouse     string              `json:"warehouse"`
+		ExpiresInDays int                 `json:"expires_in_days" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name dan minimal satu scope wajib diisi"})
+		return
+	}
+	if req.Warehouse == "" {
+		req.Warehouse = database.DefaultWarehouse
+	}
+	if !warehouseExists(req.Warehouse) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gudang tidak dikenal: " + req.Warehouse})
+		return
+	}
+	perms, err := validatePermissions(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	key := "wrm_" + base64.RawURLEncoding.EncodeToString(b)
+
+	apiKey := models.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    key[:apiKeyPrefixLen],
+		KeyHash:   middleware.HashAPIKey(key),
+		Warehouse: req.Warehouse,
+		CreatedBy: c.GetString("username"),
+	}
+	for _, p := range perms {
+		apiKey.Scopes = append(apiKey.Scopes, models.APIKeyScope{Resource: p.Resource, Action: p.Action})
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expires
+	}
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[APIKEY] %s created key #%d (%s)", apiKey.CreatedBy, apiKey.ID, apiKey.Name)
+	c.JSON(http.StatusCreated, gin.H{"api_key": apiKey, "key": key})
+}
+
+// RevokeAPIKey disables an API key for good (supervisor/leader only)
+func RevokeAPIKey(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	res := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", c.Param("id")).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key tidak ditemukan"})
+		return
+	}
+	log.Printf("[APIKEY] %s revoked key #%s", c.GetString("username"), c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"message": "API key dicabut"})
+}
+
+// ListAPIKeyUsage returns the request log of one API key, newest first
+// (supervisor/leader only). Paginated with page/pageSize.
+func ListAPIKeyUsage(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	query := database.DB.Model(&models.APIKeyUsage{}).Where("api_key_id = ?", c.Param("id"))
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize := pageSizeParam(c)
+
+	var usage []models.APIKeyUsage
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&usage).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": usage, "total": total})
+}
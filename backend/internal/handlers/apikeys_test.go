@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	db := openTestDB(t, &models.APIKey{}, &models.APIKeyScope{}, &models.APIKeyUsage{},
+		&models.Warehouse{}, &models.Role{}, &models.Permission{})
+	middleware.InvalidatePermissions()
+	if err := db.Create(&[]models.Role{{Name: "supervisor", IsSuper: true}, {Name: "staff"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&[]models.Warehouse{{Code: "WH-JC"}, {Code: "WH-SBY"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	supervisor := gin.H{"role": "supervisor"}
+	scopes := []gin.H{{"resource": "arrivals", "action": "list"}, {"resource": "soh", "action": "*"}}
+
+	tests := []struct {
+		name string
+		body gin.H
+		keys gin.H
+		want int
+	}{
+		{"staff", gin.H{"name": "x", "scopes": scopes}, gin.H{"role": "staff"}, http.StatusForbidden},
+		{"no scopes", gin.H{"name": "x", "scopes": []gin.H{}}, supervisor, http.StatusBadRequest},
+		{"unknown action", gin.H{"name": "x", "scopes": []gin.H{{"resource": "soh", "action": "drop"}}}, supervisor, http.StatusBadRequest},
+		{"unknown warehouse", gin.H{"name": "x", "scopes": scopes, "warehouse": "WH-XX"}, supervisor, http.StatusBadRequest},
+		{"negative expiry", gin.H{"name": "x", "scopes": scopes, "expires_in_days": -1}, supervisor, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := callHandler(t, CreateAPIKey, nil, tt.body, tt.keys); w.Code != tt.want {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+
+	w := callHandler(t, CreateAPIKey, nil, gin.H{"name": " feed ", "scopes": scopes, "warehouse": "WH-SBY", "expires_in_days": 30}, supervisor)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var created struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID uint `json:"id"`
+		} `json:"api_key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(w.Body.String(), middleware.HashAPIKey(created.Key)) {
+		t.Error("response carries the key hash")
+	}
+	var stored models.APIKey
+	if err := db.Preload("Scopes").First(&stored, created.APIKey.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "feed" || stored.Warehouse != "WH-SBY" || stored.CreatedBy != "tester" || len(stored.Scopes) != 2 ||
+		stored.KeyHash != middleware.HashAPIKey(created.Key) || !strings.HasPrefix(created.Key, stored.Prefix) {
+		t.Errorf("stored key %+v", stored)
+	}
+	if stored.ExpiresAt == nil || stored.ExpiresAt.Sub(time.Now().AddDate(0, 0, 30)).Abs() > time.Minute {
+		t.Errorf("expires at %v, want in 30 days", stored.ExpiresAt)
+	}
+
+	w = callHandler(t, CreateAPIKey, nil, gin.H{"name": "forever", "scopes": scopes}, supervisor)
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create without expiry: %d %s", w.Code, w.Body)
+	}
+	stored = models.APIKey{}
+	db.First(&stored, created.APIKey.ID)
+	if stored.Warehouse != "WH-JC" || stored.ExpiresAt != nil {
+		t.Errorf("default key: warehouse %q, expires at %v", stored.Warehouse, stored.ExpiresAt)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	db := openTestDB(t, &models.APIKey{}, &models.APIKeyScope{}, &models.APIKeyUsage{},
+		&models.Warehouse{}, &models.Role{}, &models.Permission{})
+	middleware.InvalidatePermissions()
+	if err := db.Create(&[]models.Role{{Name: "supervisor", IsSuper: true}, {Name: "staff"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Warehouse{Code: "WH-JC"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	key := models.APIKey{Name: "feed", KeyHash: middleware.HashAPIKey("wrm_feed"), Scopes: []models.APIKeyScope{{Resource: "*", Action: "*"}}}
+	if err := db.Create(&key).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", middleware.AuthRequired(), middleware.ActiveWarehouse(), middleware.RequirePermission("arrivals", "list"),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(middleware.APIKeyHeader, "wrm_feed")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("before revoking: %d", code)
+	}
+
+	id := gin.Params{{Key: "id", Value: "1"}}
+	if w := callHandler(t, RevokeAPIKey, id, nil, gin.H{"role": "staff"}); w.Code != http.StatusForbidden {
+		t.Errorf("revoke as staff: %d, want 403", w.Code)
+	}
+	if w := callHandler(t, RevokeAPIKey, id, nil, gin.H{"role": "supervisor"}); w.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", w.Code, w.Body)
+	}
+	if code := get(); code != http.StatusUnauthorized {
+		t.Errorf("after revoking: %d, want 401", code)
+	}
+	if w := callHandler(t, RevokeAPIKey, id, nil, gin.H{"role": "supervisor"}); w.Code != http.StatusNotFound {
+		t.Errorf("revoke twice: %d, want 404", w.Code)
+	}
+	if w := callHandler(t, RevokeAPIKey, gin.Params{{Key: "id", Value: "9"}}, nil, gin.H{"role": "supervisor"}); w.Code != http.StatusNotFound {
+		t.Errorf("revoke an unknown key: %d, want 404", w.Code)
+	}
+}
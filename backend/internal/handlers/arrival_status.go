@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Arrival statuses derived from the transactions of the receipt
+const (
+	ArrivalPendingReceive = "Pending Receive"
+	ArrivalPendingPutaway = "Pending Putaway"
+	ArrivalCompleted      = "Completed"
+)
+
+// ArrivalStatuses lists the arrival statuses in workflow order
+var ArrivalStatuses = []string{ArrivalPendingReceive, ArrivalPendingPutaway, ArrivalCompleted}
+
+// ArrivalStatus is an arrival with its progress derived from the transactions
+// booked on the same receipt_no
+type ArrivalStatus struct {
+	models.Arrival
+	ReceiveQty   int             `json:"receive_qty"`
+	PutawayQty   int             `json:"putaway_qty"`
+	PendingQty   int             `json:"pending_qty"`
+	FirstReceive models.FlexDate `json:"first_receive"`
+	LastPutaway  models.FlexDate `json:"last_putaway"`
+	Status       string          `json:"status"`
+}
+
+// arrivalStatusExpr computes the status column of withArrivalStatus. An
+// arrival is completed when both received and put-away qty equal po_qty,
+// pending putaway when only the received qty does, and pending receive otherwise.
+var arrivalStatusExpr = fmt.Sprintf(`CASE
+	WHEN COALESCE(t.receive_qty, 0) = arrivals.po_qty AND COALESCE(t.putaway_qty, 0) = arrivals.po_qty THEN '%s'
+	WHEN COALESCE(t.receive_qty, 0) = arrivals.po_qty THEN '%s'
+	ELSE '%s' END`, ArrivalCompleted, ArrivalPendingPutaway, ArrivalPendingReceive)
+
+// withArrivalStatus joins the transaction totals of each receipt onto an
+// arrivals query. Receipts match trimmed and case-insensitively; "receive"
+// and "receiving" transactions count as received, "putaway" as put away.
+// The totals are read with the same session, so they share its warehouse.
+func withArrivalStatus(query *gorm.DB) *gorm.DB {
+	const receive = "LOWER(TRIM(operate_type)) IN ('receive', 'receiving')"
+	const putaway = "LOWER(TRIM(operate_type)) = 'putaway'"
+	totals := query.Session(&gorm.Session{NewDB: true}).Model(&models.Transaction{}).
+		Select(strings.Join([]string{
+			"LOWER(TRIM(receipt_no)) AS receipt_key",
+			"SUM(CASE WHEN " + receive + " THEN qty ELSE 0 END) AS receive_qty",
+			"SUM(CASE WHEN " + putaway + " THEN qty ELSE 0 END) AS putaway_qty",
+			"MIN(CASE WHEN " + receive + " AND time_transaction <> '' THEN time_transaction END) AS first_receive",
+			"MAX(CASE WHEN " + putaway + " AND time_transaction <> '' THEN time_transaction END) AS last_putaway",
+		}, ", ")).
+		Where("TRIM(receipt_no) <> ''").
+		Group("LOWER(TRIM(receipt_no))")
+
+	return query.
+		Select(strings.Join([]string{
+			"arrivals.*",
+			"COALESCE(t.receive_qty, 0) AS receive_qty",
+			"COALESCE(t.putaway_qty, 0) AS putaway_qty",
+			"ABS(COALESCE(t.receive_qty, 0) - arrivals.po_qty) AS pending_qty",
+			"COALESCE(t.first_receive, '') AS first_receive",
+			"COALESCE(t.last_putaway, '') AS last_putaway",
+			arrivalStatusExpr + " AS status",
+		}, ", ")).
+		Joins("LEFT JOIN (?) AS t ON t.receipt_key = LOWER(TRIM(arrivals.receipt_no))", totals)
+}
+
+// arrivalStatusQuery builds the status query for a request: the usual list
+// filters of the arrivals resource plus status=<status>[,<status>...]
+func arrivalStatusQuery(h *ResourceHandler[models.Arrival], c *gin.Context) (*gorm.DB, error) {
+	query, err := h.listQuery(c)
+	if err != nil {
+		return nil, err
+	}
+	query = withArrivalStatus(query)
+	if raw := c.Query("status"); raw != "" {
+		var statuses []string
+		for _, s := range strings.Split(raw, ",") {
+			s = strings.TrimSpace(s)
+			if !isArrivalStatus(s) {
+				return nil, fmt.Errorf("unknown status %q, expected one of %s", s, strings.Join(ArrivalStatuses, ", "))
+			}
+			statuses = append(statuses, s)
+		}
+		query = query.Where("("+arrivalStatusExpr+") IN ?", statuses)
+	}
+	return query, nil
+}
+
+// isArrivalStatus checks a status name
+func isArrivalStatus(s string) bool {
+	for _, status := range ArrivalStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ListArrivalStatus serves GET /arrivals/status: arrivals with receive,
+// putaway and pending qty, first receive and last putaway time and status.
+// It takes the same filter, search, date and sort parameters as the arrivals
+// list plus status; without page it returns a flat array, with page/pageSize
+// {"data", "total"}.
+func ListArrivalStatus(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := arrivalStatusQuery(h, c)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+		keys, err := h.sortKeys(c)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+
+		pageStr := c.Query("page")
+		if pageStr == "" {
+			var items []ArrivalStatus
+			if err := applySort(query, keys).Find(&items).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, items)
+			return
+		}
+
+		page, _ := strconv.Atoi(pageStr)
+		if page < 1 {
+			page = 1
+		}
+		pageSize := pageSizeParam(c)
+
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items := []ArrivalStatus{}
+		if err := applySort(query, keys).Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": items, "total": total})
+	}
+}
+
+// ArrivalStatusSummary serves GET /arrivals/status/summary: the number of
+// arrivals and their qty per status, for the same parameters as ListArrivalStatus
+func ArrivalStatusSummary(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := arrivalStatusQuery(h, c)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+
+		type statusRow struct {
+			Status     string `json:"status"`
+			Count      int64  `json:"count"`
+			PoQty      int64  `json:"po_qty"`
+			ReceiveQty int64  `json:"receive_qty"`
+			PutawayQty int64  `json:"putaway_qty"`
+		}
+		var rows []statusRow
+		err = query.Session(&gorm.Session{NewDB: true}).Table("(?) AS s", query).
+			Select("status, COUNT(*) AS count, SUM(po_qty) AS po_qty, SUM(receive_qty) AS receive_qty, SUM(putaway_qty) AS putaway_qty").
+			Group("status").
+			Scan(&rows).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Report every status, in workflow order, even when it has no arrivals
+		byStatus := make(map[string]statusRow, len(rows))
+		for _, r := range rows {
+			byStatus[r.Status] = r
+		}
+		summary := make([]statusRow, len(ArrivalStatuses))
+		var total int64
+		for i, s := range ArrivalStatuses {
+			summary[i] = byStatus[s]
+			summary[i].Status = s
+			total += summary[i].Count
+		}
+		c.JSON(http.StatusOK, gin.H{"statuses": summary, "total": total})
+	}
+}
@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Receiving statuses of an ASN line, and of the ASNs of a receipt as a whole
+const (
+	AsnPending  = "Pending"
+	AsnPartial  = "Partial"
+	AsnComplete = "Complete"
+	AsnOver     = "Over"
+)
+
+// asnLineAliases maps the column names suppliers commonly use to the ASN
+// line columns
+var asnLineAliases = map[string]string{
+	"qty":          "expected_qty",
+	"quantity":     "expected_qty",
+	"asn_qty":      "expected_qty",
+	"batch":        "batch_no",
+	"lot":          "batch_no",
+	"lot_no":       "batch_no",
+	"expiry":       "expiry_date",
+	"exp_date":     "expiry_date",
+	"expired_date": "expiry_date",
+}
+
+// AsnHandler serves ASNs: the generic resource routes plus the supplier file
+// import, attaching to an arrival and the expected vs received view
+type AsnHandler struct {
+	asns  *ResourceHandler[models.Asn]
+	lines *ResourceHandler[models.AsnLine]
+}
+
+// NewAsnHandler creates the handler; imported lines are written through the
+// given ASN lines resource, and deleting an ASN deletes its lines with it
+func NewAsnHandler(lines *ResourceHandler[models.AsnLine]) *AsnHandler {
+	h := &AsnHandler{
+		asns:  NewResource[models.Asn]("asns").WithNaturalKey("asn_no"),
+		lines: lines,
+	}
+	h.asns.WithDeleteHook(h.deleteLines)
+	return h
+}
+
+// deleteLines moves the lines of deleted ASNs to the trash as well
+func (h *AsnHandler) deleteLines(tx *gorm.DB, asns []models.Asn, actor string) error {
+	ids := make([]uint, len(asns))
+	for i, asn := range asns {
+		ids[i] = asn.ID
+	}
+	var lineIDs []uint
+	if err := tx.Model(&models.AsnLine{}).Where("asn_id IN ?", ids).Pluck("id", &lineIDs).Error; err != nil {
+		return err
+	}
+	if len(lineIDs) == 0 {
+		return nil
+	}
+	_, err := h.lines.deleteIDs(tx, lineIDs, actor)
+	return err
+}
+
+// RegisterRoutes registers the ASN routes
+func (h *AsnHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	h.asns.RegisterRoutes(rg)
+	can := func(action string) gin.HandlerFunc {
+		return middleware.RequirePermission(h.asns.Name, action)
+	}
+	rg.GET("/receiving", can(middleware.ActionList), h.ReceivingByReceipt)
+	rg.GET("/:id/receiving", can(middleware.ActionGet), h.Receiving)
+	rg.POST("/:id/attach", can(middleware.ActionUpdate), h.Attach)
+	rg.POST("/import/supplier", can(middleware.ActionImport), h.ImportSupplierFile)
+}
+
+// AsnImportReport is the result of a supplier file import
+type AsnImportReport struct {
+	ImportReport
+	Asn          models.Asn `json:"asn"`
+	LinesRemoved int        `json:"lines_removed"`
+}
+
+// findArrival loads an arrival to attach an ASN to
+func findArrival(tx *gorm.DB, id uint) (models.Arrival, error) {
+	var arrival models.Arrival
+	if err := tx.First(&arrival, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return arrival, statusFailed(http.StatusBadRequest, "Unknown arrival %d", id)
+		}
+		return arrival, err
+	}
+	return arrival, nil
+}
+
+// attachArrival links an ASN to an arrival, taking over its receipt and the
+// delivery details the ASN does not have yet
+func attachArrival(asn *models.Asn, arrival models.Arrival) {
+	asn.ArrivalID = &arrival.ID
+	if strings.TrimSpace(arrival.ReceiptNo) != "" {
+		asn.ReceiptNo = arrival.ReceiptNo
+	}
+	if asn.PoNo == "" {
+		asn.PoNo = arrival.PoNo
+	}
+	if asn.Supplier == "" {
+		asn.Supplier = arrival.Supplier
+	}
+	if asn.Brand == "" {
+		asn.Brand = arrival.Brand
+	}
+}
+
+// ImportSupplierFile serves POST /asns/import/supplier: a multipart CSV or
+// XLSX file of ASN lines (sku, expected_qty, batch_no, expiry_date) with the
+// ASN header in the form fields asn_no, date, supplier, brand, po_no,
+// receipt_no and arrival_id. An ASN with the same asn_no gets the header
+// fields that are filled in and its lines replaced by those of the file.
+// mode works as for the generic file import.
+func (h *AsnHandler) ImportSupplierFile(c *gin.Context) {
+	mode := c.DefaultPostForm("mode", c.DefaultQuery("mode", ImportAllOrNothing))
+	if mode != ImportAllOrNothing && mode != ImportSkipInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("mode must be %s or %s", ImportAllOrNothing, ImportSkipInvalid)})
+		return
+	}
+	header := models.Asn{
+		AsnNo:     strings.TrimSpace(c.PostForm("asn_no")),
+		Supplier:  strings.TrimSpace(c.PostForm("supplier")),
+		Brand:     strings.TrimSpace(c.PostForm("brand")),
+		PoNo:      strings.TrimSpace(c.PostForm("po_no")),
+		ReceiptNo: strings.TrimSpace(c.PostForm("receipt_no")),
+		Note:      strings.TrimSpace(c.PostForm("note")),
+	}
+	if header.AsnNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "asn_no is required"})
+		return
+	}
+	if v := c.PostForm("date"); v != "" {
+		if header.Date = models.ParseFlexDate(v); !header.Date.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date"})
+			return
+		}
+	}
+	var arrivalID *uint
+	if v := c.PostForm("arrival_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "arrival_id must be a number"})
+			return
+		}
+		uid := uint(id)
+		arrivalID = &uid
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	rows, err := readImportFile(fh)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+	for i, name := range rows[0] {
+		if alias, ok := asnLineAliases[normalizeHeader(name)]; ok {
+			rows[0][i] = alias
+		}
+	}
+
+	actor := c.GetString("username")
+	report := AsnImportReport{ImportReport: ImportReport{Mode: mode, ImportMode: ImportInsertOnly, IgnoredColumns: []string{}, Errors: []RowError{}}}
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var asn models.Asn
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("asn_no = ?", header.AsnNo).Limit(1).Find(&asn)
+		if res.Error != nil {
+			return res.Error
+		}
+		exists := res.RowsAffected > 0
+		before := asn
+		asn.AsnNo = header.AsnNo
+		if header.Date.Valid {
+			asn.Date = header.Date
+		}
+		keep := func(dst *string, v string) {
+			if v != "" {
+				*dst = v
+			}
+		}
+		keep(&asn.Supplier, header.Supplier)
+		keep(&asn.Brand, header.Brand)
+		keep(&asn.PoNo, header.PoNo)
+		keep(&asn.ReceiptNo, header.ReceiptNo)
+		keep(&asn.Note, header.Note)
+		if arrivalID != nil {
+			arrival, err := findArrival(tx, *arrivalID)
+			if err != nil {
+				return err
+			}
+			attachArrival(&asn, arrival)
+		}
+		if !exists {
+			asn.UpdatedBy = actor
+			if err := tx.Create(&asn).Error; err != nil {
+				return err
+			}
+			if err := writeAudit(tx, h.asns.auditRecord(AuditCreate, actor, nil, &asn)); err != nil {
+				return err
+			}
+		} else if changes := h.asns.auditDiff(&before, &asn); len(changes) > 0 {
+			asn.UpdatedBy = actor
+			if err := tx.Save(&asn).Error; err != nil {
+				return err
+			}
+			if err := writeAudit(tx, h.asns.newAuditLog(AuditUpdate, actor, asn.ID, changes)); err != nil {
+				return err
+			}
+		}
+		fixed := map[string]interface{}{"asn_id": asn.ID}
+		if actor != "" {
+			fixed["updated_by"] = actor
+		}
+		lines, err := h.lines.parseImportRows(rows, fixed, &report.ImportReport)
+		if err != nil {
+			return statusFailed(http.StatusBadRequest, "%s", err.Error())
+		}
+		report.Failed = len(report.Errors)
+		if mode == ImportAllOrNothing && report.Failed > 0 {
+			return errValidation
+		}
+		if len(lines) == 0 {
+			return statusFailed(http.StatusBadRequest, "file has no valid lines")
+		}
+
+		var old []models.AsnLine
+		if err := tx.Where("asn_id = ?", asn.ID).Find(&old).Error; err != nil {
+			return err
+		}
+		if len(old) > 0 {
+			if err := tx.Where("asn_id = ?", asn.ID).Delete(&models.AsnLine{}).Error; err != nil {
+				return err
+			}
+			entries := make([]models.AuditLog, len(old))
+			for i := range old {
+				entries[i] = h.lines.auditRecord(AuditDelete, actor, &old[i], nil)
+			}
+			if err := writeAudit(tx, entries...); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateInBatches(&lines, 500).Error; err != nil {
+			return err
+		}
+		if err := writeAudit(tx, h.lines.auditCreates(lines, actor)...); err != nil {
+			return err
+		}
+		report.Asn = asn
+		report.LinesRemoved = len(old)
+		report.Inserted, report.Imported = len(lines), len(lines)
+		return nil
+	})
+	if errors.Is(err, errValidation) {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+
+	log.Printf("[IMPORT] %s: %s imported %d of %d lines of ASN %s from %s (%s)",
+		h.asns.Name, actor, report.Imported, report.TotalRows, report.Asn.AsnNo, fh.Filename, mode)
+	c.JSON(http.StatusOK, report)
+}
+
+// AttachRequest is the body of POST /asns/:id/attach
+type AttachRequest struct {
+	ArrivalID *uint `json:"arrival_id" binding:"required"`
+}
+
+// Attach serves POST /asns/:id/attach: links the ASN to an arrival and takes
+// over its receipt_no, so receiving on that receipt is matched to the lines
+func (h *AsnHandler) Attach(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req AttachRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("arrival_id is required")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := c.GetString("username")
+
+	var asn models.Asn
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&asn, id).Error; err != nil {
+			return err
+		}
+		arrival, err := findArrival(tx, *req.ArrivalID)
+		if err != nil {
+			return err
+		}
+		before := asn
+		attachArrival(&asn, arrival)
+		changes := h.asns.auditDiff(&before, &asn)
+		if len(changes) == 0 {
+			return nil
+		}
+		asn.UpdatedBy = actor
+		if err := tx.Save(&asn).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.asns.newAuditLog(AuditUpdate, actor, asn.ID, changes))
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.Header("ETag", etagOf(&asn))
+	c.JSON(http.StatusOK, asn)
+}
+
+// AsnLineReceiving is an ASN line with the qty received against it so far.
+// Variance is received minus expected.
+type AsnLineReceiving struct {
+	models.AsnLine
+	AsnNo       string `json:"asn_no"`
+	ReceivedQty int    `json:"received_qty"`
+	Variance    int    `json:"variance"`
+	Status      string `json:"status"`
+}
+
+// AsnReceiving compares the lines of the ASNs of a receipt with what the
+// receiving transactions booked on it. SKUs received but not announced on
+// any line are listed as unexpected.
+type AsnReceiving struct {
+	ReceiptNo   string             `json:"receipt_no"`
+	Asns        []models.Asn       `json:"asns"`
+	Lines       []AsnLineReceiving `json:"lines"`
+	Unexpected  []ReconSku         `json:"unexpected"`
+	ExpectedQty int                `json:"expected_qty"`
+	ReceivedQty int                `json:"received_qty"`
+	Variance    int                `json:"variance"`
+	Status      string             `json:"status"`
+}
+
+// asnLineStatus rates the received qty of a line against the expected qty
+func asnLineStatus(expected, received int) string {
+	switch {
+	case received == 0 && expected > 0:
+		return AsnPending
+	case received < expected:
+		return AsnPartial
+	case received > expected:
+		return AsnOver
+	default:
+		return AsnComplete
+	}
+}
+
+// matchReceiving spreads the received qty per SKU over the ASN lines. A SKU
+// on several lines fills them in order; whatever is left over goes to its
+// last line.
+func matchReceiving(asns []models.Asn, lines []models.AsnLine, received []ReconSku) AsnReceiving {
+	asnNo := make(map[uint]string, len(asns))
+	for _, a := range asns {
+		asnNo[a.ID] = a.AsnNo
+	}
+	left := map[string]int{}
+	for _, s := range received {
+		left[strings.ToLower(strings.TrimSpace(s.Sku))] += s.ReceivedQty
+	}
+	last := map[string]int{}
+	for i, l := range lines {
+		last[strings.ToLower(strings.TrimSpace(l.Sku))] = i
+	}
+
+	out := AsnReceiving{Asns: asns, Lines: make([]AsnLineReceiving, len(lines)), Unexpected: []ReconSku{}}
+	for i, l := range lines {
+		key := strings.ToLower(strings.TrimSpace(l.Sku))
+		qty := min(left[key], l.ExpectedQty)
+		if last[key] == i {
+			qty = left[key]
+		}
+		left[key] -= qty
+		out.Lines[i] = AsnLineReceiving{
+			AsnLine:     l,
+			AsnNo:       asnNo[l.AsnID],
+			ReceivedQty: qty,
+			Variance:    qty - l.ExpectedQty,
+			Status:      asnLineStatus(l.ExpectedQty, qty),
+		}
+		out.ExpectedQty += l.ExpectedQty
+		out.ReceivedQty += qty
+	}
+	for _, s := range received {
+		if _, ok := last[strings.ToLower(strings.TrimSpace(s.Sku))]; !ok && s.ReceivedQty != 0 {
+			out.Unexpected = append(out.Unexpected, s)
+			out.ReceivedQty += s.ReceivedQty
+		}
+	}
+	out.Variance = out.ReceivedQty - out.ExpectedQty
+
+	// Complete only when every line is; over when nothing is short but
+	// something came in beyond the ASN
+	short, over := false, len(out.Unexpected) > 0
+	for _, l := range out.Lines {
+		short = short || l.Status == AsnPending || l.Status == AsnPartial
+		over = over || l.Status == AsnOver
+	}
+	switch {
+	case out.ReceivedQty == 0:
+		out.Status = AsnPending
+	case short:
+		out.Status = AsnPartial
+	case over:
+		out.Status = AsnOver
+	default:
+		out.Status = AsnComplete
+	}
+	return out
+}
+
+// loadReceiving builds the receiving view of the given ASNs on a receipt. It
+// writes the error response itself and returns false when that fails.
+func loadReceiving(c *gin.Context, asns []models.Asn, receiptNo string) (AsnReceiving, bool) {
+	db := warehouseDB(c)
+	ids := make([]uint, len(asns))
+	for i, a := range asns {
+		ids[i] = a.ID
+	}
+	var lines []models.AsnLine
+	if err := db.Where("asn_id IN ?", ids).Order("asn_id, id").Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return AsnReceiving{}, false
+	}
+	received := []ReconSku{}
+	if key := receiptKey(receiptNo); key != "" {
+		var rows []receiptSkuRow
+		if err := receiptSkusQuery(db).Where("LOWER(TRIM(receipt_no)) = ?", key).Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return AsnReceiving{}, false
+		}
+		for _, r := range rows {
+			received = append(received, ReconSku{Sku: r.Sku, ReceivedQty: r.Qty})
+		}
+	}
+	out := matchReceiving(asns, lines, received)
+	out.ReceiptNo = strings.TrimSpace(receiptNo)
+	return out, true
+}
+
+// Receiving serves GET /asns/:id/receiving: the expected vs received view of
+// one ASN. It reads the receipt of the attached arrival when there is one,
+// so a receipt_no filled in on the arrival later is picked up.
+func (h *AsnHandler) Receiving(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	db := warehouseDB(c)
+	var asn models.Asn
+	if err := db.First(&asn, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	receiptNo := asn.ReceiptNo
+	if asn.ArrivalID != nil {
+		var arrival models.Arrival
+		res := db.Where("id = ?", *asn.ArrivalID).Limit(1).Find(&arrival)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+			return
+		}
+		if res.RowsAffected > 0 && strings.TrimSpace(arrival.ReceiptNo) != "" {
+			receiptNo = arrival.ReceiptNo
+		}
+	}
+	out, ok := loadReceiving(c, []models.Asn{asn}, receiptNo)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ReceivingByReceipt serves GET /asns/receiving?receipt_no=: the expected vs
+// received view of every ASN on a receipt, directly or through its arrival
+func (h *AsnHandler) ReceivingByReceipt(c *gin.Context) {
+	receiptNo := strings.TrimSpace(c.Query("receipt_no"))
+	if receiptNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "receipt_no is required"})
+		return
+	}
+	db := warehouseDB(c)
+	key := receiptKey(receiptNo)
+	arrivals := db.Session(&gorm.Session{NewDB: true}).Model(&models.Arrival{}).
+		Select("id").Where("LOWER(TRIM(receipt_no)) = ?", key)
+	var asns []models.Asn
+	if err := db.Where("LOWER(TRIM(receipt_no)) = ? OR arrival_id IN (?)", key, arrivals).Order("id").Find(&asns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(asns) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No ASN found for receipt " + receiptNo})
+		return
+	}
+	out, ok := loadReceiving(c, asns, receiptNo)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteAsnDeletesLines(t *testing.T) {
+	db := openTestDB(t, &models.Asn{}, &models.AsnLine{}, &models.AuditLog{})
+	h := NewAsnHandler(NewResource[models.AsnLine]("asn-lines"))
+	asns := []models.Asn{{AsnNo: "ASN-1"}, {AsnNo: "ASN-2"}, {AsnNo: "ASN-3"}}
+	if err := db.Create(&asns).Error; err != nil {
+		t.Fatal(err)
+	}
+	var lines []models.AsnLine
+	for _, asn := range asns {
+		lines = append(lines, models.AsnLine{AsnID: asn.ID, Sku: "A"}, models.AsnLine{AsnID: asn.ID, Sku: "B"})
+	}
+	if err := db.Create(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	liveLines := func() map[uint]int {
+		var rows []models.AsnLine
+		db.Find(&rows)
+		out := map[uint]int{}
+		for _, r := range rows {
+			out[r.AsnID]++
+		}
+		return out
+	}
+
+	if w := callHandler(t, h.asns.Delete, gin.Params{{Key: "id", Value: "1"}}, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	if got := liveLines(); got[1] != 0 || got[2] != 2 || got[3] != 2 {
+		t.Errorf("live lines per ASN after deleting #1: %v", got)
+	}
+	if w := callHandler(t, h.asns.BulkDelete, nil, gin.H{"ids": []uint{2, 3}}); w.Code != http.StatusOK {
+		t.Fatalf("bulk delete: %d %s", w.Code, w.Body)
+	}
+	if got := liveLines(); len(got) != 0 {
+		t.Errorf("live lines after deleting every ASN: %v", got)
+	}
+
+	// The lines went to the trash with an audit entry each
+	var trashed, audited int64
+	db.Unscoped().Model(&models.AsnLine{}).Where("deleted_at IS NOT NULL").Count(&trashed)
+	db.Model(&models.AuditLog{}).Where("resource = ? AND action = ?", "asn-lines", AuditDelete).Count(&audited)
+	if trashed != 6 || audited != 6 {
+		t.Errorf("%d lines in the trash and %d audited, want 6 and 6", trashed, audited)
+	}
+}
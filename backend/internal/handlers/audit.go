@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Audit log actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditSync    = "sync"
+)
+
+// AuditEntry is an audit log row with its changes decoded for the response
+type AuditEntry struct {
+	models.AuditLog
+	Changes json.RawMessage `json:"changes"`
+}
+
+// recordID returns the ID of a record
+func recordID(item interface{}) uint {
+	return uint(reflect.Indirect(reflect.ValueOf(item)).FieldByName("ID").Uint())
+}
+
+// auditDiff lists the fields that differ between two versions of a record.
+// A nil before or after stands for "no record", so a create lists every
+// filled field as new and a delete lists every filled field as old.
+func (h *ResourceHandler[T]) auditDiff(before, after *T) map[string]FieldChange {
+	cols := getExportColumns(reflect.TypeOf(new(T)).Elem())
+	oldRow := make([]string, len(cols))
+	newRow := make([]string, len(cols))
+	if before != nil {
+		oldRow = exportRow(before, cols)
+	}
+	if after != nil {
+		newRow = exportRow(after, cols)
+	}
+	changes := make(map[string]FieldChange)
+	for i, col := range cols {
+		if diffIgnoredFields[col.Header] || oldRow[i] == newRow[i] {
+			continue
+		}
+		changes[col.Header] = FieldChange{Old: oldRow[i], New: newRow[i]}
+	}
+	return changes
+}
+
+// newAuditLog builds an audit row for this resource
+func (h *ResourceHandler[T]) newAuditLog(action, actor string, id uint, changes map[string]FieldChange) models.AuditLog {
+	if changes == nil {
+		changes = map[string]FieldChange{}
+	}
+	data, _ := json.Marshal(changes)
+	return models.AuditLog{
+		Resource: h.Name,
+		RecordID: id,
+		Action:   action,
+		Actor:    actor,
+		Changes:  string(data),
+	}
+}
+
+// auditRecord builds the audit row for a change from before to after
+func (h *ResourceHandler[T]) auditRecord(action, actor string, before, after *T) models.AuditLog {
+	item := after
+	if item == nil {
+		item = before
+	}
+	return h.newAuditLog(action, actor, recordID(item), h.auditDiff(before, after))
+}
+
+// writeAudit stores audit rows in batches of 500 inside the given transaction
+func writeAudit(tx *gorm.DB, entries ...models.AuditLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&entries, 500).Error
+}
+
+// toAuditEntries decodes the stored changes of each row
+func toAuditEntries(logs []models.AuditLog) []AuditEntry {
+	out := make([]AuditEntry, len(logs))
+	for i, l := range logs {
+		out[i] = AuditEntry{AuditLog: l, Changes: json.RawMessage(l.Changes)}
+		if l.Changes == "" {
+			out[i].Changes = json.RawMessage("{}")
+		}
+	}
+	return out
+}
+
+// History returns the audit trail of one record, newest first
+func (h *ResourceHandler[T]) History(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var logs []models.AuditLog
+	if err := warehouseDB(c).Where("resource = ? AND record_id = ?", h.Name, id).
+		Order("id DESC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toAuditEntries(logs))
+}
+
+// ListAuditLogs returns the global audit log, newest first (supervisor/leader only).
+// Filters: resource, record_id, warehouse, actor, action, from and to
+// (YYYY-MM-DD, inclusive).
+// Paginated with page/pageSize; page defaults to 1.
+func ListAuditLogs(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+
+	query := database.DB.Model(&models.AuditLog{})
+	for _, field := range []string{"resource", "warehouse", "actor", "action"} {
+		if v := c.Query(field); v != "" {
+			query = query.Where(field+" = ?", v)
+		}
+	}
+	if v := c.Query("record_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "record_id must be a number"})
+			return
+		}
+		query = query.Where("record_id = ?", id)
+	}
+	if v := c.Query("from"); v != "" {
+		from := models.ParseFlexDate(v)
+		if !from.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date"})
+			return
+		}
+		query = query.Where("created_at >= ?", from.Time)
+	}
+	if v := c.Query("to"); v != "" {
+		to := models.ParseFlexDate(v)
+		if !to.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date"})
+			return
+		}
+		query = query.Where("created_at < ?", to.Time.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize := pageSizeParam(c)
+
+	var logs []models.AuditLog
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": toAuditEntries(logs), "total": total})
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"warehouse-report-monitoring/internal/database"
 	"warehouse-report-monitoring/internal/middleware"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type LoginRequest struct {
@@ -17,10 +20,18 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token    string `json:"token"`
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	// MustChangePassword means every route except /auth/me and the password
+	// change is refused until the password is changed
+	MustChangePassword bool `json:"must_change_password"`
+	// MFASetupRequired means the role requires 2FA and the user has not
+	// enrolled; every route except enrollment is refused until they do
+	MFASetupRequired bool `json:"mfa_setup_required"`
 }
 
 func Login(c *gin.Context) {
@@ -36,29 +47,70 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		c.JSON(http.StatusLocked, gin.H{
+			"error":       "Akun terkunci karena terlalu banyak percobaan login gagal. Silakan coba lagi nanti.",
+			"retry_after": int(time.Until(*user.LockedUntil).Seconds()),
+		})
+		return
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		recordFailedLogin(user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Username atau password salah"})
 		return
 	}
+	if user.FailedAttempts > 0 || user.LockedUntil != nil {
+		database.DB.Model(&user).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil})
+	}
+
+	// With 2FA on, the password only earns a short-lived token for LoginTwoFactor
+	if user.TOTPEnabled {
+		mfaToken, err := middleware.GenerateMFAToken(user.ID, user.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
+	// Drop this user's expired refresh tokens while we are here
+	database.DB.Where("user_id = ? AND expires_at < ?", user.ID, time.Now()).Delete(&models.RefreshToken{})
+
+	resp, _, err := issueSession(c, database.DB, user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{
-		Token:    token,
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-	})
+	c.JSON(http.StatusOK, resp)
+}
+
+// recordFailedLogin counts a failed login and locks the account for
+// LOGIN_LOCKOUT_MINUTES once LOGIN_MAX_FAILURES is reached
+func recordFailedLogin(user models.User) {
+	policy := currentPasswordPolicy()
+	database.DB.Model(&user).Update("failed_attempts", gorm.Expr("failed_attempts + 1"))
+	if policy.MaxFailures == 0 {
+		return
+	}
+	database.DB.Select("failed_attempts").First(&user, user.ID)
+	if user.FailedAttempts >= policy.MaxFailures {
+		until := time.Now().Add(policy.Lockout)
+		database.DB.Model(&user).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": until})
+		log.Printf("[AUTH] Account %s locked until %s after %d failed logins", user.Username, until.Format(time.RFC3339), policy.MaxFailures)
+	}
 }
 
 func GetCurrentUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"user_id":  c.GetUint("user_id"),
-		"username": c.GetString("username"),
-		"role":     c.GetString("role"),
+		"user_id":              c.GetUint("user_id"),
+		"username":             c.GetString("username"),
+		"role":                 c.GetString("role"),
+		"permissions":          middleware.PermissionsOf(c.GetString("role")),
+		"must_change_password": c.GetBool("must_change_password"),
+		"mfa_setup_required":   c.GetBool("mfa_setup_required"),
+		"warehouses":           middleware.AllowedWarehouses(c),
 	})
 }
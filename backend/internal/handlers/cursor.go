@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// errInvalidCursor is returned when a cursor token cannot be decoded or was
+// issued for a different sort order
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the decoded form of an opaque next_cursor token. It holds
+// the sort signature and the ORDER BY values of the last row of a page; a
+// nil value stands for NULL.
+type cursorToken struct {
+	Sort   string    `json:"s"`
+	Values []*string `json:"v"`
+}
+
+// sortSignature identifies an ORDER BY so a cursor cannot be replayed
+// against a different sort
+func sortSignature(terms []orderTerm) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t.Col.Column
+		if t.EmptyFlag {
+			parts[i] = "!" + parts[i]
+		}
+		if t.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// fieldByJSON returns the struct field tagged with the given json name
+func fieldByJSON(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// encodeCursor builds the next_cursor token from the last row of a page
+func encodeCursor(item interface{}, terms []orderTerm) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	token := cursorToken{Sort: sortSignature(terms)}
+	for _, t := range terms {
+		f, ok := fieldByJSON(v, t.Col.JSON)
+		if !ok {
+			return "", fmt.Errorf("cursor: unknown field %s", t.Col.JSON)
+		}
+		var value *string
+		if f.Kind() != reflect.Ptr || !f.IsNil() {
+			var s string
+			switch val := reflect.Indirect(f).Interface().(type) {
+			case models.FlexDate:
+				s = val.String()
+			case time.Time:
+				s = val.Format(time.RFC3339Nano)
+			default:
+				s = fmt.Sprint(val)
+			}
+			value = &s
+		} else if t.Col.Kind == kindFlexDate {
+			// Sorted as COALESCE(col, ''), so a NULL date is an empty one
+			empty := ""
+			value = &empty
+		}
+		if t.EmptyFlag {
+			flag := "0"
+			if value == nil || *value == "" {
+				flag = "1"
+			}
+			value = &flag
+		}
+		token.Values = append(token.Values, value)
+	}
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor parses a cursor token into typed values for each order term
+func decodeCursor(s string, terms []orderTerm) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var token cursorToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, errInvalidCursor
+	}
+	if token.Sort != sortSignature(terms) || len(token.Values) != len(terms) {
+		return nil, errInvalidCursor
+	}
+	values := make([]interface{}, len(terms))
+	for i, t := range terms {
+		if token.Values[i] == nil {
+			if t.EmptyFlag || !t.Col.Nullable {
+				return nil, errInvalidCursor
+			}
+			continue
+		}
+		v := *token.Values[i]
+		var parsed interface{}
+		switch {
+		case t.EmptyFlag:
+			parsed, err = strconv.Atoi(v)
+		case t.Col.Kind == kindInt:
+			parsed, err = strconv.ParseInt(v, 10, 64)
+		case t.Col.Kind == kindFloat:
+			parsed, err = strconv.ParseFloat(v, 64)
+		case t.Col.Kind == kindBool:
+			parsed, err = strconv.ParseBool(v)
+		case t.Col.Kind == kindTime:
+			parsed, err = time.Parse(time.RFC3339Nano, v)
+		default:
+			parsed = v
+		}
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		values[i] = parsed
+	}
+	return values, nil
+}
+
+// applyCursor restricts the query to rows that sort strictly after the cursor,
+// expanding the row comparison so mixed ASC/DESC directions are supported:
+// (t1 > v1) OR (t1 = v1 AND t2 > v2) OR ... A NULL cursor value matches
+// with IS NULL; no row sorts after it on that term, since the NULLs of a
+// column come last and tie with each other, so its branch is left out.
+func applyCursor(query *gorm.DB, terms []orderTerm, values []interface{}) *gorm.DB {
+	var ors []string
+	var args []interface{}
+	for i, t := range terms {
+		if values[i] == nil {
+			continue
+		}
+		var ands []string
+		var branchArgs []interface{}
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				ands = append(ands, terms[j].Expr+" IS NULL")
+				continue
+			}
+			ands = append(ands, terms[j].Expr+" = ?")
+			branchArgs = append(branchArgs, values[j])
+		}
+		op := ">"
+		if t.Desc {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s ?", t.Expr, op))
+		args = append(append(args, branchArgs...), values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return query.Where("("+strings.Join(ors, " OR ")+")", args...)
+}
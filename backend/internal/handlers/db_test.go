@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+
+	"warehouse-report-monitoring/internal/database"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB installs a fresh in-memory database with the warehouse scope
+// as database.DB and migrates the given models into it
+func openTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RegisterWarehouseScope(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	return db
+}
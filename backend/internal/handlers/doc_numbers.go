@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultDocNumberFormat numbers Berita Acara documents without a format of
+// their own, e.g. 1026-0001/WH-JC/2026
+const defaultDocNumberFormat = "{MM}{YY}-{SEQ:4}/{WH}/{YYYY}"
+
+// inventoryDocNumberFormat numbers the inventory Berita Acara documents,
+// e.g. INV-1026-0001/WH-JC/2026
+const inventoryDocNumberFormat = "INV-{MM}{YY}-{SEQ:4}/{WH}/{YYYY}"
+
+// builtinDocNumberFormats are the formats used when a warehouse has not set
+// up a DocNumberFormat for the document type
+var builtinDocNumberFormats = map[string]string{
+	"Stock Opname":           inventoryDocNumberFormat,
+	"Disposal":               inventoryDocNumberFormat,
+	"Adjustment":             inventoryDocNumberFormat,
+	"Transfer Barang Damage": inventoryDocNumberFormat,
+}
+
+var (
+	docNumberToken = regexp.MustCompile(`\{([A-Z]+)(?::(\d+))?\}`)
+	romanMonths    = [...]string{"I", "II", "III", "IV", "V", "VI", "VII", "VIII", "IX", "X", "XI", "XII"}
+)
+
+// formatDocNumber fills in the placeholders of a document number format for
+// a warehouse, month and sequence number. Unknown placeholders are kept as
+// they are.
+func formatDocNumber(format, warehouse string, month time.Time, seq int) string {
+	return docNumberToken.ReplaceAllStringFunc(format, func(token string) string {
+		m := docNumberToken.FindStringSubmatch(token)
+		switch m[1] {
+		case "YYYY":
+			return month.Format("2006")
+		case "YY":
+			return month.Format("06")
+		case "MM":
+			return month.Format("01")
+		case "ROMAN":
+			return romanMonths[month.Month()-1]
+		case "WH":
+			return warehouse
+		case "SEQ":
+			width := 4
+			if m[2] != "" {
+				width, _ = strconv.Atoi(m[2])
+			}
+			return fmt.Sprintf("%0*d", width, seq)
+		}
+		return token
+	})
+}
+
+// DocNumberFormatRule checks that a format numbers each document differently
+func DocNumberFormatRule(f *models.DocNumberFormat) []FieldError {
+	for _, m := range docNumberToken.FindAllStringSubmatch(f.Format, -1) {
+		if m[1] == "SEQ" {
+			return nil
+		}
+	}
+	return []FieldError{{Field: "format", Rule: "sequence", Message: "format must contain {SEQ} or {SEQ:n}"}}
+}
+
+// docNumberFormat returns the format for a document type in the warehouse
+// of tx
+func docNumberFormat(tx *gorm.DB, docType string) (string, error) {
+	var formats []models.DocNumberFormat
+	if err := tx.Where("doc_type = ?", docType).Order("id DESC").Limit(1).Find(&formats).Error; err != nil {
+		return "", err
+	}
+	if len(formats) > 0 && strings.TrimSpace(formats[0].Format) != "" {
+		return formats[0].Format, nil
+	}
+	if format, ok := builtinDocNumberFormats[docType]; ok {
+		return format, nil
+	}
+	return defaultDocNumberFormat, nil
+}
+
+// nextDocNumber hands out the next document number of a type in a warehouse
+// and month. Sequences are kept per number format rather than per type, so
+// types sharing a format (such as the default one) never produce the same
+// number. The sequence row stays locked until tx ends, so concurrent creates
+// that could produce the same number queue up instead. Numbers already taken,
+// by older documents or soft-deleted ones, are skipped.
+func nextDocNumber(tx *gorm.DB, docType, warehouse string, month time.Time) (string, error) {
+	format, err := docNumberFormat(tx, docType)
+	if err != nil {
+		return "", err
+	}
+	key := models.DocNumberSequence{Format: format, Warehouse: warehouse, Period: month.Format("2006-01")}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&key).Error; err != nil {
+		return "", err
+	}
+	where := tx.Where("format = ? AND warehouse = ? AND period = ?", key.Format, key.Warehouse, key.Period)
+	if err := where.Session(&gorm.Session{}).Model(&models.DocNumberSequence{}).
+		UpdateColumn("last_seq", gorm.Expr("last_seq + 1")).Error; err != nil {
+		return "", err
+	}
+	var seq models.DocNumberSequence
+	if err := where.Session(&gorm.Session{}).Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq).Error; err != nil {
+		return "", err
+	}
+
+	n := seq.LastSeq
+	for {
+		number := formatDocNumber(format, warehouse, month, n)
+		var taken int64
+		if err := tx.Unscoped().Model(&models.BeritaAcara{}).
+			Where("warehouse = ? AND doc_number = ?", warehouse, number).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			if n != seq.LastSeq {
+				if err := tx.Model(&seq).UpdateColumn("last_seq", n).Error; err != nil {
+					return "", err
+				}
+			}
+			return number, nil
+		}
+		n++
+	}
+}
+
+// AssignDocNumber is the create hook of Berita Acara: it numbers the document
+// in the month of its date, from the sequence of its format and warehouse
+func AssignDocNumber(tx *gorm.DB, doc *models.BeritaAcara) error {
+	warehouse := database.WarehouseFrom(tx.Statement.Context)
+	if warehouse == "" {
+		warehouse = strings.TrimSpace(doc.Warehouse)
+	}
+	if warehouse == "" {
+		warehouse = database.DefaultWarehouse
+	}
+	doc.Warehouse = warehouse
+	month := wallClockNow()
+	if doc.Date.Valid {
+		month = doc.Date.Time
+	}
+	number, err := nextDocNumber(tx, strings.TrimSpace(doc.DocType), warehouse, month)
+	if err != nil {
+		return err
+	}
+	doc.DocNumber = number
+	return nil
+}
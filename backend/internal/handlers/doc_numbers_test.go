@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+)
+
+func TestFormatDocNumber(t *testing.T) {
+	oct := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		seq    int
+		want   string
+	}{
+		{defaultDocNumberFormat, 1, "1026-0001/WH-JC/2026"},
+		{inventoryDocNumberFormat, 42, "INV-1026-0042/WH-JC/2026"},
+		{"BA/{ROMAN}/{YYYY}/{SEQ:3}", 7, "BA/X/2026/007"},
+		{"{SEQ}", 12345, "12345"},
+		{"{SEQ:2}-{WH}", 3, "03-WH-JC"},
+		{"{FOO}-{SEQ:1}", 9, "{FOO}-9"},
+		{"plain", 1, "plain"},
+	}
+	for _, tt := range tests {
+		if got := formatDocNumber(tt.format, "WH-JC", oct, tt.seq); got != tt.want {
+			t.Errorf("formatDocNumber(%q, %d) = %q, want %q", tt.format, tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestNextDocNumber(t *testing.T) {
+	db := openTestDB(t, &models.BeritaAcara{}, &models.DocNumberFormat{}, &models.DocNumberSequence{})
+	oct := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	nov := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.Create(&models.DocNumberFormat{DocType: "Retur", Format: "BA/{ROMAN}/{YYYY}/{SEQ:3}", Warehouse: "WH-JC"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	taken := []models.BeritaAcara{
+		{DocType: "Damage", DocNumber: "1026-0002/WH-JC/2026", Date: models.ParseFlexDate("2026-10-01"), Warehouse: "WH-JC"},
+		{DocType: "Kehilangan", DocNumber: "1026-0004/WH-JC/2026", Date: models.ParseFlexDate("2026-10-01"), Warehouse: "WH-JC"},
+	}
+	if err := db.Create(&taken).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&taken[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		docType   string
+		warehouse string
+		month     time.Time
+		want      string
+	}{
+		{"Damage", "WH-JC", oct, "1026-0001/WH-JC/2026"},
+		{"Kehilangan", "WH-JC", oct, "1026-0003/WH-JC/2026"}, // shares the default series, skips the deleted 0002
+		{"Damage", "WH-JC", oct, "1026-0005/WH-JC/2026"},     // skips the live 0004
+		{"Stock Opname", "WH-JC", oct, "INV-1026-0001/WH-JC/2026"},
+		{"Disposal", "WH-JC", oct, "INV-1026-0002/WH-JC/2026"},
+		{"Retur", "WH-JC", oct, "BA/X/2026/001"},
+		{"Damage", "WH-B", oct, "1026-0001/WH-B/2026"},
+		{"Damage", "WH-JC", nov, "1126-0001/WH-JC/2026"},
+		{"Kehilangan", "WH-JC", oct, "1026-0006/WH-JC/2026"},
+	}
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		got, err := nextDocNumber(db, tt.docType, tt.warehouse, tt.month)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("nextDocNumber(%q, %q, %s) = %q, want %q", tt.docType, tt.warehouse, tt.month.Format("2006-01"), got, tt.want)
+		}
+		if seen[tt.warehouse+" "+got] {
+			t.Errorf("%s handed out twice in %s", got, tt.warehouse)
+		}
+		seen[tt.warehouse+" "+got] = true
+	}
+}
+
+func TestDocNumbersOnImport(t *testing.T) {
+	db := openTestDB(t, &models.BeritaAcara{}, &models.DocNumberFormat{}, &models.DocNumberSequence{},
+		&models.AuditLog{}, &models.SyncSnapshot{})
+	db = db.WithContext(database.WithWarehouse(context.Background(), "WH-JC"))
+	h := NewResource[models.BeritaAcara]("berita-acara").WithProtectedFields("doc_number").WithCreateHook(AssignDocNumber)
+	doc := func(id uint, docType string) models.BeritaAcara {
+		return models.BeritaAcara{ID: id, DocType: docType, DocNumber: "CLIENT-1", Date: models.ParseFlexDate("2026-10-05")}
+	}
+	numbers := func(t *testing.T) map[uint]string {
+		t.Helper()
+		var docs []models.BeritaAcara
+		if err := db.Order("id").Find(&docs).Error; err != nil {
+			t.Fatal(err)
+		}
+		byID := map[uint]string{}
+		for _, d := range docs {
+			byID[d.ID] = d.DocNumber
+		}
+		return byID
+	}
+
+	if _, err := h.importBatches(db, []models.BeritaAcara{doc(0, "Damage"), doc(0, "Kehilangan"), doc(0, "Disposal")}, ImportInsertOnly, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]string{1: "1026-0001/WH-JC/2026", 2: "1026-0002/WH-JC/2026", 3: "INV-1026-0001/WH-JC/2026"}
+	if got := numbers(t); len(got) != len(want) || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Fatalf("imported numbers %v, want %v", got, want)
+	}
+
+	// A sync keeps the numbers of the documents it replaces and numbers the new ones
+	if _, err := h.replaceAll(db, []models.BeritaAcara{doc(2, "Kehilangan"), doc(0, "Damage")}, "tester", false); err != nil {
+		t.Fatal(err)
+	}
+	got := numbers(t)
+	if len(got) != 2 || got[2] != "1026-0002/WH-JC/2026" {
+		t.Fatalf("synced numbers %v, want document 2 to keep its number", got)
+	}
+	for id, n := range got {
+		if id != 2 && n != "1026-0003/WH-JC/2026" {
+			t.Errorf("new document %d numbered %q, want 1026-0003/WH-JC/2026", id, n)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dock appointment statuses. No-show and late are derived from the booked
+// slot and the check-in time, see Report.
+const (
+	AppointmentBooked    = "Booked"
+	AppointmentCheckedIn = "Checked In"
+	AppointmentCancelled = "Cancelled"
+)
+
+// activeAppointmentStatuses are the statuses that take up slot capacity
+var activeAppointmentStatuses = []string{AppointmentBooked, AppointmentCheckedIn}
+
+// defaultGraceMinutes is how late a check-in may be before it counts as late
+const defaultGraceMinutes = 15
+
+// statusError is a request that cannot be carried out, answered with status
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string { return e.message }
+
+func statusFailed(status int, format string, args ...interface{}) error {
+	return &statusError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// DockAppointmentHandler serves dock appointments. Reads go through the
+// generic resource; writes go through booking so slot capacity and PO
+// double-booking are always checked.
+type DockAppointmentHandler struct {
+	appointments *ResourceHandler[models.DockAppointment]
+	arrivals     *ResourceHandler[models.Arrival]
+}
+
+// NewDockAppointmentHandler creates the handler; check-in creates or links
+// records of the given arrivals resource
+func NewDockAppointmentHandler(arrivals *ResourceHandler[models.Arrival]) *DockAppointmentHandler {
+	return &DockAppointmentHandler{
+		appointments: NewResource[models.DockAppointment]("dock-appointments"),
+		arrivals:     arrivals,
+	}
+}
+
+// RegisterRoutes registers the dock appointment routes
+func (h *DockAppointmentHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	registerTrash(h.appointments)
+	can := func(action string) gin.HandlerFunc {
+		return middleware.RequirePermission(h.appointments.Name, action)
+	}
+	rg.GET("", can(middleware.ActionList), h.appointments.List)
+	rg.GET("/export", can(middleware.ActionList), h.appointments.Export)
+	rg.GET("/availability", can(middleware.ActionList), h.Availability)
+	rg.GET("/report", can(middleware.ActionList), h.Report)
+	rg.GET("/:id", can(middleware.ActionGet), h.appointments.Get)
+	rg.GET("/:id/history", can(middleware.ActionGet), h.appointments.History)
+	rg.POST("", can(middleware.ActionCreate), h.Book)
+	rg.PUT("/:id", can(middleware.ActionUpdate), h.Reschedule)
+	rg.POST("/:id/cancel", can(middleware.ActionUpdate), h.Cancel)
+	rg.POST("/:id/check-in", can(middleware.ActionUpdate), h.CheckIn)
+	rg.DELETE("/:id", can(middleware.ActionDelete), h.appointments.Delete)
+	rg.GET("/trash", can(middleware.ActionDelete), h.appointments.ListTrash)
+	rg.POST("/:id/restore", can(middleware.ActionDelete), h.appointments.Restore)
+}
+
+// slotTime returns the time of an HH:MM slot boundary on a date
+func slotTime(date models.FlexDate, clock string) time.Time {
+	minutes, _ := parseClock(clock)
+	d := date.Time
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, minutes, 0, 0, time.UTC)
+}
+
+// onDay limits a query to the rows dated on the day of date, including any
+// stored with a time of day
+func onDay(query *gorm.DB, date models.FlexDate) *gorm.DB {
+	day := date.Time.Format("2006-01-02")
+	return query.Where("date >= ? AND date <= ?", day, day+" 23:59:59")
+}
+
+// splitPoNumbers splits a comma-separated PO list, dropping blanks and duplicates
+func splitPoNumbers(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, po := range strings.Split(s, ",") {
+		po = strings.TrimSpace(po)
+		if po == "" || seen[strings.ToLower(po)] {
+			continue
+		}
+		seen[strings.ToLower(po)] = true
+		out = append(out, po)
+	}
+	return out
+}
+
+// bookSlot checks that the appointment's slot exists on an active dock, has
+// capacity left on its date, and that none of its POs is booked elsewhere,
+// then copies the slot onto the appointment. The date is cut to the day, as
+// slots repeat daily. The slot row stays locked until tx ends so concurrent
+// bookings cannot overfill it.
+func bookSlot(tx *gorm.DB, appt *models.DockAppointment) error {
+	if appt.Date.Valid {
+		appt.Date = models.FlexDate{Time: slotTime(appt.Date, "00:00"), Valid: true}
+	}
+	var slot models.DockSlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, appt.SlotID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return statusFailed(http.StatusBadRequest, "Unknown slot %d", appt.SlotID)
+		}
+		return err
+	}
+	var dock models.Dock
+	if err := tx.Where("code = ?", slot.DockCode).First(&dock).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return statusFailed(http.StatusBadRequest, "Unknown dock %s", slot.DockCode)
+		}
+		return err
+	}
+	if !dock.IsActive {
+		return statusFailed(http.StatusConflict, "Dock %s is not active", dock.Code)
+	}
+
+	var booked int64
+	if err := onDay(tx.Model(&models.DockAppointment{}), appt.Date).
+		Where("slot_id = ? AND status IN ? AND id <> ?", slot.ID, activeAppointmentStatuses, appt.ID).
+		Count(&booked).Error; err != nil {
+		return err
+	}
+	if int(booked) >= slot.Capacity {
+		return statusFailed(http.StatusConflict, "Slot %s %s-%s on %s is full (%d of %d booked)",
+			slot.DockCode, slot.StartTime, slot.EndTime, appt.Date.String(), booked, slot.Capacity)
+	}
+
+	if pos := splitPoNumbers(appt.PoNo); len(pos) > 0 {
+		// Only load the appointments whose PO list mentions one of ours
+		likes := make([]string, len(pos))
+		args := make([]interface{}, len(pos))
+		for i, po := range pos {
+			likes[i] = "LOWER(po_no) LIKE ?"
+			args[i] = "%" + strings.ToLower(po) + "%"
+		}
+		var open []models.DockAppointment
+		if err := tx.Where("status = ? AND id <> ?", AppointmentBooked, appt.ID).
+			Where(strings.Join(likes, " OR "), args...).Find(&open).Error; err != nil {
+			return err
+		}
+		for _, other := range open {
+			for _, theirs := range splitPoNumbers(other.PoNo) {
+				for _, po := range pos {
+					if strings.EqualFold(po, theirs) {
+						return statusFailed(http.StatusConflict, "PO %s is already booked on appointment #%d (%s %s %s)",
+							po, other.ID, other.Date.String(), other.DockCode, other.SlotStart)
+					}
+				}
+			}
+		}
+		appt.PoNo = strings.Join(pos, ", ")
+	}
+
+	appt.DockCode = slot.DockCode
+	appt.SlotStart = slot.StartTime
+	appt.SlotEnd = slot.EndTime
+	return nil
+}
+
+// respondStatusError writes the response for an error from a write transaction
+func respondStatusError(c *gin.Context, err error) {
+	var be *statusError
+	switch {
+	case errors.As(err, &be):
+		c.JSON(be.status, gin.H{"error": be.message})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// Book creates an appointment in a free slot
+func (h *DockAppointmentHandler) Book(c *gin.Context) {
+	var appt models.DockAppointment
+	if err := injectUpdatedBy(c, &appt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errs := h.appointments.validate(&appt); len(errs) > 0 {
+		respondValidationError(c, errs)
+		return
+	}
+	// Check-in state is only ever set by CheckIn
+	appt.ID = 0
+	appt.Status = AppointmentBooked
+	appt.CheckInTime = models.FlexDate{}
+	appt.ArrivalID = nil
+
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := bookSlot(tx, &appt); err != nil {
+			return err
+		}
+		if err := tx.Create(&appt).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.appointments.auditRecord(AuditCreate, c.GetString("username"), nil, &appt))
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.Header("ETag", etagOf(&appt))
+	c.JSON(http.StatusCreated, appt)
+}
+
+// lockAppointment loads an appointment for update and checks it is still booked
+func lockAppointment(tx *gorm.DB, id uint64) (models.DockAppointment, error) {
+	var appt models.DockAppointment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appt, id).Error; err != nil {
+		return appt, err
+	}
+	if appt.Status != AppointmentBooked {
+		return appt, statusFailed(http.StatusConflict, "Appointment is %s, only booked appointments can be changed", appt.Status)
+	}
+	return appt, nil
+}
+
+// Reschedule moves a booked appointment to another slot or date, or changes
+// its delivery details
+func (h *DockAppointmentHandler) Reschedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	body, _, err := readBodyWithUpdatedBy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var appt models.DockAppointment
+	var verrs []FieldError
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if appt, err = lockAppointment(tx, id); err != nil {
+			return err
+		}
+		before := appt
+		if err := json.Unmarshal(body, &appt); err != nil {
+			return statusFailed(http.StatusBadRequest, "%s", err.Error())
+		}
+		appt.ID, appt.CreatedAt = before.ID, before.CreatedAt
+		appt.Status, appt.CheckInTime, appt.ArrivalID = before.Status, before.CheckInTime, before.ArrivalID
+		if verrs = h.appointments.validate(&appt); len(verrs) > 0 {
+			return errValidation
+		}
+		if err := bookSlot(tx, &appt); err != nil {
+			return err
+		}
+		if err := tx.Save(&appt).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.appointments.auditRecord(AuditUpdate, c.GetString("username"), &before, &appt))
+	})
+	if errors.Is(err, errValidation) {
+		respondValidationError(c, verrs)
+		return
+	}
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.Header("ETag", etagOf(&appt))
+	c.JSON(http.StatusOK, appt)
+}
+
+// Cancel releases the slot of a booked appointment
+func (h *DockAppointmentHandler) Cancel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var appt models.DockAppointment
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if appt, err = lockAppointment(tx, id); err != nil {
+			return err
+		}
+		before := appt
+		appt.Status = AppointmentCancelled
+		appt.UpdatedBy = c.GetString("username")
+		if err := tx.Save(&appt).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.appointments.auditRecord(AuditUpdate, c.GetString("username"), &before, &appt))
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, appt)
+}
+
+// CheckInRequest is the body of a check-in. ArrivalID links an existing
+// arrival; without it the arrival is looked up by PO number and date, and
+// created when none exists. CheckInTime defaults to now.
+type CheckInRequest struct {
+	ArrivalID   *uint  `json:"arrival_id"`
+	CheckInTime string `json:"check_in_time"`
+}
+
+// CheckIn records that the booked vehicle arrived and creates or links its Arrival
+func (h *DockAppointmentHandler) CheckIn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	checkIn := models.FlexDate{Time: wallClockNow(), Valid: true}
+	if req.CheckInTime != "" {
+		if checkIn = models.ParseFlexDate(req.CheckInTime); !checkIn.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "check_in_time must be a date and time"})
+			return
+		}
+	}
+	actor := c.GetString("username")
+
+	var appt models.DockAppointment
+	var arrival models.Arrival
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if appt, err = lockAppointment(tx, id); err != nil {
+			return err
+		}
+
+		found := false
+		if req.ArrivalID != nil {
+			if err := tx.First(&arrival, *req.ArrivalID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return statusFailed(http.StatusBadRequest, "Unknown arrival %d", *req.ArrivalID)
+				}
+				return err
+			}
+			found = true
+		} else if pos := splitPoNumbers(appt.PoNo); len(pos) > 0 {
+			for i := range pos {
+				pos[i] = strings.ToLower(pos[i])
+			}
+			res := onDay(tx, appt.Date).Where("LOWER(TRIM(po_no)) IN ?", pos).Order("id").Limit(1).Find(&arrival)
+			if res.Error != nil {
+				return res.Error
+			}
+			found = res.RowsAffected > 0
+		}
+
+		scheduled := models.FlexDate{Time: slotTime(appt.Date, appt.SlotStart), Valid: true}
+		if found {
+			before := arrival
+			if !arrival.ScheduledArrivalTime.Valid {
+				arrival.ScheduledArrivalTime = scheduled
+			}
+			if !arrival.ArrivalTime.Valid {
+				arrival.ArrivalTime = checkIn
+			}
+			if changes := h.arrivals.auditDiff(&before, &arrival); len(changes) > 0 {
+				arrival.UpdatedBy = actor
+				if err := tx.Save(&arrival).Error; err != nil {
+					return err
+				}
+				if err := writeAudit(tx, h.arrivals.newAuditLog(AuditUpdate, actor, arrival.ID, changes)); err != nil {
+					return err
+				}
+			}
+		} else {
+			arrival = models.Arrival{
+				Date:                 appt.Date,
+				ScheduledArrivalTime: scheduled,
+				ArrivalTime:          checkIn,
+				PoNo:                 appt.PoNo,
+				Supplier:             appt.Supplier,
+				Brand:                appt.Brand,
+				Note:                 appt.Note,
+				UpdatedBy:            actor,
+			}
+			if err := tx.Create(&arrival).Error; err != nil {
+				return err
+			}
+			if err := writeAudit(tx, h.arrivals.auditRecord(AuditCreate, actor, nil, &arrival)); err != nil {
+				return err
+			}
+		}
+
+		before := appt
+		appt.Status = AppointmentCheckedIn
+		appt.CheckInTime = checkIn
+		appt.ArrivalID = &arrival.ID
+		appt.UpdatedBy = actor
+		if err := tx.Save(&appt).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.appointments.auditRecord(AuditUpdate, actor, &before, &appt))
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	lateMinutes := int(checkIn.Time.Sub(slotTime(appt.Date, appt.SlotStart)) / time.Minute)
+	c.JSON(http.StatusOK, gin.H{"appointment": appt, "arrival": arrival, "late_minutes": max(lateMinutes, 0)})
+}
+
+// SlotAvailability is a dock slot with its bookings on one date
+type SlotAvailability struct {
+	models.DockSlot
+	DockName  string `json:"dock_name"`
+	Booked    int    `json:"booked"`
+	Available int    `json:"available"`
+}
+
+// Availability lists the slots of the active docks with their free capacity
+// on a date (date=YYYY-MM-DD, default today)
+func (h *DockAppointmentHandler) Availability(c *gin.Context) {
+	date := models.FlexDate{Time: wallClockNow(), Valid: true}
+	if v := c.Query("date"); v != "" {
+		if date = models.ParseFlexDate(v); !date.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date"})
+			return
+		}
+	}
+	date = models.FlexDate{Time: slotTime(date, "00:00"), Valid: true}
+
+	db := warehouseDB(c)
+	var docks []models.Dock
+	if err := db.Where("is_active = ?", true).Find(&docks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	names := make(map[string]string, len(docks))
+	codes := make([]string, 0, len(docks))
+	for _, d := range docks {
+		names[d.Code] = d.Name
+		codes = append(codes, d.Code)
+	}
+	var slots []models.DockSlot
+	if err := db.Where("dock_code IN ?", codes).Order("dock_code, start_time").Find(&slots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	type slotCount struct {
+		SlotID uint
+		Booked int
+	}
+	var counts []slotCount
+	if err := onDay(db.Model(&models.DockAppointment{}), date).
+		Select("slot_id, COUNT(*) AS booked").
+		Where("status IN ?", activeAppointmentStatuses).
+		Group("slot_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	booked := make(map[uint]int, len(counts))
+	for _, sc := range counts {
+		booked[sc.SlotID] = sc.Booked
+	}
+
+	out := make([]SlotAvailability, len(slots))
+	for i, s := range slots {
+		out[i] = SlotAvailability{DockSlot: s, DockName: names[s.DockCode], Booked: booked[s.ID]}
+		out[i].Available = max(s.Capacity-out[i].Booked, 0)
+	}
+	c.JSON(http.StatusOK, gin.H{"date": date.String(), "slots": out})
+}
+
+// AppointmentDelay is an appointment with how many minutes after its booked
+// slot the vehicle checked in, or for a no-show how long ago the slot ended
+type AppointmentDelay struct {
+	models.DockAppointment
+	Minutes int `json:"minutes"`
+}
+
+// Report summarises the appointments dated from..to (YYYY-MM-DD, default the
+// last 7 days) and lists the late arrivals and no-shows. A check-in more than
+// grace minutes (default 15) after the slot start is late; a booking still
+// not checked in grace minutes after its slot ended is a no-show.
+func (h *DockAppointmentHandler) Report(c *gin.Context) {
+	now := wallClockNow()
+	today := models.FlexDate{Time: slotTime(models.FlexDate{Time: now}, "00:00"), Valid: true}
+	from := models.FlexDate{Time: today.Time.AddDate(0, 0, -6), Valid: true}
+	to := today
+	for param, d := range map[string]*models.FlexDate{"from": &from, "to": &to} {
+		if v := c.Query(param); v != "" {
+			if *d = models.ParseFlexDate(v); !d.Valid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a date"})
+				return
+			}
+		}
+	}
+	grace := defaultGraceMinutes
+	if v := c.Query("grace"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace must be a number of minutes"})
+			return
+		}
+		grace = n
+	}
+
+	var appts []models.DockAppointment
+	if err := warehouseDB(c).Where("date >= ? AND date <= ?", from.String(), to.String()+" 23:59:59").
+		Order("date, slot_start, id").Find(&appts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary := map[string]int{"total": len(appts), "checked_in": 0, "on_time": 0, "late": 0, "no_show": 0, "cancelled": 0, "upcoming": 0}
+	late := []AppointmentDelay{}
+	noShows := []AppointmentDelay{}
+	graceTime := time.Duration(grace) * time.Minute
+	for _, a := range appts {
+		switch a.Status {
+		case AppointmentCancelled:
+			summary["cancelled"]++
+		case AppointmentCheckedIn:
+			summary["checked_in"]++
+			delay := a.CheckInTime.Time.Sub(slotTime(a.Date, a.SlotStart))
+			if a.CheckInTime.Valid && delay > graceTime {
+				summary["late"]++
+				late = append(late, AppointmentDelay{DockAppointment: a, Minutes: int(delay / time.Minute)})
+			} else {
+				summary["on_time"]++
+			}
+		default:
+			if overdue := now.Sub(slotTime(a.Date, a.SlotEnd)); overdue > graceTime {
+				summary["no_show"]++
+				noShows = append(noShows, AppointmentDelay{DockAppointment: a, Minutes: int(overdue / time.Minute)})
+			} else {
+				summary["upcoming"]++
+			}
+		}
+	}
+	sort.SliceStable(late, func(i, j int) bool { return late[i].Minutes > late[j].Minutes })
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":          from.String(),
+		"to":            to.String(),
+		"grace_minutes": grace,
+		"summary":       summary,
+		"late":          late,
+		"no_shows":      noShows,
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBookSlot(t *testing.T) {
+	db := openTestDB(t, &models.Dock{}, &models.DockSlot{}, &models.DockAppointment{})
+	day := models.ParseFlexDate
+	fixtures := []interface{}{
+		&models.Dock{Code: "D1", IsActive: true},
+		&models.Dock{Code: "D2", IsActive: true},
+		&models.DockSlot{ID: 1, DockCode: "D1", StartTime: "08:00", EndTime: "10:00", Capacity: 2},
+		&models.DockSlot{ID: 2, DockCode: "D2", StartTime: "08:00", EndTime: "10:00", Capacity: 1},
+		&models.DockSlot{ID: 3, DockCode: "D1", StartTime: "10:00", EndTime: "12:00", Capacity: 1},
+		&models.DockSlot{ID: 4, DockCode: "D1", StartTime: "13:00", EndTime: "15:00", Capacity: 1},
+		&models.DockAppointment{ID: 1, SlotID: 1, Date: day("2026-10-20"), Brand: "A", Status: AppointmentBooked},
+		&models.DockAppointment{ID: 2, SlotID: 1, Date: day("2026-10-20"), Brand: "A", Status: AppointmentCancelled},
+		&models.DockAppointment{ID: 3, SlotID: 3, Date: day("2026-10-20"), Brand: "B", Status: AppointmentCheckedIn},
+		&models.DockAppointment{ID: 4, SlotID: 3, Date: day("2026-10-21"), Brand: "B", Status: AppointmentCancelled},
+		&models.DockAppointment{ID: 5, SlotID: 1, Date: day("2026-10-22"), Brand: "C", Status: AppointmentBooked, PoNo: "PO-2, PO-3"},
+		&models.DockAppointment{ID: 6, SlotID: 4, Date: day("2026-10-20 08:00:00"), Brand: "C", Status: AppointmentBooked},
+		&models.DockAppointment{ID: 7, SlotID: 4, Date: day("2026-10-25"), Brand: "C", Status: AppointmentBooked, PoNo: "PO-30"},
+	}
+	for _, f := range fixtures {
+		if err := db.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Model(&models.Dock{}).Where("code = ?", "D2").Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		appt   models.DockAppointment
+		status int // 0 when the booking goes through
+	}{
+		{"seat left", models.DockAppointment{SlotID: 1, Date: day("2026-10-20")}, 0},
+		{"time of day is cut off", models.DockAppointment{SlotID: 1, Date: day("2026-10-20 14:00:00")}, 0},
+		{"checked in counts", models.DockAppointment{SlotID: 3, Date: day("2026-10-20")}, http.StatusConflict},
+		{"cancelled does not count", models.DockAppointment{SlotID: 3, Date: day("2026-10-21")}, 0},
+		{"own booking does not count", models.DockAppointment{ID: 3, SlotID: 3, Date: day("2026-10-20")}, 0},
+		{"booking stored with a time counts", models.DockAppointment{SlotID: 4, Date: day("2026-10-20")}, http.StatusConflict},
+		{"inactive dock", models.DockAppointment{SlotID: 2, Date: day("2026-10-20")}, http.StatusConflict},
+		{"unknown slot", models.DockAppointment{SlotID: 99, Date: day("2026-10-20")}, http.StatusBadRequest},
+		{"PO booked elsewhere", models.DockAppointment{SlotID: 1, Date: day("2026-10-23"), PoNo: "po-3"}, http.StatusConflict},
+		{"PO only on own booking", models.DockAppointment{ID: 5, SlotID: 1, Date: day("2026-10-23"), PoNo: "PO-3"}, 0},
+		{"one of several POs booked", models.DockAppointment{SlotID: 1, Date: day("2026-10-23"), PoNo: "PO-9, PO-2"}, http.StatusConflict},
+		{"PO part of a longer one", models.DockAppointment{ID: 5, SlotID: 1, Date: day("2026-10-23"), PoNo: "PO-9, PO-3"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appt := tt.appt
+			err := bookSlot(db, &appt)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("got %v, want no error", err)
+				}
+				if got := appt.Date.String(); got != tt.appt.Date.Time.Format("2006-01-02") {
+					t.Errorf("date %q, want the day only", got)
+				}
+				if appt.DockCode == "" || appt.SlotStart == "" {
+					t.Errorf("slot not copied onto the appointment: %+v", appt)
+				}
+				return
+			}
+			var se *statusError
+			if !errors.As(err, &se) || se.status != tt.status {
+				t.Errorf("got %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestCheckIn(t *testing.T) {
+	db := openTestDB(t, &models.DockAppointment{}, &models.Arrival{}, &models.AuditLog{})
+	day := models.ParseFlexDate
+	fixtures := []interface{}{
+		&models.Arrival{ID: 1, Date: day("2026-10-20 07:30:00"), PoNo: " po-7 ", Brand: "A"},
+		&models.Arrival{ID: 2, Date: day("2026-10-19"), PoNo: "PO-8", Brand: "B"},
+		&models.DockAppointment{ID: 1, SlotID: 1, Date: day("2026-10-20"), SlotStart: "08:00", Brand: "A", PoNo: "PO-6, PO-7", Status: AppointmentBooked},
+		&models.DockAppointment{ID: 2, SlotID: 1, Date: day("2026-10-20"), SlotStart: "08:00", Brand: "B", PoNo: "PO-8", Status: AppointmentBooked},
+	}
+	for _, f := range fixtures {
+		if err := db.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewDockAppointmentHandler(NewResource[models.Arrival]("arrivals"))
+	// checkIn checks an appointment in and returns the arrival it was linked to
+	checkIn := func(id string) uint {
+		t.Helper()
+		w := callHandler(t, h.CheckIn, gin.Params{{Key: "id", Value: id}}, gin.H{"check_in_time": "2026-10-20 08:10:00"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("check in #%s: %d %s", id, w.Code, w.Body)
+		}
+		var appt models.DockAppointment
+		if err := db.First(&appt, id).Error; err != nil || appt.ArrivalID == nil {
+			t.Fatalf("appointment #%s: %v, linked to %v", id, err, appt.ArrivalID)
+		}
+		return *appt.ArrivalID
+	}
+
+	// The arrival of the same day is linked, whatever the case and spacing of its PO
+	if got := checkIn("1"); got != 1 {
+		t.Errorf("appointment 1 linked to arrival %d, want 1", got)
+	}
+	var arrival models.Arrival
+	db.First(&arrival, 1)
+	if arrival.ArrivalTime.String() != "2026-10-20 08:10:00" || arrival.ScheduledArrivalTime.String() != "2026-10-20 08:00:00" {
+		t.Errorf("linked arrival times %s / %s", arrival.ArrivalTime.String(), arrival.ScheduledArrivalTime.String())
+	}
+
+	// An arrival with the PO on another day is left alone
+	if got := checkIn("2"); got <= 2 {
+		t.Errorf("appointment 2 linked to arrival %d, want a new one", got)
+	}
+	var count int64
+	db.Model(&models.Arrival{}).Count(&count)
+	if count != 3 {
+		t.Errorf("%d arrivals, want 3", count)
+	}
+}
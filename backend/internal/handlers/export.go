@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportColumn is a single exported column, taken from a model's json tag
+type exportColumn struct {
+	Header string
+	Index  int
+}
+
+// getExportColumns returns the json-tagged fields of a model in declaration order
+func getExportColumns(t reflect.Type) []exportColumn {
+	var cols []exportColumn
+	for i := 0; i < t.NumField(); i++ {
+		jsonTag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if jsonTag == "" || jsonTag == "-" {
+			continue
+		}
+		cols = append(cols, exportColumn{Header: jsonTag, Index: i})
+	}
+	return cols
+}
+
+// exportValue formats a field for export. FlexDate uses its normalized form.
+func exportValue(f reflect.Value) string {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return ""
+		}
+		f = f.Elem()
+	}
+	switch v := f.Interface().(type) {
+	case models.FlexDate:
+		return v.String()
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// exportRow converts a record into a row of strings matching cols
+func exportRow(item interface{}, cols []exportColumn) []string {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	row := make([]string, len(cols))
+	for i, col := range cols {
+		row[i] = exportValue(v.Field(col.Index))
+	}
+	return row
+}
+
+// exportCells converts a record into spreadsheet cells, keeping numbers numeric
+func exportCells(item interface{}, cols []exportColumn) []interface{} {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	cells := make([]interface{}, len(cols))
+	for i, col := range cols {
+		f := v.Field(col.Index)
+		if f.Kind() == reflect.Ptr && !f.IsNil() {
+			f = f.Elem()
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			cells[i] = f.Interface()
+		default:
+			cells[i] = exportValue(v.Field(col.Index))
+		}
+	}
+	return cells
+}
+
+// Export streams every record matching the List filters as CSV or XLSX.
+// Accepts the same search/dateField/filter/sort parameters as List plus
+// format=csv|xlsx (default csv). Rows are read from a database cursor one
+// at a time, so memory use does not grow with the table.
+func (h *ResourceHandler[T]) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+
+	query, err := h.listQuery(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return
+	}
+	keys, err := h.sortKeys(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return
+	}
+
+	rows, err := applySort(query, keys).Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	cols := getExportColumns(reflect.TypeOf(new(T)).Elem())
+	headers := make([]string, len(cols))
+	for i, col := range cols {
+		headers[i] = col.Header
+	}
+
+	filename := fmt.Sprintf("%s_%s.%s", h.Name, time.Now().Format("20060102_150405"), format)
+	disposition := fmt.Sprintf("attachment; filename=\"%s\"", filename)
+
+	count := 0
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", disposition)
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(headers); err != nil {
+			abortExport(c, h.Name, count, err)
+			return
+		}
+		for rows.Next() {
+			var item T
+			if err := query.ScanRows(rows, &item); err != nil {
+				abortExport(c, h.Name, count, err)
+				return
+			}
+			if err := w.Write(exportRow(&item, cols)); err != nil {
+				abortExport(c, h.Name, count, err)
+				return
+			}
+			count++
+			if count%1000 == 0 {
+				w.Flush()
+				c.Writer.Flush()
+			}
+		}
+		if err := rows.Err(); err != nil {
+			abortExport(c, h.Name, count, err)
+			return
+		}
+		if w.Flush(); w.Error() != nil {
+			abortExport(c, h.Name, count, w.Error())
+			return
+		}
+		log.Printf("[EXPORT] %s: %d rows as csv (by %s)", h.Name, count, c.GetString("username"))
+		return
+	}
+
+	// XLSX: excelize's StreamWriter spills rows to a temp file instead of memory
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Sheet1"
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sw.SetRow("A1", toCells(headers)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var item T
+		if err := query.ScanRows(rows, &item); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, count+2)
+		if err := sw.SetRow(cell, exportCells(&item, cols)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sw.Flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", disposition)
+	c.Status(http.StatusOK)
+	if err := f.Write(c.Writer); err != nil {
+		abortExport(c, h.Name, count, err)
+		return
+	}
+	log.Printf("[EXPORT] %s: %d rows as xlsx (by %s)", h.Name, count, c.GetString("username"))
+}
+
+// abortExport ends an export that failed part way. While nothing has been
+// sent it answers 500; after that the status is already 200, so the
+// connection is dropped and the client sees a broken download instead of a
+// file that looks complete but is cut short.
+func abortExport(c *gin.Context, name string, count int, err error) {
+	log.Printf("[EXPORT] %s: failed after %d rows: %v", name, count, err)
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Content-Type")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Abort()
+	if conn, _, herr := c.Writer.Hijack(); herr == nil {
+		conn.Close()
+		return
+	}
+	// Writers that cannot be hijacked, such as HTTP/2 streams
+	panic(http.ErrAbortHandler)
+}
+
+// toCells converts a string row into excelize cell values
+func toCells(row []string) []interface{} {
+	cells := make([]interface{}, len(row))
+	for i, v := range row {
+		cells[i] = v
+	}
+	return cells
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// columnKind describes how filter values for a column are parsed and compared
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindFlexDate
+	kindTime
+)
+
+// columnInfo maps a model's json field name to its database column
+type columnInfo struct {
+	JSON     string
+	Column   string
+	Kind     columnKind
+	Nullable bool // pointer field, stored as NULL when unset
+}
+
+var (
+	flexDateType = reflect.TypeOf(models.FlexDate{})
+	timeType     = reflect.TypeOf(time.Time{})
+	namer        = schema.NamingStrategy{}
+)
+
+// getModelColumns returns the filterable columns of a model keyed by json tag.
+// The column name comes from the gorm "column:" tag, falling back to GORM's default naming.
+func getModelColumns(t interface{}) map[string]columnInfo {
+	cols := make(map[string]columnInfo)
+	val := reflect.TypeOf(t).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonTag == "" || jsonTag == "-" {
+			continue
+		}
+		gormTag := field.Tag.Get("gorm")
+		if gormTag == "-" {
+			continue
+		}
+		column := namer.ColumnName("", field.Name)
+		for _, part := range strings.Split(gormTag, ";") {
+			if strings.HasPrefix(part, "column:") {
+				column = strings.TrimPrefix(part, "column:")
+			}
+		}
+
+		ft := field.Type
+		nullable := ft.Kind() == reflect.Ptr
+		if nullable {
+			ft = ft.Elem()
+		}
+		var kind columnKind
+		switch {
+		case ft == flexDateType:
+			kind = kindFlexDate
+		case ft == timeType:
+			kind = kindTime
+		case ft.Kind() == reflect.String:
+			kind = kindString
+		case ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64:
+			kind = kindInt
+		case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+			kind = kindFloat
+		case ft.Kind() == reflect.Bool:
+			kind = kindBool
+		default:
+			continue
+		}
+		cols[jsonTag] = columnInfo{JSON: jsonTag, Column: column, Kind: kind, Nullable: nullable}
+	}
+	return cols
+}
+
+// filterParamPattern matches filter[<field>] and filter[<field>][<op>]. Any
+// op is captured so that unknown ones are reported rather than ignored.
+var filterParamPattern = regexp.MustCompile(`^filter\[([^\]]+)\](\[([^\]]+)\])?$`)
+
+// filterOperators maps the supported filter operators to their SQL comparison
+var filterOperators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "LIKE",
+	"in":   "IN",
+	"nin":  "NOT IN",
+}
+
+// filterCondition is a single parsed filter[field][op]=value parameter
+type filterCondition struct {
+	Col   columnInfo
+	Op    string
+	Value string
+}
+
+// QueryParamError is returned when filter or sort parameters reference unknown
+// columns or operators, or carry values that cannot be parsed for the column type
+type QueryParamError struct {
+	UnknownColumns   []string `json:"unknown_columns,omitempty"`
+	UnknownOperators []string `json:"unknown_operators,omitempty"`
+	InvalidValues    []string `json:"invalid_values,omitempty"`
+	UnknownSortKeys  []string `json:"unknown_sort_keys,omitempty"`
+}
+
+func (e *QueryParamError) Error() string {
+	var parts []string
+	if len(e.UnknownColumns) > 0 {
+		parts = append(parts, "unknown columns: "+strings.Join(e.UnknownColumns, ", "))
+	}
+	if len(e.UnknownOperators) > 0 {
+		parts = append(parts, "unknown operators: "+strings.Join(e.UnknownOperators, ", "))
+	}
+	if len(e.InvalidValues) > 0 {
+		parts = append(parts, "invalid values: "+strings.Join(e.InvalidValues, ", "))
+	}
+	if len(e.UnknownSortKeys) > 0 {
+		parts = append(parts, "unknown sort keys: "+strings.Join(e.UnknownSortKeys, ", "))
+	}
+	return "Invalid query parameters (" + strings.Join(parts, "; ") + ")"
+}
+
+// parseFilters extracts filter[field][op]=value parameters and validates them
+// against the model columns. A missing operator defaults to "eq"; operators
+// are case-sensitive, and a filter parameter of any other shape is rejected.
+func parseFilters(params url.Values, cols map[string]columnInfo) ([]filterCondition, error) {
+	var conds []filterCondition
+	ferr := &QueryParamError{}
+	for key, values := range params {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		m := filterParamPattern.FindStringSubmatch(key)
+		if m == nil {
+			ferr.UnknownOperators = append(ferr.UnknownOperators, key)
+			continue
+		}
+		field, op := m[1], m[3]
+		if m[2] == "" {
+			op = "eq"
+		}
+		col, ok := cols[field]
+		if !ok {
+			ferr.UnknownColumns = append(ferr.UnknownColumns, field)
+			continue
+		}
+		if _, ok := filterOperators[op]; !ok {
+			ferr.UnknownOperators = append(ferr.UnknownOperators, op)
+			continue
+		}
+		for _, v := range values {
+			cond := filterCondition{Col: col, Op: op, Value: v}
+			if err := cond.validate(); err != nil {
+				ferr.InvalidValues = append(ferr.InvalidValues, fmt.Sprintf("%s=%q", key, v))
+				continue
+			}
+			conds = append(conds, cond)
+		}
+	}
+	if len(ferr.UnknownColumns) > 0 || len(ferr.UnknownOperators) > 0 || len(ferr.InvalidValues) > 0 {
+		sort.Strings(ferr.UnknownColumns)
+		sort.Strings(ferr.UnknownOperators)
+		sort.Strings(ferr.InvalidValues)
+		return nil, ferr
+	}
+	// Deterministic order keeps the generated SQL stable
+	sort.Slice(conds, func(i, j int) bool {
+		if conds[i].Col.Column != conds[j].Col.Column {
+			return conds[i].Col.Column < conds[j].Col.Column
+		}
+		return conds[i].Op < conds[j].Op
+	})
+	return conds, nil
+}
+
+// values splits "in"/"nin" lists on commas; other operators take the raw value
+func (f filterCondition) values() []string {
+	if f.Op == "in" || f.Op == "nin" {
+		parts := strings.Split(f.Value, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts
+	}
+	return []string{f.Value}
+}
+
+// validate checks that every value can be converted to the column type.
+// like only applies to text columns: strings and the text-stored FlexDate.
+func (f filterCondition) validate() error {
+	if f.Op == "like" {
+		if f.Col.Kind != kindString && f.Col.Kind != kindFlexDate {
+			return fmt.Errorf("like is not supported on column %s", f.Col.Column)
+		}
+		return nil
+	}
+	for _, v := range f.values() {
+		if _, err := f.Col.convert(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert parses a raw filter value into the type stored in the column
+func (c columnInfo) convert(v string) (interface{}, error) {
+	switch c.Kind {
+	case kindInt:
+		return strconv.ParseInt(v, 10, 64)
+	case kindFloat:
+		return strconv.ParseFloat(v, 64)
+	case kindBool:
+		return strconv.ParseBool(v)
+	case kindFlexDate, kindTime:
+		fd := models.ParseFlexDate(v)
+		if !fd.Valid {
+			return nil, fmt.Errorf("invalid date %q", v)
+		}
+		if c.Kind == kindTime {
+			return fd.Time, nil
+		}
+		return fd, nil
+	default:
+		return v, nil
+	}
+}
+
+// isDateOnly reports whether a FlexDate carries no time component
+func isDateOnly(fd models.FlexDate) bool {
+	return fd.Time.Hour() == 0 && fd.Time.Minute() == 0 && fd.Time.Second() == 0
+}
+
+// apply adds the condition to the query. FlexDate columns are stored as
+// normalized text, so a date-only value covers the whole day.
+func (f filterCondition) apply(query *gorm.DB) *gorm.DB {
+	col := query.Statement.Quote(f.Col.Column)
+	sqlOp := filterOperators[f.Op]
+
+	if f.Op == "like" {
+		return query.Where(fmt.Sprintf("%s LIKE ?", col), "%"+f.Value+"%")
+	}
+
+	var args []interface{}
+	for _, v := range f.values() {
+		parsed, _ := f.Col.convert(v)
+		args = append(args, parsed)
+	}
+
+	if f.Col.Kind == kindFlexDate {
+		fd := args[0].(models.FlexDate)
+		if f.Op != "in" && f.Op != "nin" && isDateOnly(fd) {
+			day := fd.String()
+			endOfDay := day + " 23:59:59"
+			switch f.Op {
+			case "eq":
+				return query.Where(fmt.Sprintf("%s >= ? AND %s <= ?", col, col), day, endOfDay)
+			case "ne":
+				return query.Where(fmt.Sprintf("NOT (%s >= ? AND %s <= ?)", col, col), day, endOfDay)
+			case "gt":
+				return query.Where(fmt.Sprintf("%s > ?", col), endOfDay)
+			case "lte":
+				return query.Where(fmt.Sprintf("%s <= ?", col), endOfDay)
+			}
+		}
+		for i, a := range args {
+			args[i] = a.(models.FlexDate).String()
+		}
+	}
+
+	if f.Op == "in" || f.Op == "nin" {
+		return query.Where(fmt.Sprintf("%s %s ?", col, sqlOp), args)
+	}
+	return query.Where(fmt.Sprintf("%s %s ?", col, sqlOp), args[0])
+}
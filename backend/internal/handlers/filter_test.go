@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilters(t *testing.T) {
+	cols := getModelColumns(new(cursorRow))
+	tests := []struct {
+		query string
+		conds []string // column op value, in the order applied
+		err   *QueryParamError
+	}{
+		{query: "", conds: nil},
+		{query: "search=x&page=2&sort=-qty", conds: nil},
+		{query: "filter[name]=a", conds: []string{"name eq a"}},
+		{query: "filter[qty][gte]=5&filter[qty][lt]=9", conds: []string{"qty gte 5", "qty lt 9"}},
+		{query: "filter[name][like]=ab&filter[date][eq]=2024-10-01", conds: []string{"date eq 2024-10-01", "name like ab"}},
+		{query: "filter[qty][in]=1,2,3", conds: []string{"qty in 1,2,3"}},
+		{query: "filter[qty][GTE]=5", err: &QueryParamError{UnknownOperators: []string{"GTE"}}},
+		{query: "filter[qty][between]=1", err: &QueryParamError{UnknownOperators: []string{"between"}}},
+		{query: "filter[qty][]=1", err: &QueryParamError{UnknownOperators: []string{"filter[qty][]"}}},
+		{query: "filter[qty][gt][x]=1", err: &QueryParamError{UnknownOperators: []string{"filter[qty][gt][x]"}}},
+		{query: "filter[colour]=red", err: &QueryParamError{UnknownColumns: []string{"colour"}}},
+		{query: "filter[qty][gt]=many", err: &QueryParamError{InvalidValues: []string{`filter[qty][gt]="many"`}}},
+		{query: "filter[qty][like]=1", err: &QueryParamError{InvalidValues: []string{`filter[qty][like]="1"`}}},
+		{query: "filter[done]=maybe", err: &QueryParamError{InvalidValues: []string{`filter[done]="maybe"`}}},
+		{query: "filter[date][gte]=soon", err: &QueryParamError{InvalidValues: []string{`filter[date][gte]="soon"`}}},
+		{query: "filter[qty][in]=1,x", err: &QueryParamError{InvalidValues: []string{`filter[qty][in]="1,x"`}}},
+		{
+			query: "filter[b]=1&filter[a]=1&filter[qty][NE]=1",
+			err:   &QueryParamError{UnknownColumns: []string{"a", "b"}, UnknownOperators: []string{"NE"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conds, err := parseFilters(params, cols)
+			if tt.err != nil {
+				var qerr *QueryParamError
+				if !errors.As(err, &qerr) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				if !reflect.DeepEqual(qerr, tt.err) {
+					t.Errorf("error %+v, want %+v", qerr, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range conds {
+				got = append(got, c.Col.Column+" "+c.Op+" "+c.Value)
+			}
+			if !reflect.DeepEqual(got, tt.conds) {
+				t.Errorf("conditions %q, want %q", got, tt.conds)
+			}
+		})
+	}
+}
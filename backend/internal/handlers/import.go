@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Import modes for ImportFile
+const (
+	ImportAllOrNothing = "all-or-nothing"
+	ImportSkipInvalid  = "skip-invalid"
+)
+
+// importIgnoredFields are managed by the server and never read from a file
+var importIgnoredFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"updated_by": true,
+}
+
+// RowError lists the problems found on one row of an import. For files, Row
+// is the 1-based line number counting the header row; for JSON batches it is
+// the 1-based position of the record.
+type RowError struct {
+	Row    int          `json:"row"`
+	Errors []FieldError `json:"errors"`
+}
+
+// ImportReport is the response body of ImportFile
+type ImportReport struct {
+	Mode           string     `json:"mode"`
+	ImportMode     string     `json:"import_mode"`
+	TotalRows      int        `json:"total_rows"`
+	Imported       int        `json:"imported"`
+	Inserted       int        `json:"inserted"`
+	Updated        int        `json:"updated"`
+	Skipped        int        `json:"skipped"`
+	Failed         int        `json:"failed"`
+	IgnoredColumns []string   `json:"ignored_columns"`
+	Errors         []RowError `json:"errors"`
+}
+
+// mappedColumn ties a file column position to a model column
+type mappedColumn struct {
+	Index int
+	Col   columnInfo
+}
+
+// normalizeHeader turns a spreadsheet header like "Receipt No" into "receipt_no"
+func normalizeHeader(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff")
+	h = strings.ToLower(strings.TrimSpace(h))
+	h = strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(h)
+	return h
+}
+
+// readImportFile reads every row of an uploaded CSV or XLSX file.
+// For XLSX only the first sheet is read.
+func readImportFile(fh *multipart.FileHeader) ([][]string, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(fh.Filename)) {
+	case ".csv":
+		r := csv.NewReader(file)
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		return r.ReadAll()
+	case ".xlsx":
+		f, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		return f.GetRows(sheets[0])
+	default:
+		return nil, fmt.Errorf("unsupported file type %q, expected .csv or .xlsx", filepath.Ext(fh.Filename))
+	}
+}
+
+// parseImportCell converts a cell into a JSON value for the column type.
+// Spreadsheets often carry numbers as "1,200" or "12.0", so both are accepted.
+func parseImportCell(col columnInfo, cell string) (interface{}, *FieldError) {
+	cell = strings.TrimSpace(cell)
+	switch col.Kind {
+	case kindInt:
+		if cell == "" {
+			return nil, nil
+		}
+		clean := strings.ReplaceAll(cell, ",", "")
+		if n, err := strconv.ParseInt(clean, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(clean, 64); err == nil && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+		return nil, &FieldError{Field: col.JSON, Rule: "int", Message: fmt.Sprintf("%s: %q is not a whole number", col.JSON, cell)}
+	case kindFloat:
+		if cell == "" {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(strings.ReplaceAll(cell, ",", ""), 64)
+		if err != nil {
+			return nil, &FieldError{Field: col.JSON, Rule: "number", Message: fmt.Sprintf("%s: %q is not a number", col.JSON, cell)}
+		}
+		return f, nil
+	case kindBool:
+		if cell == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, &FieldError{Field: col.JSON, Rule: "bool", Message: fmt.Sprintf("%s: %q is not true/false", col.JSON, cell)}
+		}
+		return b, nil
+	case kindFlexDate:
+		if cell != "" && !models.ParseFlexDate(cell).Valid {
+			return nil, &FieldError{Field: col.JSON, Rule: "date", Message: fmt.Sprintf("%s: could not parse date %q", col.JSON, cell)}
+		}
+		return cell, nil
+	default:
+		return cell, nil
+	}
+}
+
+// ImportFile imports a multipart CSV/XLSX upload (form field "file").
+// Headers are matched to the model's json tags, every row is checked against
+// the binding tags, and a per-row error report is returned.
+//
+// mode=all-or-nothing (default) imports nothing if any row is invalid;
+// mode=skip-invalid imports the valid rows and reports the rest.
+// import_mode handles existing natural keys the same way as BatchImport.
+func (h *ResourceHandler[T]) ImportFile(c *gin.Context) {
+	mode := c.DefaultPostForm("mode", c.DefaultQuery("mode", ImportAllOrNothing))
+	if mode != ImportAllOrNothing && mode != ImportSkipInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("mode must be %s or %s", ImportAllOrNothing, ImportSkipInvalid)})
+		return
+	}
+	importMode, err := h.resolveImportMode(c.DefaultPostForm("import_mode", c.Query("import_mode")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	rows, err := readImportFile(fh)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+
+	report := ImportReport{Mode: mode, ImportMode: importMode, IgnoredColumns: []string{}, Errors: []RowError{}}
+	username := c.GetString("username")
+	fixed := map[string]interface{}{}
+	if username != "" {
+		fixed["updated_by"] = username
+	}
+	valid, err := h.parseImportRows(rows, fixed, &report)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "ignored_columns": report.IgnoredColumns})
+		return
+	}
+	report.Failed = len(report.Errors)
+
+	if mode == ImportAllOrNothing && report.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	if len(valid) > 0 {
+		err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+			res, err := h.importBatches(tx, valid, importMode, username)
+			report.Inserted, report.Updated, report.Skipped = res.Inserted, res.Updated, res.Skipped
+			report.Imported = res.Inserted + res.Updated
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	log.Printf("[IMPORT] %s: %s imported %d of %d rows from %s (%s)",
+		h.Name, username, report.Imported, report.TotalRows, fh.Filename, mode)
+	c.JSON(http.StatusOK, report)
+}
+
+// parseImportRows maps the header row of a file to the model's columns and
+// turns every other non-blank row into a record, adding the problems of each
+// row to report. fixed values are set on every row before it is validated.
+// It fails only when no header matches a column.
+func (h *ResourceHandler[T]) parseImportRows(rows [][]string, fixed map[string]interface{}, report *ImportReport) ([]T, error) {
+	// Map header positions to model columns
+	cols := getModelColumns(new(T))
+	var mapped []mappedColumn
+	for i, header := range rows[0] {
+		name := normalizeHeader(header)
+		col, ok := cols[name]
+		if !ok || importIgnoredFields[name] || h.isProtected(name) {
+			if strings.TrimSpace(header) != "" {
+				report.IgnoredColumns = append(report.IgnoredColumns, header)
+			}
+			continue
+		}
+		mapped = append(mapped, mappedColumn{Index: i, Col: col})
+	}
+	if len(mapped) == 0 {
+		return nil, fmt.Errorf("no column in the header row matches this resource")
+	}
+
+	var valid []T
+	for r, row := range rows[1:] {
+		lineNo := r + 2
+		if isBlankRow(row) {
+			continue
+		}
+		report.TotalRows++
+
+		raw := make(map[string]interface{})
+		var rowErrs []FieldError
+		for _, m := range mapped {
+			if m.Index >= len(row) {
+				continue
+			}
+			v, ferr := parseImportCell(m.Col, row[m.Index])
+			if ferr != nil {
+				rowErrs = append(rowErrs, *ferr)
+				continue
+			}
+			if v != nil {
+				raw[m.Col.JSON] = v
+			}
+		}
+		for k, v := range fixed {
+			raw[k] = v
+		}
+
+		// Validate even when some cells failed to parse, so the report lists
+		// every problem on the row; parse errors win over binding errors.
+		var item T
+		enriched, _ := json.Marshal(raw)
+		if err := json.Unmarshal(enriched, &item); err != nil {
+			rowErrs = append(rowErrs, FieldError{Message: err.Error()})
+		} else {
+			flagged := make(map[string]bool)
+			for _, fe := range rowErrs {
+				flagged[fe.Field] = true
+			}
+			for _, fe := range h.validate(&item) {
+				if !flagged[fe.Field] {
+					rowErrs = append(rowErrs, fe)
+				}
+			}
+		}
+
+		if len(rowErrs) > 0 {
+			report.Errors = append(report.Errors, RowError{Row: lineNo, Errors: rowErrs})
+			continue
+		}
+		valid = append(valid, item)
+	}
+	return valid, nil
+}
+
+// isBlankRow reports whether every cell of a row is empty
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
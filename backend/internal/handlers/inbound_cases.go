@@ -0,0 +1,745 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	minioClient "warehouse-report-monitoring/internal/minio"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inbound case statuses, in workflow order
+const (
+	CaseOpen            = "Open"
+	CaseInvestigating   = "Investigating"
+	CaseWaitingSupplier = "Waiting Supplier"
+	CaseResolved        = "Resolved"
+	CaseClosed          = "Closed"
+)
+
+// CaseStatuses lists the case statuses in workflow order
+var CaseStatuses = []string{CaseOpen, CaseInvestigating, CaseWaitingSupplier, CaseResolved, CaseClosed}
+
+// openCaseStatuses are the statuses of cases still being worked on
+var openCaseStatuses = []string{CaseOpen, CaseInvestigating, CaseWaitingSupplier}
+
+// caseTransitions lists the statuses each status may move to. A resolved
+// case can be reopened for investigation; a closed one is final.
+var caseTransitions = map[string][]string{
+	CaseOpen:            {CaseInvestigating, CaseWaitingSupplier, CaseResolved, CaseClosed},
+	CaseInvestigating:   {CaseWaitingSupplier, CaseResolved, CaseClosed},
+	CaseWaitingSupplier: {CaseInvestigating, CaseResolved, CaseClosed},
+	CaseResolved:        {CaseInvestigating, CaseClosed},
+	CaseClosed:          {},
+}
+
+// canTransition reports whether caseTransitions lets a case move from one
+// status to another
+func canTransition(from, to string) bool {
+	for _, s := range caseTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Kinds of case timeline entries
+const (
+	CaseCommentNote       = "comment"
+	CaseCommentStatus     = "status"
+	CaseCommentAssignment = "assignment"
+	CaseCommentEscalation = "escalation"
+)
+
+// maxEscalationLevel caps how far an overdue case escalates
+const maxEscalationLevel = 3
+
+// maxAttachmentSize bounds an uploaded case attachment
+const maxAttachmentSize = 20 << 20
+
+// unsafeFileChars are replaced in attachment object keys
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// caseWorkflowFields are the case fields only the workflow endpoints change
+var caseWorkflowFields = []string{"status", "resolved_at", "closed_at", "escalation_level", "escalated_at"}
+
+// InboundCaseHandler serves inbound cases: the generic resource routes, with
+// the workflow fields protected, plus status transitions, assignment,
+// comments, attachments, escalation and statistics
+type InboundCaseHandler struct {
+	cases *ResourceHandler[models.InboundCase]
+}
+
+// NewInboundCaseHandler creates the handler
+func NewInboundCaseHandler() *InboundCaseHandler {
+	return &InboundCaseHandler{
+		cases: NewResource[models.InboundCase]("inbound-cases").WithProtectedFields(caseWorkflowFields...),
+	}
+}
+
+// RegisterRoutes registers the inbound case routes
+func (h *InboundCaseHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	h.cases.RegisterRoutes(rg)
+	can := func(action string) gin.HandlerFunc {
+		return middleware.RequirePermission(h.cases.Name, action)
+	}
+	rg.GET("/overdue", can(middleware.ActionList), h.Overdue)
+	rg.GET("/stats", can(middleware.ActionList), h.Stats)
+	rg.POST("/escalate", can(middleware.ActionUpdate), h.Escalate)
+	rg.POST("/:id/transition", can(middleware.ActionUpdate), h.Transition)
+	rg.POST("/:id/assign", can(middleware.ActionUpdate), h.Assign)
+	rg.GET("/:id/comments", can(middleware.ActionGet), h.ListComments)
+	rg.POST("/:id/comments", can(middleware.ActionUpdate), h.AddComment)
+	rg.GET("/:id/attachments", can(middleware.ActionGet), h.ListAttachments)
+	rg.POST("/:id/attachments", can(middleware.ActionUpdate), h.UploadAttachment)
+	rg.GET("/:id/attachments/:attachmentId", can(middleware.ActionGet), h.GetAttachment)
+	rg.DELETE("/:id/attachments/:attachmentId", can(middleware.ActionUpdate), h.DeleteAttachment)
+}
+
+// caseTypeIndex maps the code and the name of each active case type of each
+// warehouse to the type
+type caseTypeIndex map[string]models.InboundCaseType
+
+func caseTypeKey(warehouse, name string) string {
+	return strings.ToLower(strings.TrimSpace(warehouse)) + "|" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// loadCaseTypes reads the active case types visible to db
+func loadCaseTypes(db *gorm.DB) (caseTypeIndex, error) {
+	var types []models.InboundCaseType
+	if err := db.Where("is_active = ?", true).Find(&types).Error; err != nil {
+		return nil, err
+	}
+	idx := caseTypeIndex{}
+	for _, t := range types {
+		if t.Name != "" {
+			idx[caseTypeKey(t.Warehouse, t.Name)] = t
+		}
+		idx[caseTypeKey(t.Warehouse, t.Code)] = t
+	}
+	return idx, nil
+}
+
+// typeOf returns the case type of a case, if its Case names one
+func (idx caseTypeIndex) typeOf(cs models.InboundCase) (models.InboundCaseType, bool) {
+	t, ok := idx[caseTypeKey(cs.Warehouse, cs.Case)]
+	return t, ok
+}
+
+// dueAt returns when a case is due: its due date, or else its date plus the
+// due hours of its case type. Cases with neither are never due.
+func (idx caseTypeIndex) dueAt(cs models.InboundCase) models.FlexDate {
+	if cs.DueDate.Valid {
+		return cs.DueDate
+	}
+	if t, ok := idx.typeOf(cs); ok && t.DueHours > 0 && cs.Date.Valid {
+		return models.FlexDate{Time: cs.Date.Time.Add(time.Duration(t.DueHours) * time.Hour), Valid: true}
+	}
+	return models.FlexDate{}
+}
+
+// isOpenCase reports whether a case is still being worked on. Cases from
+// before the workflow have no status and count as open.
+func isOpenCase(cs models.InboundCase) bool {
+	if cs.Status == "" {
+		return true
+	}
+	for _, s := range openCaseStatuses {
+		if cs.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CaseView is a case with its case type, its due time and how long it is
+// overdue. CaseType is the code of the matching case type, or the trimmed
+// Case when it names none.
+type CaseView struct {
+	models.InboundCase
+	CaseType     string          `json:"case_type"`
+	DueAt        models.FlexDate `json:"due_at"`
+	Overdue      bool            `json:"overdue"`
+	OverdueHours int             `json:"overdue_hours"`
+}
+
+// viewCase works out when a case is due and whether it is overdue at now
+func viewCase(cs models.InboundCase, idx caseTypeIndex, now time.Time) CaseView {
+	v := CaseView{InboundCase: cs, CaseType: strings.TrimSpace(cs.Case), DueAt: idx.dueAt(cs)}
+	if t, ok := idx.typeOf(cs); ok {
+		v.CaseType = t.Code
+	}
+	if v.DueAt.Valid && isOpenCase(cs) && now.After(v.DueAt.Time) {
+		v.Overdue = true
+		v.OverdueHours = int(now.Sub(v.DueAt.Time) / time.Hour)
+	}
+	return v
+}
+
+// escalationLevel is the level an overdue case should be at: one on
+// becoming overdue, one more for every EscalateEveryHours after that
+func (idx caseTypeIndex) escalationLevel(v CaseView) int {
+	if !v.Overdue {
+		return 0
+	}
+	level := 1
+	if t, ok := idx.typeOf(v.InboundCase); ok && t.EscalateEveryHours > 0 {
+		level += v.OverdueHours / t.EscalateEveryHours
+	}
+	return min(level, maxEscalationLevel)
+}
+
+// addCaseComment writes an entry to the timeline of a case
+func addCaseComment(tx *gorm.DB, caseID uint, kind, body, author string) (models.InboundCaseComment, error) {
+	comment := models.InboundCaseComment{CaseID: caseID, Kind: kind, Body: body, Author: author}
+	err := tx.Create(&comment).Error
+	return comment, err
+}
+
+// escalateCases raises the escalation level of the open cases visible to db
+// that are overdue, noting each escalation on the case timeline and in the
+// audit log. It returns the escalated cases.
+func (h *InboundCaseHandler) escalateCases(db *gorm.DB, now time.Time, actor string) ([]CaseView, error) {
+	idx, err := loadCaseTypes(db)
+	if err != nil {
+		return nil, err
+	}
+	var cases []models.InboundCase
+	if err := db.Where("(status IN ? OR status = '' OR status IS NULL) AND escalation_level < ?", openCaseStatuses, maxEscalationLevel).
+		Order("id").Find(&cases).Error; err != nil {
+		return nil, err
+	}
+
+	escalated := []CaseView{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, cs := range cases {
+			v := viewCase(cs, idx, now)
+			level := idx.escalationLevel(v)
+			if level <= cs.EscalationLevel {
+				continue
+			}
+			before := cs
+			cs.EscalationLevel = level
+			cs.EscalatedAt = models.FlexDate{Time: now, Valid: true}
+			cs.UpdatedBy = actor
+			if err := tx.Model(&cs).Select("escalation_level", "escalated_at", "updated_by", "updated_at").Updates(&cs).Error; err != nil {
+				return err
+			}
+			note := fmt.Sprintf("Escalated to level %d: overdue by %d hours (due %s)", level, v.OverdueHours, v.DueAt.String())
+			if _, err := addCaseComment(tx, cs.ID, CaseCommentEscalation, note, actor); err != nil {
+				return err
+			}
+			if err := writeAudit(tx, h.cases.auditRecord(AuditUpdate, actor, &before, &cs)); err != nil {
+				return err
+			}
+			v.InboundCase = cs
+			escalated = append(escalated, v)
+		}
+		return nil
+	})
+	return escalated, err
+}
+
+// StartCaseEscalation escalates overdue cases of every active warehouse,
+// once at startup and then every interval. interval <= 0 disables the job.
+func (h *InboundCaseHandler) StartCaseEscalation(interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("[CASE] Escalation job disabled")
+		return
+	}
+	log.Printf("[CASE] Escalating overdue cases every %s", interval)
+	go func() {
+		for {
+			h.runCaseEscalation()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// runCaseEscalation performs one pass of the escalation job
+func (h *InboundCaseHandler) runCaseEscalation() {
+	var codes []string
+	if err := database.DB.Model(&models.Warehouse{}).Where("is_active = ?", true).Pluck("code", &codes).Error; err != nil {
+		log.Printf("[CASE] Escalation failed to list warehouses: %v", err)
+		return
+	}
+	now := wallClockNow()
+	for _, code := range codes {
+		db := database.DB.WithContext(database.WithWarehouse(context.Background(), code))
+		escalated, err := h.escalateCases(db, now, "system")
+		if err != nil {
+			log.Printf("[CASE] %s: escalation failed: %v", code, err)
+			continue
+		}
+		if len(escalated) > 0 {
+			log.Printf("[CASE] %s: escalated %d overdue cases", code, len(escalated))
+		}
+	}
+}
+
+// Escalate serves POST /inbound-cases/escalate: runs the escalation of the
+// active warehouse now and returns the cases it escalated
+func (h *InboundCaseHandler) Escalate(c *gin.Context) {
+	escalated, err := h.escalateCases(warehouseDB(c), wallClockNow(), c.GetString("username"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"escalated": escalated, "total": len(escalated)})
+}
+
+// loadCaseViews reads the cases matching the list parameters of the request
+// with their due times. It writes the error response itself and returns
+// false when that fails.
+func (h *InboundCaseHandler) loadCaseViews(c *gin.Context) ([]CaseView, bool) {
+	query, err := h.cases.listQuery(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return nil, false
+	}
+	var cases []models.InboundCase
+	if err := query.Find(&cases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	idx, err := loadCaseTypes(warehouseDB(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	now := wallClockNow()
+	views := make([]CaseView, len(cases))
+	for i, cs := range cases {
+		views[i] = viewCase(cs, idx, now)
+	}
+	return views, true
+}
+
+// Overdue serves GET /inbound-cases/overdue: the open cases past their due
+// time, most overdue first. Takes the list parameters of the cases resource.
+func (h *InboundCaseHandler) Overdue(c *gin.Context) {
+	views, ok := h.loadCaseViews(c)
+	if !ok {
+		return
+	}
+	items := []CaseView{}
+	for _, v := range views {
+		if v.Overdue {
+			items = append(items, v)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].OverdueHours > items[j].OverdueHours })
+	c.JSON(http.StatusOK, items)
+}
+
+// CaseBrandStats sums up the cases of one brand. Resolution hours run from
+// the case date to when it was resolved (or closed, if never resolved).
+type CaseBrandStats struct {
+	Brand              string         `json:"brand"`
+	Total              int            `json:"total"`
+	Qty                int            `json:"qty"`
+	Open               int            `json:"open"`
+	Overdue            int            `json:"overdue"`
+	Escalated          int            `json:"escalated"`
+	ByStatus           map[string]int `json:"by_status"`
+	ByType             map[string]int `json:"by_type"`
+	AvgResolutionHours float64        `json:"avg_resolution_hours"`
+	resolved           int
+	resolutionHours    float64
+}
+
+// Stats serves GET /inbound-cases/stats: case counts per brand by status
+// and case type, with open, overdue and escalated counts and the average
+// time to resolve. Takes the list parameters of the cases resource.
+func (h *InboundCaseHandler) Stats(c *gin.Context) {
+	views, ok := h.loadCaseViews(c)
+	if !ok {
+		return
+	}
+	byBrand := map[string]*CaseBrandStats{}
+	for _, v := range views {
+		brand := strings.TrimSpace(v.Brand)
+		s := byBrand[strings.ToLower(brand)]
+		if s == nil {
+			s = &CaseBrandStats{Brand: brand, ByStatus: map[string]int{}, ByType: map[string]int{}}
+			byBrand[strings.ToLower(brand)] = s
+		}
+		status := v.Status
+		if status == "" {
+			status = CaseOpen
+		}
+		s.Total++
+		s.Qty += v.Qty
+		s.ByStatus[status]++
+		s.ByType[v.CaseType]++
+		if isOpenCase(v.InboundCase) {
+			s.Open++
+		}
+		if v.Overdue {
+			s.Overdue++
+		}
+		if v.EscalationLevel > 0 {
+			s.Escalated++
+		}
+		done := v.ResolvedAt
+		if !done.Valid {
+			done = v.ClosedAt
+		}
+		if done.Valid && v.Date.Valid && !isOpenCase(v.InboundCase) {
+			s.resolved++
+			s.resolutionHours += max(done.Time.Sub(v.Date.Time).Hours(), 0)
+			s.AvgResolutionHours = float64(int(s.resolutionHours/float64(s.resolved)*100+0.5)) / 100
+		}
+	}
+
+	out := make([]*CaseBrandStats, 0, len(byBrand))
+	for _, s := range byBrand {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Brand < out[j].Brand
+	})
+	c.JSON(http.StatusOK, gin.H{"data": out, "statuses": CaseStatuses})
+}
+
+// caseID parses the :id of a case route
+func caseID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// updateCase locks a case, applies change to it and saves the changed
+// columns with an audit entry and a timeline note from change
+func (h *InboundCaseHandler) updateCase(c *gin.Context, id uint64, kind string, change func(cs *models.InboundCase) (string, error)) (models.InboundCase, error) {
+	actor := c.GetString("username")
+	var cs models.InboundCase
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cs, id).Error; err != nil {
+			return err
+		}
+		before := cs
+		note, err := change(&cs)
+		if err != nil {
+			return err
+		}
+		cs.UpdatedBy = actor
+		if err := tx.Save(&cs).Error; err != nil {
+			return err
+		}
+		if _, err := addCaseComment(tx, cs.ID, kind, note, actor); err != nil {
+			return err
+		}
+		return writeAudit(tx, h.cases.auditRecord(AuditUpdate, actor, &before, &cs))
+	})
+	return cs, err
+}
+
+// TransitionRequest is the body of POST /inbound-cases/:id/transition
+type TransitionRequest struct {
+	Status     string `json:"status" binding:"required"`
+	Resolution string `json:"resolution"`
+	Comment    string `json:"comment"`
+}
+
+// Transition moves a case to another status along caseTransitions.
+// Resolving or closing a case needs a resolution, sent or already recorded.
+func (h *InboundCaseHandler) Transition(c *gin.Context) {
+	id, ok := caseID(c)
+	if !ok {
+		return
+	}
+	var req TransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cs, err := h.updateCase(c, id, CaseCommentStatus, func(cs *models.InboundCase) (string, error) {
+		from := cs.Status
+		if from == "" {
+			from = CaseOpen
+		}
+		if !canTransition(from, req.Status) {
+			return "", statusFailed(http.StatusConflict, "Cannot move a case from %s to %s", from, req.Status)
+		}
+		if r := strings.TrimSpace(req.Resolution); r != "" {
+			cs.Resolution = r
+		}
+		now := models.FlexDate{Time: wallClockNow(), Valid: true}
+		switch req.Status {
+		case CaseResolved:
+			cs.ResolvedAt = now
+		case CaseClosed:
+			cs.ClosedAt = now
+		case CaseInvestigating:
+			// Reopened: the earlier resolution no longer holds
+			cs.ResolvedAt = models.FlexDate{}
+		}
+		if (req.Status == CaseResolved || req.Status == CaseClosed) && cs.Resolution == "" {
+			return "", statusFailed(http.StatusBadRequest, "resolution is required to move a case to %s", req.Status)
+		}
+		cs.Status = req.Status
+		note := from + " → " + req.Status
+		if comment := strings.TrimSpace(req.Comment); comment != "" {
+			note += ": " + comment
+		}
+		return note, nil
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.Header("ETag", etagOf(&cs))
+	c.JSON(http.StatusOK, cs)
+}
+
+// AssignRequest is the body of POST /inbound-cases/:id/assign
+type AssignRequest struct {
+	Assignee string `json:"assignee"`
+	Comment  string `json:"comment"`
+}
+
+// Assign hands a case to a user; an empty assignee unassigns it
+func (h *InboundCaseHandler) Assign(c *gin.Context) {
+	id, ok := caseID(c)
+	if !ok {
+		return
+	}
+	var req AssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	assignee := strings.TrimSpace(req.Assignee)
+	cs, err := h.updateCase(c, id, CaseCommentAssignment, func(cs *models.InboundCase) (string, error) {
+		note := "Assigned to " + assignee
+		if assignee == "" {
+			note = "Unassigned"
+		}
+		if comment := strings.TrimSpace(req.Comment); comment != "" {
+			note += ": " + comment
+		}
+		cs.Assignee = assignee
+		return note, nil
+	})
+	if err != nil {
+		respondStatusError(c, err)
+		return
+	}
+	c.Header("ETag", etagOf(&cs))
+	c.JSON(http.StatusOK, cs)
+}
+
+// findCase checks that a case exists in the active warehouse
+func findCase(c *gin.Context) (models.InboundCase, bool) {
+	var cs models.InboundCase
+	id, ok := caseID(c)
+	if !ok {
+		return cs, false
+	}
+	if err := warehouseDB(c).First(&cs, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return cs, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return cs, false
+	}
+	return cs, true
+}
+
+// ListComments returns the timeline of a case, oldest first
+func (h *InboundCaseHandler) ListComments(c *gin.Context) {
+	cs, ok := findCase(c)
+	if !ok {
+		return
+	}
+	comments := []models.InboundCaseComment{}
+	if err := warehouseDB(c).Where("case_id = ?", cs.ID).Order("created_at, id").Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+// CommentRequest is the body of POST /inbound-cases/:id/comments
+type CommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// AddComment adds a user comment to the timeline of a case
+func (h *InboundCaseHandler) AddComment(c *gin.Context) {
+	cs, ok := findCase(c)
+	if !ok {
+		return
+	}
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body is required"})
+		return
+	}
+	comment, err := addCaseComment(warehouseDB(c), cs.ID, CaseCommentNote, strings.TrimSpace(req.Body), c.GetString("username"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, comment)
+}
+
+// ListAttachments returns the files attached to a case, oldest first
+func (h *InboundCaseHandler) ListAttachments(c *gin.Context) {
+	cs, ok := findCase(c)
+	if !ok {
+		return
+	}
+	attachments := []models.InboundCaseAttachment{}
+	if err := warehouseDB(c).Where("case_id = ?", cs.ID).Order("created_at, id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UploadAttachment stores a multipart file (field "file") with a case
+func (h *InboundCaseHandler) UploadAttachment(c *gin.Context) {
+	cs, ok := findCase(c)
+	if !ok {
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+	if header.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file is larger than %d MB", maxAttachmentSize>>20)})
+		return
+	}
+
+	name := filepath.Base(header.Filename)
+	objectKey := fmt.Sprintf("inbound-cases/%s/%d/%s_%s", cs.Warehouse, cs.ID,
+		time.Now().Format("20060102_150405"), unsafeFileChars.ReplaceAllString(name, "_"))
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := minioClient.UploadFile(objectKey, file, header.Size, contentType); err != nil {
+		log.Printf("[CASE] Failed to upload attachment for case %d: %v", cs.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload attachment"})
+		return
+	}
+
+	username := c.GetString("username")
+	attachment := models.InboundCaseAttachment{
+		CaseID:      cs.ID,
+		FileName:    name,
+		ObjectKey:   objectKey,
+		ContentType: contentType,
+		Size:        header.Size,
+		UploadedBy:  username,
+	}
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attachment).Error; err != nil {
+			return err
+		}
+		_, err := addCaseComment(tx, cs.ID, CaseCommentNote, "Attached "+name, username)
+		return err
+	})
+	if err != nil {
+		// Clean up the uploaded file on DB failure
+		_ = minioClient.DeleteFile(objectKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// findAttachment loads an attachment of the case in the route
+func findAttachment(c *gin.Context) (models.InboundCaseAttachment, bool) {
+	var attachment models.InboundCaseAttachment
+	cs, ok := findCase(c)
+	if !ok {
+		return attachment, false
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return attachment, false
+	}
+	if err := warehouseDB(c).Where("case_id = ?", cs.ID).First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return attachment, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+// GetAttachment returns a presigned URL of an attachment; download=true
+// makes the browser save it instead of showing it
+func (h *InboundCaseHandler) GetAttachment(c *gin.Context) {
+	attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	disposition := ""
+	if c.Query("download") == "true" {
+		disposition = fmt.Sprintf("attachment; filename=\"%s\"", attachment.FileName)
+	}
+	url, err := minioClient.GetFileURL(attachment.ObjectKey, disposition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate attachment URL"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// DeleteAttachment removes an attachment and its file
+func (h *InboundCaseHandler) DeleteAttachment(c *gin.Context) {
+	attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	username := c.GetString("username")
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		_, err := addCaseComment(tx, attachment.CaseID, CaseCommentNote, "Removed attachment "+attachment.FileName, username)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := minioClient.DeleteFile(attachment.ObjectKey); err != nil {
+		log.Printf("[CASE] Warning: failed to delete attachment from MinIO: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{CaseOpen, CaseInvestigating, true},
+		{CaseOpen, CaseResolved, true},
+		{CaseOpen, CaseClosed, true},
+		{CaseOpen, CaseOpen, false},
+		{CaseInvestigating, CaseWaitingSupplier, true},
+		{CaseInvestigating, CaseOpen, false},
+		{CaseWaitingSupplier, CaseInvestigating, true},
+		{CaseWaitingSupplier, CaseResolved, true},
+		{CaseResolved, CaseInvestigating, true},
+		{CaseResolved, CaseClosed, true},
+		{CaseResolved, CaseOpen, false},
+		{CaseClosed, CaseInvestigating, false},
+		{CaseClosed, CaseOpen, false},
+		{CaseOpen, "Done", false},
+		{"Done", CaseClosed, false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestEscalationLevel(t *testing.T) {
+	idx := caseTypeIndex{}
+	for _, ct := range []models.InboundCaseType{
+		{Code: "DMG", Name: "Damage", Warehouse: "WH-JC", DueHours: 24, EscalateEveryHours: 12},
+		{Code: "QTY", Warehouse: "WH-JC", DueHours: 48},
+	} {
+		idx[caseTypeKey(ct.Warehouse, ct.Code)] = ct
+		if ct.Name != "" {
+			idx[caseTypeKey(ct.Warehouse, ct.Name)] = ct
+		}
+	}
+	date := models.ParseFlexDate("2026-10-01 08:00:00")
+	hoursAfter := func(h int) time.Time { return date.Time.Add(time.Duration(h) * time.Hour) }
+
+	tests := []struct {
+		name  string
+		cs    models.InboundCase
+		now   time.Time
+		level int
+	}{
+		{"not yet due", models.InboundCase{Case: "DMG", Date: date}, hoursAfter(24), 0},
+		{"just overdue", models.InboundCase{Case: "DMG", Date: date}, hoursAfter(25), 1},
+		{"named by the type name", models.InboundCase{Case: " damage ", Date: date}, hoursAfter(25), 1},
+		{"one more every 12 hours", models.InboundCase{Case: "DMG", Date: date}, hoursAfter(24 + 12), 2},
+		{"capped", models.InboundCase{Case: "DMG", Date: date}, hoursAfter(24 + 100), maxEscalationLevel},
+		{"type without escalation hours", models.InboundCase{Case: "QTY", Date: date}, hoursAfter(200), 1},
+		{"due date wins over the type", models.InboundCase{Case: "DMG", Date: date, DueDate: models.ParseFlexDate("2026-10-05")}, hoursAfter(48), 0},
+		{"unknown type without due date", models.InboundCase{Case: "Other", Date: date}, hoursAfter(1000), 0},
+		{"resolved case", models.InboundCase{Case: "DMG", Date: date, Status: CaseResolved}, hoursAfter(100), 0},
+		{"waiting supplier is still open", models.InboundCase{Case: "DMG", Date: date, Status: CaseWaitingSupplier}, hoursAfter(25), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cs.Warehouse = "WH-JC"
+			if got := idx.escalationLevel(viewCase(tt.cs, idx, tt.now)); got != tt.level {
+				t.Errorf("level %d, want %d", got, tt.level)
+			}
+		})
+	}
+}
+
+func TestCaseWorkflowFieldsProtected(t *testing.T) {
+	db := openTestDB(t, &models.InboundCase{}, &models.AuditLog{}, &models.SyncSnapshot{})
+	h := NewInboundCaseHandler().cases
+	date := models.ParseFlexDate("2026-10-01")
+	resolved := models.ParseFlexDate("2026-10-02")
+	sent := func(id uint, qty int) models.InboundCase {
+		return models.InboundCase{
+			ID: id, Date: date, Case: "DMG", Qty: qty,
+			Status: CaseClosed, ClosedAt: resolved, EscalationLevel: 2, EscalatedAt: resolved,
+		}
+	}
+	stored := func(t *testing.T) map[uint]models.InboundCase {
+		t.Helper()
+		var rows []models.InboundCase
+		if err := db.Order("id").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		byID := map[uint]models.InboundCase{}
+		for _, r := range rows {
+			byID[r.ID] = r
+		}
+		return byID
+	}
+	fresh := func(t *testing.T, cs models.InboundCase) {
+		t.Helper()
+		if cs.Status != CaseOpen || cs.ClosedAt.Valid || cs.EscalationLevel != 0 || cs.EscalatedAt.Valid {
+			t.Errorf("case %d: status %q closed %q level %d escalated %q, want a fresh case",
+				cs.ID, cs.Status, cs.ClosedAt, cs.EscalationLevel, cs.EscalatedAt)
+		}
+	}
+
+	if _, err := h.importBatches(db, []models.InboundCase{sent(0, 1), sent(0, 2)}, ImportInsertOnly, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	rows := stored(t)
+	if len(rows) != 2 {
+		t.Fatalf("imported %d cases, want 2", len(rows))
+	}
+	for _, cs := range rows {
+		fresh(t, cs)
+	}
+
+	if err := db.Model(&models.InboundCase{}).Where("id = ?", 1).
+		Updates(map[string]interface{}{"status": CaseResolved, "resolved_at": "2026-10-03", "escalation_level": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A sync keeps the workflow of the cases it replaces and starts new ones fresh
+	if _, err := h.replaceAll(db, []models.InboundCase{sent(1, 5), sent(0, 6)}, "tester", false); err != nil {
+		t.Fatal(err)
+	}
+	rows = stored(t)
+	if len(rows) != 2 {
+		t.Fatalf("synced %d cases, want 2", len(rows))
+	}
+	kept := rows[1]
+	if kept.Qty != 5 || kept.Status != CaseResolved || kept.ResolvedAt.String() != "2026-10-03" || kept.EscalationLevel != 1 || kept.ClosedAt.Valid {
+		t.Errorf("case 1 after sync: %+v", kept)
+	}
+	for id, cs := range rows {
+		if id != 1 {
+			fresh(t, cs)
+		}
+	}
+
+	// A snapshot restore brings back the stored values of the rows it restores
+	snapshot := []models.InboundCase{sent(1, 7), sent(9, 8)}
+	snapshot[0].Status, snapshot[0].ClosedAt = CaseOpen, models.FlexDate{}
+	if _, err := h.replaceAll(db, snapshot, "tester", true); err != nil {
+		t.Fatal(err)
+	}
+	rows = stored(t)
+	if rows[1].Status != CaseResolved || rows[1].Qty != 7 {
+		t.Errorf("case 1 after restore: status %q qty %d, want Resolved 7", rows[1].Status, rows[1].Qty)
+	}
+	if rows[9].Status != CaseClosed || rows[9].EscalationLevel != 2 {
+		t.Errorf("case 9 after restore: status %q level %d, want the snapshot's", rows[9].Status, rows[9].EscalationLevel)
+	}
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dock-to-stock stages of an arrival, in order
+const (
+	SlaStageUnloading   = "unloading"     // arrival_time to finish_unloading_time
+	SlaStageReceive     = "receive"       // finish_unloading_time to the first receive
+	SlaStagePutaway     = "putaway"       // first receive to putaway complete
+	SlaStageDockToStock = "dock_to_stock" // arrival_time to putaway complete
+)
+
+// SlaStages lists the stages in order
+var SlaStages = []string{SlaStageUnloading, SlaStageReceive, SlaStagePutaway, SlaStageDockToStock}
+
+// SLA states of a stage, from best to worst. A stage without a target or
+// that has not started has no state.
+const (
+	SlaOnTime   = "on_time"
+	SlaAtRisk   = "at_risk"
+	SlaBreached = "breached"
+)
+
+var slaStateRank = map[string]int{"": 0, SlaOnTime: 1, SlaAtRisk: 2, SlaBreached: 3}
+
+// SlaStage is the lead time of one stage of an arrival. Minutes is the time
+// taken, or the time elapsed so far while the stage is still open.
+type SlaStage struct {
+	Stage         string          `json:"stage"`
+	Start         models.FlexDate `json:"start"`
+	End           models.FlexDate `json:"end"`
+	Minutes       int             `json:"minutes"`
+	TargetMinutes int             `json:"target_minutes"`
+	Done          bool            `json:"done"`
+	State         string          `json:"state"`
+}
+
+// ArrivalSla is an arrival with its stage lead times against the matching SLA
+type ArrivalSla struct {
+	ArrivalStatus
+	SlaID  *uint      `json:"sla_id"`
+	Stages []SlaStage `json:"stages"`
+	State  string     `json:"sla_state"`
+}
+
+// wallClockNow returns the server's local wall-clock time labelled as UTC,
+// the way FlexDate reads the zone-less timestamps stored in the database
+func wallClockNow() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+}
+
+// matchSla picks the most specific SLA for a brand and urgency: brand and
+// urgency beat brand only, which beats urgency only, which beats the default
+func matchSla(slas []models.InboundSla, brand, urgensi string) *models.InboundSla {
+	brand = strings.TrimSpace(brand)
+	urgensi = strings.TrimSpace(urgensi)
+	var best *models.InboundSla
+	bestScore := -1
+	for i := range slas {
+		s := &slas[i]
+		score := 0
+		if b := strings.TrimSpace(s.Brand); b != "" {
+			if !strings.EqualFold(b, brand) {
+				continue
+			}
+			score += 2
+		}
+		if u := strings.TrimSpace(s.Urgensi); u != "" {
+			if !strings.EqualFold(u, urgensi) {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best
+}
+
+// measureStage times a stage from start to end. A stage that is not done is
+// measured up to now; one that has not started is left at zero.
+func measureStage(stage string, start, end models.FlexDate, done bool, target, atRiskPercent int, now time.Time) SlaStage {
+	s := SlaStage{Stage: stage, Start: start, TargetMinutes: target}
+	if !start.Valid {
+		return s
+	}
+	until := now
+	if done && end.Valid {
+		s.End, s.Done = end, true
+		until = end.Time
+	}
+	if d := until.Sub(start.Time); d > 0 {
+		s.Minutes = int(d / time.Minute)
+	}
+	switch {
+	case target == 0:
+	case s.Minutes > target:
+		s.State = SlaBreached
+	case !s.Done && s.Minutes*100 >= target*atRiskPercent:
+		s.State = SlaAtRisk
+	default:
+		s.State = SlaOnTime
+	}
+	return s
+}
+
+// evaluateSla measures every stage of an arrival against its SLA
+func evaluateSla(a ArrivalStatus, sla *models.InboundSla, now time.Time) ArrivalSla {
+	var target models.InboundSla
+	out := ArrivalSla{ArrivalStatus: a}
+	if sla != nil {
+		target = *sla
+		out.SlaID = &sla.ID
+	}
+	putaway := a.Status == ArrivalCompleted
+	out.Stages = []SlaStage{
+		measureStage(SlaStageUnloading, a.ArrivalTime, a.FinishUnloadingTime, a.FinishUnloadingTime.Valid, target.UnloadingMinutes, target.AtRiskPercent, now),
+		measureStage(SlaStageReceive, a.FinishUnloadingTime, a.FirstReceive, a.FirstReceive.Valid, target.ReceiveMinutes, target.AtRiskPercent, now),
+		measureStage(SlaStagePutaway, a.FirstReceive, a.LastPutaway, putaway, target.PutawayMinutes, target.AtRiskPercent, now),
+		measureStage(SlaStageDockToStock, a.ArrivalTime, a.LastPutaway, putaway, target.DockToStockMinutes, target.AtRiskPercent, now),
+	}
+	for _, s := range out.Stages {
+		if slaStateRank[s.State] > slaStateRank[out.State] {
+			out.State = s.State
+		}
+	}
+	return out
+}
+
+// slaMaxDays bounds the startDate..endDate range of the SLA endpoints, which
+// measure every arrival in the range in memory
+const slaMaxDays = 93
+
+// slaDateRange reads the required startDate and endDate (YYYY-MM-DD)
+func slaDateRange(c *gin.Context) (string, string, error) {
+	startDate, endDate := c.Query("startDate"), c.Query("endDate")
+	if startDate == "" || endDate == "" {
+		return "", "", fmt.Errorf("startDate and endDate are required")
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", "", fmt.Errorf("startDate must be YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", "", fmt.Errorf("endDate must be YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return "", "", fmt.Errorf("endDate must not be before startDate")
+	}
+	if end.Sub(start) >= slaMaxDays*24*time.Hour {
+		return "", "", fmt.Errorf("date range must not exceed %d days", slaMaxDays)
+	}
+	return startDate, endDate, nil
+}
+
+// loadArrivalSlas evaluates the arrivals matching the status query of the
+// request against the SLAs of the active warehouse. The date range (on the
+// arrival date unless dateField says otherwise) and brand are applied in SQL
+// so only those arrivals are measured. It writes the error response itself
+// and returns false when that fails.
+func loadArrivalSlas(h *ResourceHandler[models.Arrival], c *gin.Context) ([]ArrivalSla, bool) {
+	startDate, endDate, err := slaDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	query, err := arrivalStatusQuery(h, c)
+	if err != nil {
+		respondQueryError(c, err)
+		return nil, false
+	}
+	if c.Query("dateField") == "" {
+		query = query.Where("arrivals.date >= ? AND arrivals.date <= ?", startDate, endDate+" 23:59:59")
+	}
+	if brand := strings.TrimSpace(c.Query("brand")); brand != "" {
+		query = query.Where("LOWER(TRIM(arrivals.brand)) = ?", strings.ToLower(brand))
+	}
+	keys, err := h.sortKeys(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return nil, false
+	}
+	var arrivals []ArrivalStatus
+	if err := applySort(query, keys).Find(&arrivals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	var slas []models.InboundSla
+	if err := warehouseDB(c).Find(&slas).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	now := wallClockNow()
+	out := make([]ArrivalSla, len(arrivals))
+	for i, a := range arrivals {
+		out[i] = evaluateSla(a, matchSla(slas, a.Brand, a.Urgensi), now)
+	}
+	return out, true
+}
+
+// stageOf returns the named stage of an evaluated arrival
+func (a ArrivalSla) stageOf(stage string) SlaStage {
+	for _, s := range a.Stages {
+		if s.Stage == stage {
+			return s
+		}
+	}
+	return SlaStage{Stage: stage}
+}
+
+// parseSlaList splits a comma-separated parameter and checks each value
+func parseSlaList(c *gin.Context, param string, valid []string) (map[string]bool, error) {
+	raw := c.Query(param)
+	if raw == "" {
+		return nil, nil
+	}
+	set := map[string]bool{}
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		found := false
+		for _, ok := range valid {
+			if v == ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown %s %q, expected one of %s", param, v, strings.Join(valid, ", "))
+		}
+		set[v] = true
+	}
+	return set, nil
+}
+
+// ListArrivalSla serves GET /arrivals/sla: the arrivals that breached or are
+// at risk of breaching their SLA. state=on_time,at_risk,breached picks the
+// states (default at_risk,breached) and stage limits the check to one stage.
+// Takes the same parameters as ListArrivalStatus plus brand, and requires
+// startDate and endDate; without page it returns a flat array, with
+// page/pageSize {"data", "total"}.
+func ListArrivalSla(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		states, err := parseSlaList(c, "state", []string{SlaOnTime, SlaAtRisk, SlaBreached})
+		if err == nil && states == nil {
+			states = map[string]bool{SlaAtRisk: true, SlaBreached: true}
+		}
+		var stages map[string]bool
+		if err == nil {
+			stages, err = parseSlaList(c, "stage", SlaStages)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		all, ok := loadArrivalSlas(h, c)
+		if !ok {
+			return
+		}
+
+		items := []ArrivalSla{}
+		for _, a := range all {
+			state := a.State
+			if stages != nil {
+				state = ""
+				for stage := range stages {
+					if s := a.stageOf(stage).State; slaStateRank[s] > slaStateRank[state] {
+						state = s
+					}
+				}
+			}
+			if states[state] {
+				items = append(items, a)
+			}
+		}
+
+		pageStr := c.Query("page")
+		if pageStr == "" {
+			c.JSON(http.StatusOK, items)
+			return
+		}
+		page, _ := strconv.Atoi(pageStr)
+		if page < 1 {
+			page = 1
+		}
+		pageSize := pageSizeParam(c)
+		total := len(items)
+		start := min((page-1)*pageSize, total)
+		end := min(start+pageSize, total)
+		c.JSON(http.StatusOK, gin.H{"data": items[start:end], "total": total})
+	}
+}
+
+// SlaStageStats sums up the lead times of one stage. Minutes figures cover
+// finished stages only; Open counts stages still running.
+type SlaStageStats struct {
+	Count      int     `json:"count"`
+	Open       int     `json:"open"`
+	AvgMinutes float64 `json:"avg_minutes"`
+	MinMinutes int     `json:"min_minutes"`
+	MaxMinutes int     `json:"max_minutes"`
+	OnTime     int     `json:"on_time"`
+	AtRisk     int     `json:"at_risk"`
+	Breached   int     `json:"breached"`
+	total      int
+}
+
+// SlaGroupStats is the stage statistics of the arrivals of one brand or day
+type SlaGroupStats struct {
+	Key      string                    `json:"key"`
+	Arrivals int                       `json:"arrivals"`
+	Stages   map[string]*SlaStageStats `json:"stages"`
+}
+
+// add counts a measured stage
+func (s *SlaStageStats) add(stage SlaStage) {
+	if !stage.Start.Valid {
+		return
+	}
+	switch stage.State {
+	case SlaOnTime:
+		s.OnTime++
+	case SlaAtRisk:
+		s.AtRisk++
+	case SlaBreached:
+		s.Breached++
+	}
+	if !stage.Done {
+		s.Open++
+		return
+	}
+	if s.Count == 0 || stage.Minutes < s.MinMinutes {
+		s.MinMinutes = stage.Minutes
+	}
+	if stage.Minutes > s.MaxMinutes {
+		s.MaxMinutes = stage.Minutes
+	}
+	s.Count++
+	s.total += stage.Minutes
+	s.AvgMinutes = float64(s.total) / float64(s.Count)
+}
+
+// ArrivalSlaStats serves GET /arrivals/sla/stats: per-stage lead time
+// statistics grouped by brand (group=brand, the default) or by arrival date
+// (group=day). Takes the same parameters as ListArrivalSla.
+func ArrivalSlaStats(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := c.DefaultQuery("group", "brand")
+		if group != "brand" && group != "day" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group must be brand or day"})
+			return
+		}
+		all, ok := loadArrivalSlas(h, c)
+		if !ok {
+			return
+		}
+
+		groups := map[string]*SlaGroupStats{}
+		for _, a := range all {
+			key := strings.TrimSpace(a.Brand)
+			if group == "day" {
+				key = ""
+				if a.Date.Valid {
+					key = a.Date.Time.Format("2006-01-02")
+				}
+			}
+			g := groups[key]
+			if g == nil {
+				g = &SlaGroupStats{Key: key, Stages: make(map[string]*SlaStageStats, len(SlaStages))}
+				for _, stage := range SlaStages {
+					g.Stages[stage] = &SlaStageStats{}
+				}
+				groups[key] = g
+			}
+			g.Arrivals++
+			for _, s := range a.Stages {
+				g.Stages[s.Stage].add(s)
+			}
+		}
+
+		out := make([]*SlaGroupStats, 0, len(groups))
+		for _, g := range groups {
+			out = append(out, g)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+		c.JSON(http.StatusOK, gin.H{"group": group, "data": out})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicy is read once from the environment:
+//   - PASSWORD_MIN_LENGTH (default 8)
+//   - PASSWORD_MIN_CLASSES: how many of lowercase, uppercase, digit and
+//     symbol must appear (default 3)
+//   - PASSWORD_HISTORY: how many of the latest passwords, the current one
+//     included, cannot be reused (default 5)
+//   - LOGIN_MAX_FAILURES: failed logins before the account locks (default 5)
+//   - LOGIN_LOCKOUT_MINUTES: how long the lock lasts (default 15)
+type PasswordPolicy struct {
+	MinLength   int
+	MinClasses  int
+	History     int
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+var (
+	policyOnce     sync.Once
+	passwordPolicy PasswordPolicy
+)
+
+// envInt reads a non-negative integer from the environment
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// currentPasswordPolicy returns the policy from the environment
+func currentPasswordPolicy() PasswordPolicy {
+	policyOnce.Do(func() {
+		passwordPolicy = PasswordPolicy{
+			MinLength:   envInt("PASSWORD_MIN_LENGTH", 8),
+			MinClasses:  envInt("PASSWORD_MIN_CLASSES", 3),
+			History:     envInt("PASSWORD_HISTORY", 5),
+			MaxFailures: envInt("LOGIN_MAX_FAILURES", 5),
+			Lockout:     time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+		}
+	})
+	return passwordPolicy
+}
+
+// checkPasswordPolicy lists the rules a new password breaks
+func checkPasswordPolicy(password, username string) []string {
+	policy := currentPasswordPolicy()
+	var problems []string
+	if len([]rune(password)) < policy.MinLength {
+		problems = append(problems, fmt.Sprintf("Password minimal %d karakter", policy.MinLength))
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < policy.MinClasses {
+		problems = append(problems, fmt.Sprintf("Password harus memuat minimal %d dari: huruf kecil, huruf besar, angka, simbol", policy.MinClasses))
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		problems = append(problems, "Password tidak boleh memuat username")
+	}
+	return problems
+}
+
+// passwordReused reports whether password matches one of the user's last
+// PASSWORD_HISTORY passwords, the current one included
+func passwordReused(tx *gorm.DB, user models.User, password string) (bool, error) {
+	history := currentPasswordPolicy().History
+	if history == 0 {
+		return false, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil {
+		return true, nil
+	}
+	if history == 1 {
+		return false, nil
+	}
+	var previous []models.PasswordHistory
+	if err := tx.Where("user_id = ?", user.ID).Order("id DESC").Limit(history - 1).Find(&previous).Error; err != nil {
+		return false, err
+	}
+	for _, p := range previous {
+		if bcrypt.CompareHashAndPassword([]byte(p.Hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rememberPassword moves the user's current hash into the history, which
+// keeps the PASSWORD_HISTORY-1 passwords before the new one
+func rememberPassword(tx *gorm.DB, user models.User) error {
+	keep := currentPasswordPolicy().History - 1
+	if keep > 0 {
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Hash: user.Password}).Error; err != nil {
+			return err
+		}
+	} else {
+		keep = 0
+	}
+	var ids []uint
+	if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+		Order("id DESC").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > keep {
+		return tx.Delete(&models.PasswordHistory{}, ids[keep:]).Error
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// setPasswordPolicy replaces the environment policy for the rest of the test
+func setPasswordPolicy(t *testing.T, policy PasswordPolicy) {
+	t.Helper()
+	saved := currentPasswordPolicy()
+	passwordPolicy = policy
+	t.Cleanup(func() { passwordPolicy = saved })
+}
+
+// setPassword stores a cheap bcrypt hash of password for the user
+func setPassword(t *testing.T, db *gorm.DB, user models.User, password string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&user).Update("password", string(hash)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// login posts a username and password to Login
+func login(t *testing.T, username, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	return callHandler(t, Login, nil, gin.H{"username": username, "password": password})
+}
+
+// changePassword calls ChangePassword on target as the given user and role
+func changePassword(t *testing.T, target, by models.User, current, next string) *httptest.ResponseRecorder {
+	t.Helper()
+	return callHandler(t, ChangePassword, gin.Params{{Key: "id", Value: fmt.Sprint(target.ID)}},
+		gin.H{"current_password": current, "new_password": next}, gin.H{"user_id": by.ID, "role": by.Role})
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	setPasswordPolicy(t, PasswordPolicy{MinLength: 8, MinClasses: 3, History: 5})
+	tests := []struct {
+		password string
+		problems int
+	}{
+		{"Gudang-2024", 0},
+		{"gudang2024X", 0},
+		{"Gd-1", 1},
+		{"gudangjakarta", 1},
+		{"GUDANG2024", 1},
+		{"abc", 2},
+		{"Alice-2024", 1},
+		{"xALICEx-9", 1},
+		{"Ä-gudang-9", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := checkPasswordPolicy(tt.password, "alice"); len(got) != tt.problems {
+				t.Errorf("problems %q, want %d", got, tt.problems)
+			}
+		})
+	}
+
+	setPasswordPolicy(t, PasswordPolicy{MinLength: 4, MinClasses: 1})
+	if got := checkPasswordPolicy("gudang", ""); got != nil {
+		t.Errorf("relaxed policy: problems %q, want none", got)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	setPasswordPolicy(t, PasswordPolicy{MaxFailures: 3, Lockout: time.Minute})
+	db, user := openSessionDB(t)
+	setPassword(t, db, user, "Gudang-2024")
+	state := func() models.User {
+		var u models.User
+		if err := db.First(&u, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := login(t, "alice", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: %d, want 401", w.Code)
+		}
+	}
+	if u := state(); u.FailedAttempts != 2 || u.LockedUntil != nil {
+		t.Fatalf("after 2 failures: %d attempts, locked until %v", u.FailedAttempts, u.LockedUntil)
+	}
+	// A success below the threshold starts the count over
+	if w := login(t, "alice", "Gudang-2024"); w.Code != http.StatusOK {
+		t.Fatalf("correct password: %d %s", w.Code, w.Body)
+	}
+	if u := state(); u.FailedAttempts != 0 {
+		t.Errorf("after a success: %d attempts, want 0", u.FailedAttempts)
+	}
+
+	for i := 0; i < 3; i++ {
+		login(t, "alice", "wrong")
+	}
+	u := state()
+	if u.LockedUntil == nil || u.LockedUntil.Before(time.Now().Add(50*time.Second)) {
+		t.Fatalf("after 3 failures: locked until %v, want about a minute from now", u.LockedUntil)
+	}
+	if w := login(t, "alice", "Gudang-2024"); w.Code != http.StatusLocked {
+		t.Errorf("correct password while locked: %d, want 423", w.Code)
+	}
+
+	// Once the lock expires the right password gets in and clears it
+	if err := db.Model(&user).Update("locked_until", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := login(t, "alice", "Gudang-2024"); w.Code != http.StatusOK {
+		t.Fatalf("after the lock expired: %d %s", w.Code, w.Body)
+	}
+	if u := state(); u.FailedAttempts != 0 || u.LockedUntil != nil {
+		t.Errorf("after an expired lock: %d attempts, locked until %v", u.FailedAttempts, u.LockedUntil)
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	setPasswordPolicy(t, PasswordPolicy{MinLength: 8, MinClasses: 3, History: 3})
+	db, user := openSessionDB(t)
+	if err := db.AutoMigrate(&models.PasswordHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	setPassword(t, db, user, "Gudang-0000")
+
+	current := "Gudang-0000"
+	change := func(next string) int {
+		w := changePassword(t, user, user, current, next)
+		if w.Code == http.StatusOK {
+			current = next
+		}
+		return w.Code
+	}
+	for _, next := range []string{"Gudang-1111", "Gudang-2222"} {
+		if code := change(next); code != http.StatusOK {
+			t.Fatalf("change to %s: %d", next, code)
+		}
+	}
+	// The last 3 passwords, the current one included, are off limits
+	for _, old := range []string{"Gudang-2222", "Gudang-1111", "Gudang-0000"} {
+		if code := change(old); code != http.StatusBadRequest {
+			t.Errorf("reuse of %s: %d, want 400", old, code)
+		}
+	}
+	if code := change("short"); code != http.StatusBadRequest {
+		t.Errorf("password against the policy: %d, want 400", code)
+	}
+	if code := change("Gudang-3333"); code != http.StatusOK {
+		t.Fatalf("change to a new password: %d", code)
+	}
+	var kept int64
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&kept)
+	if kept != 2 {
+		t.Errorf("%d passwords in the history, want 2", kept)
+	}
+	if code := change("Gudang-0000"); code != http.StatusOK {
+		t.Errorf("reuse of the 4th latest password: %d, want 200", code)
+	}
+	if code := changePassword(t, user, user, "wrong", "Gudang-4444").Code; code != http.StatusUnauthorized {
+		t.Errorf("wrong current password: %d, want 401", code)
+	}
+}
+
+func TestForcedPasswordChange(t *testing.T) {
+	setPasswordPolicy(t, PasswordPolicy{MinLength: 8, MinClasses: 3, History: 3})
+	db := openTestDB(t, &models.User{}, &models.RefreshToken{}, &models.Role{}, &models.Permission{}, &models.PasswordHistory{})
+	middleware.InvalidatePermissions()
+	if err := db.Create(&models.Role{Name: "supervisor", IsSuper: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SEED_PASSWORD_SUPERVISOR", "Seed-Pass-1")
+	database.SeedDefaultUsers()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", middleware.AuthRequired(), middleware.PasswordChanged(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	loginAs := func(username, password string) LoginResponse {
+		t.Helper()
+		w := login(t, username, password)
+		var resp LoginResponse
+		if w.Code != http.StatusOK {
+			t.Fatalf("login %s: %d %s", username, w.Code, w.Body)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// A seeded account has to change its password on first login
+	first := loginAs("supervisor", "Seed-Pass-1")
+	if !first.MustChangePassword {
+		t.Error("first login of a seeded account: must_change_password false")
+	}
+	if code := get(first.Token); code != http.StatusForbidden {
+		t.Errorf("route before the change: %d, want 403", code)
+	}
+	var supervisor, staff models.User
+	if err := db.Where("username = ?", "supervisor").First(&supervisor).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := changePassword(t, supervisor, supervisor, "Seed-Pass-1", "Gudang-Baru-1"); w.Code != http.StatusOK {
+		t.Fatalf("own change: %d %s", w.Code, w.Body)
+	}
+	second := loginAs("supervisor", "Gudang-Baru-1")
+	if second.MustChangePassword || get(second.Token) != http.StatusOK {
+		t.Errorf("login after the change: must_change_password %v", second.MustChangePassword)
+	}
+
+	// A password set by a supervisor must be changed by its owner
+	if err := db.Where("username = ?", "admin.inbound").First(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&staff).Update("must_change_password", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := changePassword(t, staff, supervisor, "", "Gudang-Reset-1"); w.Code != http.StatusOK {
+		t.Fatalf("reset by supervisor: %d %s", w.Code, w.Body)
+	}
+	if reset := loginAs("admin.inbound", "Gudang-Reset-1"); !reset.MustChangePassword {
+		t.Error("login after a reset by a supervisor: must_change_password false")
+	}
+	var got []string
+	var users []models.User
+	db.Where("must_change_password = ?", true).Order("username").Find(&users)
+	for _, u := range users {
+		got = append(got, u.Username)
+	}
+	if want := []string{"admin.inbound", "admin.inventory", "key.account", "leader"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts that must change their password %q, want %q", got, want)
+	}
+}
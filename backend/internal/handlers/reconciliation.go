@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Reconciliation results of a PO receipt
+const (
+	ReconMatched     = "Matched"
+	ReconOver        = "Over Receipt"
+	ReconShort       = "Short Receipt"
+	ReconNotReceived = "Not Received"
+	ReconNotOnPO     = "Not On PO"
+)
+
+// ReconResults lists the reconciliation results
+var ReconResults = []string{ReconMatched, ReconOver, ReconShort, ReconNotReceived, ReconNotOnPO}
+
+// receiveTypes matches the transactions that count as received
+const receiveTypes = "LOWER(TRIM(operate_type)) IN ('receive', 'receiving')"
+
+// reconChunk bounds the receipt numbers sent in one IN list
+const reconChunk = 1000
+
+// ReconSku is the qty received of one SKU on a receipt
+type ReconSku struct {
+	Sku         string `json:"sku"`
+	ReceivedQty int    `json:"received_qty"`
+}
+
+// PoReconciliation compares what a PO receipt was expected to bring with what
+// was received. Variance is received minus PO qty, in units and in percent of
+// the PO qty; the plan variance does the same against the plan qty. Receipts
+// booked in transactions without any arrival are reported as Not On PO.
+type PoReconciliation struct {
+	ArrivalID       *uint                     `json:"arrival_id"`
+	Date            models.FlexDate           `json:"date"`
+	PoNo            string                    `json:"po_no"`
+	ReceiptNo       string                    `json:"receipt_no"`
+	Brand           string                    `json:"brand"`
+	Supplier        string                    `json:"supplier"`
+	PoQty           int                       `json:"po_qty"`
+	PlanQty         int                       `json:"plan_qty"`
+	ReceivedQty     int                       `json:"received_qty"`
+	Variance        int                       `json:"variance"`
+	VariancePct     float64                   `json:"variance_pct"`
+	PlanVariance    int                       `json:"plan_variance"`
+	PlanVariancePct float64                   `json:"plan_variance_pct"`
+	Result          string                    `json:"result"`
+	Skus            []ReconSku                `json:"skus"`
+	RejectedQty     int                       `json:"rejected_qty"`
+	CaseQty         int                       `json:"case_qty"`
+	Rejections      []models.InboundRejection `json:"rejections"`
+	Cases           []models.InboundCase      `json:"cases"`
+}
+
+// receiptKey normalizes a receipt number the way transactions are matched
+func receiptKey(receiptNo string) string {
+	return strings.ToLower(strings.TrimSpace(receiptNo))
+}
+
+// variancePct returns variance as a percentage of base, rounded to 2 decimals.
+// Anything against a zero base is a 100% variance.
+func variancePct(variance, base int) float64 {
+	if variance == 0 {
+		return 0
+	}
+	if base == 0 {
+		return math.Copysign(100, float64(variance))
+	}
+	return math.Round(float64(variance)*10000/float64(base)) / 100
+}
+
+// reconcile sets the variances and result of a row. Variances within
+// tolerance percent of the PO qty count as matched.
+func (r *PoReconciliation) reconcile(tolerance float64) {
+	r.Variance = r.ReceivedQty - r.PoQty
+	r.VariancePct = variancePct(r.Variance, r.PoQty)
+	r.PlanVariance = r.ReceivedQty - r.PlanQty
+	r.PlanVariancePct = variancePct(r.PlanVariance, r.PlanQty)
+	switch {
+	case r.ArrivalID == nil:
+		r.Result = ReconNotOnPO
+	case r.ReceivedQty == 0 && r.PoQty > 0:
+		r.Result = ReconNotReceived
+	case math.Abs(r.VariancePct) <= tolerance:
+		r.Result = ReconMatched
+	case r.Variance > 0:
+		r.Result = ReconOver
+	default:
+		r.Result = ReconShort
+	}
+}
+
+// receiptSkuRow is the received qty of a SKU on a receipt
+type receiptSkuRow struct {
+	ReceiptKey string
+	ReceiptNo  string
+	Sku        string
+	Qty        int
+	Date       models.FlexDate
+}
+
+// receiptSkusQuery sums the received qty per receipt and SKU
+func receiptSkusQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Transaction{}).
+		Select("LOWER(TRIM(receipt_no)) AS receipt_key, MIN(TRIM(receipt_no)) AS receipt_no, TRIM(sku) AS sku, SUM(qty) AS qty, MIN(date) AS date").
+		Where(receiveTypes).
+		Where("TRIM(receipt_no) <> ''").
+		Group("LOWER(TRIM(receipt_no)), TRIM(sku)")
+}
+
+// chunkKeys splits receipt keys into IN-list sized chunks
+func chunkKeys(keys []string) [][]string {
+	var chunks [][]string
+	for len(keys) > reconChunk {
+		chunks = append(chunks, keys[:reconChunk])
+		keys = keys[reconChunk:]
+	}
+	if len(keys) > 0 {
+		chunks = append(chunks, keys)
+	}
+	return chunks
+}
+
+// creditReceipt credits the SKU lines of a receipt to the rows of the
+// arrivals sharing it. A receipt split over several arrivals is spread over
+// them oldest first, each taking up to its PO qty and the last one whatever
+// is left, so the received qty is counted once. SKU lines are split where
+// they straddle two arrivals.
+func creditReceipt(rows []PoReconciliation, idx []int, lines []receiptSkuRow) {
+	if len(idx) == 0 {
+		return
+	}
+	order := append([]int(nil), idx...)
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := rows[order[a]].ArrivalID, rows[order[b]].ArrivalID
+		return ra != nil && rb != nil && *ra < *rb
+	})
+	lines = append([]receiptSkuRow(nil), lines...)
+	sort.SliceStable(lines, func(a, b int) bool { return lines[a].Sku < lines[b].Sku })
+
+	k := 0
+	for _, s := range lines {
+		qty := s.Qty
+		for {
+			r := &rows[order[k]]
+			take := qty
+			if k < len(order)-1 {
+				room := r.PoQty - r.ReceivedQty
+				if room <= 0 || qty < 0 {
+					k++
+					continue
+				}
+				take = min(qty, room)
+			}
+			r.ReceivedQty += take
+			r.Skus = append(r.Skus, ReconSku{Sku: s.Sku, ReceivedQty: take})
+			qty -= take
+			if qty == 0 {
+				break
+			}
+		}
+	}
+}
+
+// buildReconciliation loads the reconciliation rows for a request: one per
+// arrival matching the arrivals list parameters, followed by the receipts
+// without an arrival (unless unplanned=false, or search or filter narrow
+// the arrivals, since those receipts have no arrival fields to match).
+// It writes the error response itself and returns false when that fails.
+func buildReconciliation(h *ResourceHandler[models.Arrival], c *gin.Context) ([]PoReconciliation, bool) {
+	tolerance := 0.0
+	if v := c.Query("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tolerance must be a percentage"})
+			return nil, false
+		}
+		tolerance = t
+	}
+	results, err := parseSlaList(c, "result", ReconResults)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	query, err := h.listQuery(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return nil, false
+	}
+	keys, err := h.sortKeys(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return nil, false
+	}
+	rows, err := reconcileArrivals(c, applySort(query, keys), tolerance, results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return rows, true
+}
+
+// reconcileArrivals builds the reconciliation rows of the arrivals query
+func reconcileArrivals(c *gin.Context, query *gorm.DB, tolerance float64, results map[string]bool) ([]PoReconciliation, error) {
+	var arrivals []models.Arrival
+	if err := query.Find(&arrivals).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]PoReconciliation, len(arrivals))
+	byKey := map[string][]int{}
+	for i, a := range arrivals {
+		id := a.ID
+		rows[i] = PoReconciliation{
+			ArrivalID: &id, Date: a.Date, PoNo: a.PoNo, ReceiptNo: a.ReceiptNo,
+			Brand: a.Brand, Supplier: a.Supplier, PoQty: a.PoQty, PlanQty: a.PlanQty,
+		}
+		if k := receiptKey(a.ReceiptNo); k != "" {
+			byKey[k] = append(byKey[k], i)
+		}
+	}
+	receiptKeys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		receiptKeys = append(receiptKeys, k)
+	}
+
+	db := warehouseDB(c)
+	var skus []receiptSkuRow
+	for _, chunk := range chunkKeys(receiptKeys) {
+		var part []receiptSkuRow
+		if err := receiptSkusQuery(db).Where("LOWER(TRIM(receipt_no)) IN ?", chunk).Scan(&part).Error; err != nil {
+			return nil, err
+		}
+		skus = append(skus, part...)
+	}
+
+	_, filtered := c.GetQuery("search")
+	for param := range c.Request.URL.Query() {
+		filtered = filtered || strings.HasPrefix(param, "filter[")
+	}
+	if c.Query("unplanned") != "false" && !filtered {
+		planned := db.Session(&gorm.Session{NewDB: true}).Model(&models.Arrival{}).
+			Select("LOWER(TRIM(receipt_no))").Where("TRIM(receipt_no) <> ''")
+		unplanned := receiptSkusQuery(db).Where("LOWER(TRIM(receipt_no)) NOT IN (?)", planned)
+		if c.Query("dateField") == "date" && c.Query("startDate") != "" && c.Query("endDate") != "" {
+			unplanned = unplanned.Where("date >= ? AND date <= ?", c.Query("startDate")+" 00:00:00", c.Query("endDate")+" 23:59:59")
+		}
+		var part []receiptSkuRow
+		if err := unplanned.Scan(&part).Error; err != nil {
+			return nil, err
+		}
+		for _, s := range part {
+			if _, ok := byKey[s.ReceiptKey]; !ok {
+				rows = append(rows, PoReconciliation{Date: s.Date, ReceiptNo: s.ReceiptNo})
+				byKey[s.ReceiptKey] = []int{len(rows) - 1}
+				receiptKeys = append(receiptKeys, s.ReceiptKey)
+			} else if i := byKey[s.ReceiptKey][0]; s.Date.Valid && (!rows[i].Date.Valid || s.Date.Time.Before(rows[i].Date.Time)) {
+				rows[i].Date = s.Date
+			}
+		}
+		skus = append(skus, part...)
+	}
+
+	skusByKey := map[string][]receiptSkuRow{}
+	for _, s := range skus {
+		skusByKey[s.ReceiptKey] = append(skusByKey[s.ReceiptKey], s)
+	}
+	for k, lines := range skusByKey {
+		creditReceipt(rows, byKey[k], lines)
+	}
+
+	for _, chunk := range chunkKeys(receiptKeys) {
+		var rejections []models.InboundRejection
+		if err := db.Where("LOWER(TRIM(receipt_no)) IN ?", chunk).Order("id").Find(&rejections).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rejections {
+			for _, i := range byKey[receiptKey(r.ReceiptNo)] {
+				rows[i].RejectedQty += r.Qty
+				rows[i].Rejections = append(rows[i].Rejections, r)
+			}
+		}
+		var cases []models.InboundCase
+		if err := db.Where("LOWER(TRIM(receipt_no)) IN ?", chunk).Order("id").Find(&cases).Error; err != nil {
+			return nil, err
+		}
+		for _, ic := range cases {
+			for _, i := range byKey[receiptKey(ic.ReceiptNo)] {
+				rows[i].CaseQty += ic.Qty
+				rows[i].Cases = append(rows[i].Cases, ic)
+			}
+		}
+	}
+
+	out := make([]PoReconciliation, 0, len(rows))
+	for _, r := range rows {
+		r.reconcile(tolerance)
+		if results != nil && !results[r.Result] {
+			continue
+		}
+		sort.Slice(r.Skus, func(i, j int) bool { return r.Skus[i].Sku < r.Skus[j].Sku })
+		if r.Skus == nil {
+			r.Skus = []ReconSku{}
+		}
+		if r.Rejections == nil {
+			r.Rejections = []models.InboundRejection{}
+		}
+		if r.Cases == nil {
+			r.Cases = []models.InboundCase{}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// ListReconciliation serves GET /arrivals/reconciliation: PO receipts with
+// their received qty per SKU, variance against PO and plan qty, and the
+// inbound rejections and cases of the receipt. Takes the arrivals list
+// parameters plus result=<result>[,...], tolerance (percent, default 0) and
+// unplanned=false to leave out receipts without an arrival. Without page it
+// returns a flat array, with page/pageSize {"data", "total", "summary"}.
+func ListReconciliation(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, ok := buildReconciliation(h, c)
+		if !ok {
+			return
+		}
+		pageStr := c.Query("page")
+		if pageStr == "" {
+			c.JSON(http.StatusOK, rows)
+			return
+		}
+		summary := make(map[string]int, len(ReconResults))
+		for _, r := range ReconResults {
+			summary[r] = 0
+		}
+		for _, r := range rows {
+			summary[r.Result]++
+		}
+		page, _ := strconv.Atoi(pageStr)
+		if page < 1 {
+			page = 1
+		}
+		pageSize := pageSizeParam(c)
+		start := min((page-1)*pageSize, len(rows))
+		end := min(start+pageSize, len(rows))
+		c.JSON(http.StatusOK, gin.H{"data": rows[start:end], "total": len(rows), "summary": summary})
+	}
+}
+
+// reconExportHeaders are the columns of a reconciliation export by level
+var reconExportHeaders = map[string][]string{
+	"receipt": {"date", "po_no", "receipt_no", "brand", "supplier", "po_qty", "plan_qty", "received_qty",
+		"variance", "variance_pct", "plan_variance", "plan_variance_pct", "result", "rejected_qty", "case_qty",
+		"rejection_ids", "case_ids"},
+	"sku": {"date", "po_no", "receipt_no", "brand", "supplier", "po_qty", "received_qty_receipt", "variance",
+		"variance_pct", "result", "sku", "received_qty", "rejected_qty", "case_qty"},
+}
+
+// reconExportRows flattens reconciliation rows for export. At sku level each
+// received SKU gets a row carrying the rejections and cases booked on it.
+func reconExportRows(rows []PoReconciliation, level string) [][]interface{} {
+	var out [][]interface{}
+	for _, r := range rows {
+		head := []interface{}{r.Date.String(), r.PoNo, r.ReceiptNo, r.Brand, r.Supplier, r.PoQty}
+		if level == "receipt" {
+			rejIDs := make([]string, len(r.Rejections))
+			for i, x := range r.Rejections {
+				rejIDs[i] = strconv.FormatUint(uint64(x.ID), 10)
+			}
+			caseIDs := make([]string, len(r.Cases))
+			for i, x := range r.Cases {
+				caseIDs[i] = strconv.FormatUint(uint64(x.ID), 10)
+			}
+			out = append(out, append(head, r.PlanQty, r.ReceivedQty, r.Variance, r.VariancePct,
+				r.PlanVariance, r.PlanVariancePct, r.Result, r.RejectedQty, r.CaseQty,
+				strings.Join(rejIDs, " "), strings.Join(caseIDs, " ")))
+			continue
+		}
+		skus := r.Skus
+		if len(skus) == 0 {
+			skus = []ReconSku{{}}
+		}
+		for _, s := range skus {
+			rejected, cased := 0, 0
+			for _, x := range r.Rejections {
+				if strings.EqualFold(strings.TrimSpace(x.Sku), s.Sku) {
+					rejected += x.Qty
+				}
+			}
+			for _, x := range r.Cases {
+				if strings.EqualFold(strings.TrimSpace(x.Sku), s.Sku) {
+					cased += x.Qty
+				}
+			}
+			row := append([]interface{}{}, head...)
+			out = append(out, append(row, r.ReceivedQty, r.Variance, r.VariancePct, r.Result,
+				s.Sku, s.ReceivedQty, rejected, cased))
+		}
+	}
+	return out
+}
+
+// ExportReconciliation serves GET /arrivals/reconciliation/export: the
+// reconciliation as CSV or XLSX (format=csv|xlsx, default csv) for supplier
+// claims, one row per receipt (level=receipt, the default) or per received
+// SKU (level=sku). Takes the same parameters as ListReconciliation.
+func ExportReconciliation(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+			return
+		}
+		level := c.DefaultQuery("level", "receipt")
+		headers, ok := reconExportHeaders[level]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "level must be receipt or sku"})
+			return
+		}
+		rows, ok := buildReconciliation(h, c)
+		if !ok {
+			return
+		}
+		data := reconExportRows(rows, level)
+
+		filename := fmt.Sprintf("po-reconciliation_%s.%s", time.Now().Format("20060102_150405"), format)
+		disposition := fmt.Sprintf("attachment; filename=\"%s\"", filename)
+
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", disposition)
+			c.Status(http.StatusOK)
+			w := csv.NewWriter(c.Writer)
+			if err := w.Write(headers); err != nil {
+				log.Printf("[EXPORT] po-reconciliation: %v", err)
+				return
+			}
+			for _, row := range data {
+				record := make([]string, len(row))
+				for i, v := range row {
+					record[i] = fmt.Sprint(v)
+				}
+				if err := w.Write(record); err != nil {
+					log.Printf("[EXPORT] po-reconciliation: %v", err)
+					return
+				}
+			}
+			w.Flush()
+			log.Printf("[EXPORT] po-reconciliation: %d rows as csv (by %s)", len(data), c.GetString("username"))
+			return
+		}
+
+		f := excelize.NewFile()
+		defer f.Close()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := sw.SetRow("A1", toCells(headers)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i, row := range data {
+			cell, _ := excelize.CoordinatesToCellName(1, i+2)
+			if err := sw.SetRow(cell, row); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := sw.Flush(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", disposition)
+		c.Status(http.StatusOK)
+		if err := f.Write(c.Writer); err != nil {
+			log.Printf("[EXPORT] po-reconciliation: %v", err)
+			return
+		}
+		log.Printf("[EXPORT] po-reconciliation: %d rows as xlsx (by %s)", len(data), c.GetString("username"))
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreditReceipt(t *testing.T) {
+	arrival := func(id uint, poQty int) PoReconciliation {
+		return PoReconciliation{ArrivalID: &id, PoQty: poQty}
+	}
+	line := func(sku string, qty int) receiptSkuRow {
+		return receiptSkuRow{ReceiptKey: "r1", Sku: sku, Qty: qty}
+	}
+
+	tests := []struct {
+		name     string
+		rows     []PoReconciliation
+		lines    []receiptSkuRow
+		received []int
+		skus     [][]ReconSku
+	}{
+		{
+			name:     "single arrival takes everything",
+			rows:     []PoReconciliation{arrival(1, 10)},
+			lines:    []receiptSkuRow{line("A", 8), line("B", 5)},
+			received: []int{13},
+			skus:     [][]ReconSku{{{"A", 8}, {"B", 5}}},
+		},
+		{
+			name:     "split up to the PO qty, oldest first",
+			rows:     []PoReconciliation{arrival(2, 6), arrival(1, 4)},
+			lines:    []receiptSkuRow{line("B", 3), line("A", 7)},
+			received: []int{6, 4},
+			skus:     [][]ReconSku{{{"A", 3}, {"B", 3}}, {{"A", 4}}},
+		},
+		{
+			name:     "over receipt goes to the last arrival",
+			rows:     []PoReconciliation{arrival(1, 4), arrival(2, 4)},
+			lines:    []receiptSkuRow{line("A", 11)},
+			received: []int{4, 7},
+			skus:     [][]ReconSku{{{"A", 4}}, {{"A", 7}}},
+		},
+		{
+			name:     "short receipt leaves the later arrivals empty",
+			rows:     []PoReconciliation{arrival(1, 4), arrival(2, 4)},
+			lines:    []receiptSkuRow{line("A", 3)},
+			received: []int{3, 0},
+			skus:     [][]ReconSku{{{"A", 3}}, nil},
+		},
+		{
+			name:     "zero PO qty is skipped",
+			rows:     []PoReconciliation{arrival(1, 0), arrival(2, 5)},
+			lines:    []receiptSkuRow{line("A", 2)},
+			received: []int{0, 2},
+			skus:     [][]ReconSku{nil, {{"A", 2}}},
+		},
+		{
+			name:     "receipt without an arrival",
+			rows:     []PoReconciliation{{}},
+			lines:    []receiptSkuRow{line("A", 2), line("B", 0)},
+			received: []int{2},
+			skus:     [][]ReconSku{{{"A", 2}, {"B", 0}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := make([]int, len(tt.rows))
+			for i := range idx {
+				idx[i] = i
+			}
+			creditReceipt(tt.rows, idx, tt.lines)
+			for i, r := range tt.rows {
+				if r.ReceivedQty != tt.received[i] {
+					t.Errorf("row %d received %d, want %d", i, r.ReceivedQty, tt.received[i])
+				}
+				if !reflect.DeepEqual(r.Skus, tt.skus[i]) {
+					t.Errorf("row %d skus %v, want %v", i, r.Skus, tt.skus[i])
+				}
+			}
+		})
+	}
+}
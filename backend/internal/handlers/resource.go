@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,16 +12,21 @@ import (
 	"strconv"
 	"strings"
 
-	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 // ResourceHandler provides generic CRUD operations for any GORM model
 type ResourceHandler[T any] struct {
-	Name string
+	Name       string
+	NaturalKey []string                                           // json fields identifying a record, see WithNaturalKey
+	Validators []func(*T) []FieldError                            // cross-field rules, see WithValidator
+	Protected  []string                                           // json fields the generic writes leave alone, see WithProtectedFields
+	OnCreate   []func(tx *gorm.DB, item *T) error                 // run before every insert, see WithCreateHook
+	OnDelete   []func(tx *gorm.DB, items []T, actor string) error // run after Delete and BulkDelete, see WithDeleteHook
 }
 
 // NewResource creates a new ResourceHandler for a given model type
@@ -28,23 +34,65 @@ func NewResource[T any](name string) *ResourceHandler[T] {
 	return &ResourceHandler[T]{Name: name}
 }
 
-// injectUpdatedBy reads the raw request body, injects updated_by from JWT, and binds to item
-func injectUpdatedBy[T any](c *gin.Context, item *T) error {
+// WithCreateHook adds a step that runs inside the transaction just before a
+// new record is inserted, for fields the server fills in such as document
+// numbers. Create, BatchImport, ImportFile and Sync run it on every row they
+// insert; a snapshot restore does not, as it brings back stored rows. An
+// error aborts the whole write.
+func (h *ResourceHandler[T]) WithCreateHook(hook func(tx *gorm.DB, item *T) error) *ResourceHandler[T] {
+	h.OnCreate = append(h.OnCreate, hook)
+	return h
+}
+
+// runCreateHooks runs the create hooks on a record about to be inserted
+func (h *ResourceHandler[T]) runCreateHooks(tx *gorm.DB, item *T) error {
+	for _, hook := range h.OnCreate {
+		if err := hook(tx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithDeleteHook adds a step that runs inside the transaction right after
+// Delete or BulkDelete moved records to the trash, for dependent rows that
+// should go with them. An error aborts the whole delete.
+func (h *ResourceHandler[T]) WithDeleteHook(hook func(tx *gorm.DB, items []T, actor string) error) *ResourceHandler[T] {
+	h.OnDelete = append(h.OnDelete, hook)
+	return h
+}
+
+// readBodyWithUpdatedBy reads the raw JSON object body and injects updated_by
+// from JWT. It returns the enriched JSON and the top-level keys that were sent.
+func readBodyWithUpdatedBy(c *gin.Context) ([]byte, []string, error) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	// Parse into a generic map so we can inject updated_by
 	var raw map[string]interface{}
 	if err := json.Unmarshal(body, &raw); err != nil {
-		return err
+		return nil, nil, err
 	}
 	username := c.GetString("username")
 	if username != "" {
 		raw["updated_by"] = username
 	}
-	// Re-marshal with updated_by injected, then bind to typed struct
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	// Re-marshal with updated_by injected
 	enriched, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enriched, keys, nil
+}
+
+// injectUpdatedBy reads the raw request body, injects updated_by from JWT, and binds to item
+func injectUpdatedBy[T any](c *gin.Context, item *T) error {
+	enriched, _, err := readBodyWithUpdatedBy(c)
 	if err != nil {
 		return err
 	}
@@ -68,35 +116,10 @@ func getSearchColumns(t interface{}) []string {
 	return cols
 }
 
-// List returns all records, with optional server-side pagination
-func (h *ResourceHandler[T]) List(c *gin.Context) {
-	pageStr := c.Query("page")
-	
-	// Legacy mode: if no page parameter, return everything as a flat array
-	if pageStr == "" {
-		var items []T
-		if err := database.DB.Find(&items).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusOK, items)
-		return
-	}
-
-	// Paginated mode
-	page, _ := strconv.Atoi(pageStr)
-	if page < 1 {
-		page = 1
-	}
-	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
-	if pageSize < 1 {
-		pageSize = 50
-	}
-	if pageSize > 100000 {
-		pageSize = 100000
-	}
-
-	query := database.DB.Model(new(T))
+// listQuery builds the base query for List, applying the date range, search
+// and structured filter parameters shared by every listing endpoint
+func (h *ResourceHandler[T]) listQuery(c *gin.Context) (*gorm.DB, error) {
+	query := warehouseDB(c).Model(new(T))
 
 	// Date filtering
 	dateField := c.Query("dateField")
@@ -117,36 +140,177 @@ func (h *ResourceHandler[T]) List(c *gin.Context) {
 			var args []interface{}
 			searchTerm := "%" + search + "%"
 			for _, col := range cols {
-				orConditions = append(orConditions, fmt.Sprintf("%s LIKE ?", col))
+				orConditions = append(orConditions, fmt.Sprintf("%s LIKE ?", query.Statement.Quote(col)))
 				args = append(args, searchTerm)
 			}
 			query = query.Where(strings.Join(orConditions, " OR "), args...)
 		}
 	}
 
+	// Structured filters: filter[field][op]=value
+	conds, err := parseFilters(c.Request.URL.Query(), getModelColumns(new(T)))
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range conds {
+		query = cond.apply(query)
+	}
+
+	return query, nil
+}
+
+// sortKeys parses the sort parameter against the model columns
+func (h *ResourceHandler[T]) sortKeys(c *gin.Context) ([]sortKey, error) {
+	return parseSort(c.Query("sort"), getModelColumns(new(T)))
+}
+
+// respondQueryError writes a 400 for invalid list parameters
+func respondQueryError(c *gin.Context, err error) {
+	var qerr *QueryParamError
+	if errors.As(err, &qerr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             qerr.Error(),
+			"unknown_columns":   qerr.UnknownColumns,
+			"unknown_operators": qerr.UnknownOperators,
+			"invalid_values":    qerr.InvalidValues,
+			"unknown_sort_keys": qerr.UnknownSortKeys,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// pageSizeParam reads pageSize, defaulting to 50 and capped at 100000
+func pageSizeParam(c *gin.Context) int {
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 {
+		pageSize = 50
+	}
+	if pageSize > 100000 {
+		pageSize = 100000
+	}
+	return pageSize
+}
+
+// List returns all records, with optional server-side pagination.
+//   - no page/cursor: legacy mode, a flat array of every matching row
+//   - page=N: offset pagination, {"data", "total"}
+//   - cursor=<token>: keyset pagination, {"data", "next_cursor", "total"};
+//     pass an empty cursor for the first page
+//
+// count=false skips the COUNT query and omits total.
+func (h *ResourceHandler[T]) List(c *gin.Context) {
+	query, err := h.listQuery(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return
+	}
+	keys, err := h.sortKeys(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return
+	}
+	withCount := c.Query("count") != "false"
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		h.listCursor(c, query, keys, cursor, withCount)
+		return
+	}
+
+	pageStr := c.Query("page")
+
+	// Legacy mode: if no page parameter, return everything as a flat array
+	if pageStr == "" {
+		if len(keys) > 0 {
+			query = applySort(query, keys)
+		}
+		var items []T
+		if err := query.Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	// Paginated mode
+	page, _ := strconv.Atoi(pageStr)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := pageSizeParam(c)
+
 	// Count total before limit/offset
 	var total int64
-	query.Count(&total)
+	if withCount {
+		query.Count(&total)
+	}
 
-	// Apply limit, offset, and order
+	// Apply limit, offset, and order (defaults to newest first)
 	offset := (page - 1) * pageSize
 	var items []T
-	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
+	if err := applySort(query, keys).Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":  items,
-		"total": total,
-	})
+	resp := gin.H{"data": items}
+	if withCount {
+		resp["total"] = total
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-// Get returns a single record by ID
+// listCursor serves keyset pagination. It fetches one extra row to know
+// whether another page exists; next_cursor is empty on the last page.
+func (h *ResourceHandler[T]) listCursor(c *gin.Context, query *gorm.DB, keys []sortKey, cursor string, withCount bool) {
+	pageSize := pageSizeParam(c)
+
+	var total int64
+	if withCount {
+		query.Session(&gorm.Session{}).Count(&total)
+	}
+
+	terms := orderTerms(query, keys)
+	if cursor != "" {
+		values, err := decodeCursor(cursor, terms)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+		query = applyCursor(query, terms, values)
+	}
+
+	var items []T
+	if err := applySort(query, keys).Limit(pageSize + 1).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	nextCursor := ""
+	if len(items) > pageSize {
+		items = items[:pageSize]
+		token, err := encodeCursor(&items[pageSize-1], terms)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		nextCursor = token
+	}
+
+	resp := gin.H{"data": items, "next_cursor": nextCursor}
+	if withCount {
+		resp["total"] = total
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get returns a single record by ID. The ETag header can be sent back as
+// If-Match on PUT/PATCH to detect concurrent edits.
 func (h *ResourceHandler[T]) Get(c *gin.Context) {
 	id := c.Param("id")
 	var item T
-	if err := database.DB.First(&item, id).Error; err != nil {
+	if err := warehouseDB(c).First(&item, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 			return
@@ -154,6 +318,7 @@ func (h *ResourceHandler[T]) Get(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", etagOf(&item))
 	c.JSON(http.StatusOK, item)
 }
 
@@ -164,42 +329,72 @@ func (h *ResourceHandler[T]) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := database.DB.Create(&item).Error; err != nil {
+	var zero T
+	h.keepProtected(&item, &zero)
+	if errs := h.validate(&item); len(errs) > 0 {
+		respondValidationError(c, errs)
+		return
+	}
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := h.runCreateHooks(tx, &item); err != nil {
+			return err
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, h.auditRecord(AuditCreate, c.GetString("username"), nil, &item))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", etagOf(&item))
 	c.JSON(http.StatusCreated, item)
 }
 
-// Update updates an existing record by ID
+// Update replaces an existing record by ID with the fields in the body
 func (h *ResourceHandler[T]) Update(c *gin.Context) {
-	id := c.Param("id")
-	var existing T
-	if err := database.DB.First(&existing, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	h.update(c, false)
+}
 
-	if err := injectUpdatedBy(c, &existing); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// Patch updates only the fields sent in the body
+func (h *ResourceHandler[T]) Patch(c *gin.Context) {
+	h.update(c, true)
+}
+
+// deleteIDs soft-deletes the given records, runs the delete hooks and
+// audits each one
+func (h *ResourceHandler[T]) deleteIDs(tx *gorm.DB, ids interface{}, actor string) (int, error) {
+	var items []T
+	if err := tx.Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return 0, err
 	}
-	if err := database.DB.Save(&existing).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if len(items) == 0 {
+		return 0, nil
+	}
+	if err := tx.Delete(&items).Error; err != nil {
+		return 0, err
 	}
-	c.JSON(http.StatusOK, existing)
+	for _, hook := range h.OnDelete {
+		if err := hook(tx, items, actor); err != nil {
+			return 0, err
+		}
+	}
+	entries := make([]models.AuditLog, len(items))
+	for i := range items {
+		entries[i] = h.auditRecord(AuditDelete, actor, &items[i], nil)
+	}
+	return len(items), writeAudit(tx, entries...)
 }
 
 // Delete removes a record by ID
 func (h *ResourceHandler[T]) Delete(c *gin.Context) {
 	id := c.Param("id")
-	var item T
-	if err := database.DB.Delete(&item, id).Error; err != nil {
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		_, err := h.deleteIDs(tx, []string{id}, c.GetString("username"))
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -215,8 +410,11 @@ func (h *ResourceHandler[T]) BulkDelete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	var item T
-	if err := database.DB.Delete(&item, req.IDs).Error; err != nil {
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		_, err := h.deleteIDs(tx, req.IDs, c.GetString("username"))
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -224,21 +422,50 @@ func (h *ResourceHandler[T]) BulkDelete(c *gin.Context) {
 }
 
 // Sync truncates the table and re-inserts all data (for import/full sync)
-// REQUIRES "confirm": true in request body to prevent accidental data loss
+// REQUIRES "confirm": true in request body to prevent accidental data loss.
+// With "dry_run": true (or ?dry_run=true) nothing is written; the response
+// previews inserts, updates and deletes by the resource's natural key.
+// The replaced rows are kept as a snapshot that can be restored later.
 func (h *ResourceHandler[T]) Sync(c *gin.Context) {
 	var req struct {
 		Data    []T  `json:"data"`
 		Confirm bool `json:"confirm"`
+		DryRun  bool `json:"dry_run"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.clearProtected(req.Data)
+	invalid := h.validateAll(req.Data)
+
+	if req.DryRun || c.Query("dry_run") == "true" {
+		var existing []T
+		if err := warehouseDB(c).Find(&existing).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if invalid == nil {
+			invalid = []RowError{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":      true,
+			"diff":         h.diffSync(existing, req.Data),
+			"invalid_rows": invalid,
+		})
+		return
+	}
+
+	if len(invalid) > 0 {
+		respondBatchValidationError(c, invalid)
+		return
+	}
+
 	if !req.Confirm {
 		// Count existing records to warn the user
 		var existingCount int64
-		database.DB.Model(new(T)).Count(&existingCount)
+		warehouseDB(c).Model(new(T)).Count(&existingCount)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":          "Sync requires confirm: true. This will DELETE all existing data and replace with new data.",
 			"existing_count": existingCount,
@@ -249,23 +476,19 @@ func (h *ResourceHandler[T]) Sync(c *gin.Context) {
 
 	// Count existing records for logging
 	var existingCount int64
-	database.DB.Model(new(T)).Count(&existingCount)
+	warehouseDB(c).Model(new(T)).Count(&existingCount)
+	username := c.GetString("username")
 	log.Printf("[SYNC] %s: replacing %d existing records with %d new records (by %s)",
-		h.Name, existingCount, len(req.Data), c.GetString("username"))
+		h.Name, existingCount, len(req.Data), username)
 
-	// Truncate + re-insert in a transaction
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		// Delete all existing records (hard delete)
-		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(new(T)).Error; err != nil {
+	// Snapshot + truncate + re-insert in a transaction
+	var snap *models.SyncSnapshot
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if snap, err = h.replaceAll(tx, req.Data, username, false); err != nil {
 			return err
 		}
-		// Insert all new records
-		if len(req.Data) > 0 {
-			if err := tx.Create(&req.Data).Error; err != nil {
-				return err
-			}
-		}
-		return nil
+		return writeAudit(tx, h.syncAudit(username, existingCount, len(req.Data), snap.ID))
 	})
 
 	if err != nil {
@@ -273,56 +496,49 @@ func (h *ResourceHandler[T]) Sync(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[SYNC] %s: completed successfully (%d records)", h.Name, len(req.Data))
+	log.Printf("[SYNC] %s: completed successfully (%d records, snapshot #%d)", h.Name, len(req.Data), snap.ID)
 	c.JSON(http.StatusOK, gin.H{
-		"synced":   len(req.Data),
-		"total":    len(req.Data),
-		"replaced": existingCount,
+		"synced":      len(req.Data),
+		"total":       len(req.Data),
+		"replaced":    existingCount,
+		"snapshot_id": snap.ID,
 	})
 }
 
-// BatchImport appends data in batches of 500 (does NOT truncate existing data)
+// BatchImport writes data in batches of 500 (does NOT truncate existing data).
+// "import_mode" picks what happens to rows whose natural key already exists:
+// insert-only appends, upsert overwrites and skip-existing leaves them alone.
 func (h *ResourceHandler[T]) BatchImport(c *gin.Context) {
 	var req struct {
-		Data []T `json:"data"`
+		Data       []T    `json:"data"`
+		ImportMode string `json:"import_mode"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	mode, err := h.resolveImportMode(req.ImportMode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.clearProtected(req.Data)
+	if invalid := h.validateAll(req.Data); len(invalid) > 0 {
+		respondBatchValidationError(c, invalid)
+		return
+	}
+
 	if len(req.Data) == 0 {
 		c.JSON(http.StatusOK, gin.H{"imported": 0})
 		return
 	}
 
-	batchSize := 500
-	total := 0
-
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		for i := 0; i < len(req.Data); i += batchSize {
-			end := i + batchSize
-			if end > len(req.Data) {
-				end = len(req.Data)
-			}
-			batch := req.Data[i:end]
-			
-			if h.Name == "locations" {
-				if err := tx.Clauses(clause.OnConflict{
-					Columns:   []clause.Column{{Name: "location"}},
-					UpdateAll: true,
-				}).Create(&batch).Error; err != nil {
-					return err
-				}
-			} else {
-				if err := tx.Create(&batch).Error; err != nil {
-					return err
-				}
-			}
-			
-			total += len(batch)
-		}
-		return nil
+	var res ImportResult
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		res, err = h.importBatches(tx, req.Data, mode, c.GetString("username"))
+		return err
 	})
 
 	if err != nil {
@@ -330,17 +546,39 @@ func (h *ResourceHandler[T]) BatchImport(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"imported": total})
+	c.JSON(http.StatusOK, gin.H{
+		"imported":    res.Inserted + res.Updated,
+		"inserted":    res.Inserted,
+		"updated":     res.Updated,
+		"skipped":     res.Skipped,
+		"import_mode": mode,
+	})
 }
 
-// RegisterRoutes registers all CRUD routes for this resource
+// RegisterRoutes registers all CRUD routes for this resource, each guarded by
+// the matching permission of the caller's role, and enrolls the resource in
+// the trash retention job
 func (h *ResourceHandler[T]) RegisterRoutes(rg *gin.RouterGroup) {
-	rg.GET("", h.List)
-	rg.GET("/:id", h.Get)
-	rg.POST("", h.Create)
-	rg.PUT("/:id", h.Update)
-	rg.DELETE("/:id", h.Delete)
-	rg.POST("/bulk-delete", h.BulkDelete)
-	rg.POST("/sync", h.Sync)
-	rg.POST("/import", h.BatchImport)
+	registerTrash(h)
+	can := func(action string) gin.HandlerFunc {
+		return middleware.RequirePermission(h.Name, action)
+	}
+	rg.GET("", can(middleware.ActionList), h.List)
+	rg.GET("/export", can(middleware.ActionList), h.Export)
+	rg.GET("/:id", can(middleware.ActionGet), h.Get)
+	rg.GET("/:id/history", can(middleware.ActionGet), h.History)
+	rg.POST("", can(middleware.ActionCreate), h.Create)
+	rg.PUT("/:id", can(middleware.ActionUpdate), h.Update)
+	rg.PATCH("/:id", can(middleware.ActionUpdate), h.Patch)
+	rg.DELETE("/:id", can(middleware.ActionDelete), h.Delete)
+	rg.POST("/bulk-delete", can(middleware.ActionDelete), h.BulkDelete)
+	rg.GET("/trash", can(middleware.ActionDelete), h.ListTrash)
+	rg.POST("/:id/restore", can(middleware.ActionDelete), h.Restore)
+	rg.POST("/trash/restore", can(middleware.ActionDelete), h.BulkRestore)
+	rg.POST("/trash/purge", can(middleware.ActionDelete), h.PurgeTrash)
+	rg.POST("/sync", can(middleware.ActionSync), h.Sync)
+	rg.GET("/sync/snapshots", can(middleware.ActionSync), h.ListSyncSnapshots)
+	rg.POST("/sync/snapshots/:snapshotId/restore", can(middleware.ActionSync), h.RestoreSyncSnapshot)
+	rg.POST("/import", can(middleware.ActionImport), h.BatchImport)
+	rg.POST("/import/file", can(middleware.ActionImport), h.ImportFile)
 }
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RoleRequest is the body of CreateRole and UpdateRole
+type RoleRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description"`
+	IsSuper     bool                `json:"is_super"`
+	Require2FA  bool                `json:"require_2fa"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// RoleResponse is a role with the number of users assigned to it
+type RoleResponse struct {
+	models.Role
+	UserCount int64 `json:"user_count"`
+}
+
+// validatePermissions checks resources and actions and drops duplicates
+func validatePermissions(perms []models.Permission) ([]models.Permission, error) {
+	validAction := map[string]bool{middleware.Wildcard: true}
+	for _, a := range middleware.Actions {
+		validAction[a] = true
+	}
+	seen := map[string]bool{}
+	out := make([]models.Permission, 0, len(perms))
+	for _, p := range perms {
+		p.Resource = strings.TrimSpace(p.Resource)
+		p.Action = strings.TrimSpace(p.Action)
+		if p.Resource == "" {
+			return nil, fmt.Errorf("permission resource is required")
+		}
+		if !validAction[p.Action] {
+			return nil, fmt.Errorf("unknown action %q, expected one of %s or *", p.Action, strings.Join(middleware.Actions, ", "))
+		}
+		key := p.Resource + ":" + p.Action
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, models.Permission{Resource: p.Resource, Action: p.Action})
+	}
+	return out, nil
+}
+
+// ListRoles returns all roles with their permissions (supervisor/leader only)
+func ListRoles(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]RoleResponse, len(roles))
+	for i, r := range roles {
+		result[i] = RoleResponse{Role: r}
+		database.DB.Model(&models.User{}).Where("role = ?", r.Name).Count(&result[i].UserCount)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RoleOptions lists the actions and resources a permission can refer to
+func RoleOptions(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	trashMu.Lock()
+	resources := make([]string, 0, len(trashResources))
+	for name := range trashResources {
+		resources = append(resources, name)
+	}
+	trashMu.Unlock()
+	sort.Strings(resources)
+	c.JSON(http.StatusOK, gin.H{"actions": middleware.Actions, "resources": resources})
+}
+
+// CreateRole defines a new role (supervisor/leader only)
+func CreateRole(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama role wajib diisi"})
+		return
+	}
+	perms, err := validatePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if roleExists(req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role sudah ada"})
+		return
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description, IsSuper: req.IsSuper, Require2FA: req.Require2FA, Permissions: perms}
+	if err := database.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.InvalidatePermissions()
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes a role's name, description, super and 2FA flags and permissions
+// (supervisor/leader only). Permissions are replaced by the list sent.
+// Renaming a role moves its users along. A super user cannot take super
+// access away from their own role.
+func UpdateRole(c *gin.Context) {
+	currentRole := c.GetString("role")
+	if !isSuperRole(currentRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama role wajib diisi"})
+		return
+	}
+	perms, err := validatePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+
+	var role models.Role
+	if err := database.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role tidak ditemukan"})
+		return
+	}
+	if role.Name == currentRole && (!req.IsSuper || req.Name != role.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak bisa mengubah nama atau akses super role sendiri"})
+		return
+	}
+	if req.Name != role.Name && roleExists(req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role sudah ada"})
+		return
+	}
+
+	oldName := role.Name
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Updates(map[string]interface{}{
+			"name":        req.Name,
+			"description": req.Description,
+			"is_super":    req.IsSuper,
+			"require_2fa": req.Require2FA,
+		}).Error; err != nil {
+			return err
+		}
+		if oldName != req.Name {
+			if err := tx.Model(&models.User{}).Where("role = ?", oldName).Update("role", req.Name).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.Permission{}).Error; err != nil {
+			return err
+		}
+		for i := range perms {
+			perms[i].RoleID = role.ID
+		}
+		if len(perms) > 0 {
+			return tx.Create(&perms).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.InvalidatePermissions()
+
+	database.DB.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a role that no user is assigned to (supervisor/leader only)
+func DeleteRole(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var role models.Role
+	if err := database.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role tidak ditemukan"})
+		return
+	}
+	var users int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role masih dipakai oleh user", "user_count": users})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.Permission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.InvalidatePermissions()
+	c.JSON(http.StatusOK, gin.H{"message": "Role berhasil dihapus"})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateRoleInvalidatesPermissions(t *testing.T) {
+	db := openTestDB(t, &models.Role{}, &models.Permission{}, &models.User{})
+	middleware.InvalidatePermissions()
+	roles := []models.Role{
+		{Name: "supervisor", IsSuper: true},
+		{Name: "staff", Permissions: []models.Permission{{Resource: "arrivals", Action: middleware.ActionList}}},
+	}
+	if err := db.Create(&roles).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !middleware.HasPermission("staff", "arrivals", middleware.ActionList) || middleware.HasPermission("staff", "soh", middleware.ActionList) {
+		t.Fatal("unexpected staff permissions before the update")
+	}
+
+	body := gin.H{"name": "staff", "permissions": []gin.H{{"resource": "soh", "action": "list"}}}
+	w := callHandler(t, UpdateRole, gin.Params{{Key: "id", Value: fmt.Sprint(roles[1].ID)}}, body, gin.H{"role": "supervisor"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update role: %d %s", w.Code, w.Body)
+	}
+	if middleware.HasPermission("staff", "arrivals", middleware.ActionList) {
+		t.Error("the removed permission still applies")
+	}
+	if !middleware.HasPermission("staff", "soh", middleware.ActionList) {
+		t.Error("the added permission does not apply")
+	}
+
+	w = callHandler(t, UpdateRole, gin.Params{{Key: "id", Value: fmt.Sprint(roles[1].ID)}}, body, gin.H{"role": "staff"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("update by a non-super role: %d, want 403", w.Code)
+	}
+}
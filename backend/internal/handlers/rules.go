@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+)
+
+// varianceRule checks variance == phy_qty - sys_qty
+func varianceRule(sysField, phyField, varianceField string, sys, phy, variance int) []FieldError {
+	if variance == phy-sys {
+		return nil
+	}
+	return []FieldError{{
+		Field:   varianceField,
+		Rule:    "variance",
+		Message: fmt.Sprintf("%s must equal %s - %s (%d), got %d", varianceField, phyField, sysField, phy-sys, variance),
+	}}
+}
+
+// DccVarianceRule checks that a DCC line's variance matches its quantities,
+// including the reconcile columns when all three are filled in
+func DccVarianceRule(d *models.Dcc) []FieldError {
+	errs := varianceRule("sys_qty", "phy_qty", "variance", d.SysQty, d.PhyQty, d.Variance)
+	if d.ReconcileSysQty != nil && d.ReconcilePhyQty != nil && d.ReconcileVariance != nil {
+		errs = append(errs, varianceRule("reconcile_sys_qty", "reconcile_phy_qty", "reconcile_variance",
+			*d.ReconcileSysQty, *d.ReconcilePhyQty, *d.ReconcileVariance)...)
+	}
+	return errs
+}
+
+// StockOpnameVarianceRule checks that a stock opname line's variance matches its quantities
+func StockOpnameVarianceRule(s *models.StockOpname) []FieldError {
+	return varianceRule("sys_qty", "phy_qty", "variance", s.SysQty, s.PhyQty, s.Variance)
+}
+
+// parseClock parses an HH:MM time of day into minutes after midnight
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// DockSlotTimeRule checks that a dock slot has HH:MM start and end times and
+// ends after it starts
+func DockSlotTimeRule(s *models.DockSlot) []FieldError {
+	var errs []FieldError
+	start, okStart := parseClock(s.StartTime)
+	if !okStart {
+		errs = append(errs, FieldError{Field: "start_time", Rule: "clock", Message: "start_time must be HH:MM"})
+	}
+	end, okEnd := parseClock(s.EndTime)
+	if !okEnd {
+		errs = append(errs, FieldError{Field: "end_time", Rule: "clock", Message: "end_time must be HH:MM"})
+	}
+	if okStart && okEnd && end <= start {
+		errs = append(errs, FieldError{Field: "end_time", Rule: "after", Message: "end_time must be after start_time"})
+	}
+	return errs
+}
+
+// UnloadingEventTimeRule checks that an unloading event finishes after it
+// starts and is not finished without having started
+func UnloadingEventTimeRule(e *models.UnloadingEvent) []FieldError {
+	switch {
+	case e.FinishTime.Valid && !e.StartTime.Valid:
+		return []FieldError{{Field: "start_time", Rule: "required_with", Message: "start_time is required when finish_time is set"}}
+	case e.FinishTime.Valid && e.FinishTime.Time.Before(e.StartTime.Time):
+		return []FieldError{{Field: "finish_time", Rule: "after", Message: "finish_time must not be before start_time"}}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// refreshTokenTTL is how long a refresh token stays valid without use
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// newOpaqueToken returns a random URL-safe token and its storage hash
+func newOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the SHA-256 hex digest stored for an opaque token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueSession creates an access token and a refresh token for user.
+// family is empty for a fresh login and carried over on rotation.
+func issueSession(c *gin.Context, tx *gorm.DB, user models.User, family string) (LoginResponse, *models.RefreshToken, error) {
+	access, err := middleware.GenerateToken(user.ID, user.Username, user.Role, user.TokenVersion)
+	if err != nil {
+		return LoginResponse{}, nil, err
+	}
+	refresh, hash, err := newOpaqueToken()
+	if err != nil {
+		return LoginResponse{}, nil, err
+	}
+	if family == "" {
+		if _, family, err = newOpaqueToken(); err != nil {
+			return LoginResponse{}, nil, err
+		}
+	}
+	rt := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		Family:    family,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := tx.Create(&rt).Error; err != nil {
+		return LoginResponse{}, nil, err
+	}
+	return LoginResponse{
+		Token:              access,
+		RefreshToken:       refresh,
+		ExpiresIn:          int(middleware.AccessTokenTTL.Seconds()),
+		UserID:             user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		MFASetupRequired:   !user.TOTPEnabled && middleware.RoleRequires2FA(user.Role),
+	}, &rt, nil
+}
+
+// bumpTokenVersion invalidates every access token of a user. Refresh
+// tokens keep working, so clients pick up the change on their next refresh.
+func bumpTokenVersion(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// revokeUserSessions invalidates every access and refresh token of a user
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	if err := bumpTokenVersion(tx, userID); err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeFamily revokes every live refresh token descending from one login
+func revokeFamily(tx *gorm.DB, family string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new
+// refresh token. The old refresh token is revoked; presenting it again is
+// treated as theft and revokes every token of that login.
+func RefreshSession(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token wajib diisi"})
+		return
+	}
+
+	var rt models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&rt).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if rt.RevokedAt != nil {
+		if rt.ReplacedBy != 0 {
+			log.Printf("[AUTH] Refresh token reuse for user #%d, revoking session family", rt.UserID)
+			revokeFamily(database.DB, rt.Family)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		return
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	var resp LoginResponse
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, rt.UserID).Error; err != nil {
+			return err
+		}
+		// Revoke only if still live, so two concurrent refreshes cannot both win
+		res := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", rt.ID).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		var next *models.RefreshToken
+		var err error
+		if resp, next, err = issueSession(c, tx, user, rt.Family); err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).Where("id = ?", rt.ID).Update("replaced_by", next.ID).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the refresh token's login. With "all": true every session
+// of the user is ended, including access tokens already issued.
+func Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+		All          bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token wajib diisi"})
+		return
+	}
+
+	var rt models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&rt).Error; err != nil {
+		// Unknown token: nothing to revoke, the client is logged out anyway
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if req.All {
+			return revokeUserSessions(tx, rt.UserID)
+		}
+		return revokeFamily(tx, rt.Family)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// openSessionDB sets up the tables behind logins with one user, "alice"
+func openSessionDB(t *testing.T) (*gorm.DB, models.User) {
+	t.Helper()
+	db := openTestDB(t, &models.User{}, &models.RefreshToken{}, &models.Role{}, &models.Permission{})
+	middleware.InvalidatePermissions()
+	user := models.User{Username: "alice", Password: "x", Role: "staff"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db, user
+}
+
+// testLogin starts a session for user the way a successful login does
+func testLogin(t *testing.T, db *gorm.DB, user models.User) LoginResponse {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	if err := db.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := issueSession(c, db, user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// refresh calls RefreshSession and returns the status and the new session
+func refresh(t *testing.T, token string) (int, LoginResponse) {
+	t.Helper()
+	w := callHandler(t, RefreshSession, nil, gin.H{"refresh_token": token})
+	var resp LoginResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestRefreshRotation(t *testing.T) {
+	db, user := openSessionDB(t)
+	first := testLogin(t, db, user)
+	other := testLogin(t, db, user)
+
+	code, second := refresh(t, first.RefreshToken)
+	if code != http.StatusOK || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken || second.Token == "" {
+		t.Fatalf("refresh: %d %+v", code, second)
+	}
+	var old models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(first.RefreshToken)).First(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.RevokedAt == nil || old.ReplacedBy == 0 {
+		t.Errorf("rotated token: revoked %v replaced by %d, want revoked and replaced", old.RevokedAt, old.ReplacedBy)
+	}
+	code, third := refresh(t, second.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("second refresh: %d", code)
+	}
+
+	// Replaying a rotated token revokes the whole login, the newest token too
+	if code, _ := refresh(t, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("reused token: %d, want 401", code)
+	}
+	if code, _ := refresh(t, third.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("newest token of a reused family: %d, want 401", code)
+	}
+	var live int64
+	db.Model(&models.RefreshToken{}).Where("revoked_at IS NULL").Count(&live)
+	if live != 1 {
+		t.Errorf("%d live refresh tokens, want only the other login's", live)
+	}
+	if code, _ := refresh(t, other.RefreshToken); code != http.StatusOK {
+		t.Errorf("other login after reuse: %d, want 200", code)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	db, user := openSessionDB(t)
+	expired := testLogin(t, db, user)
+	if err := db.Model(&models.RefreshToken{}).Where("token_hash = ?", hashToken(expired.RefreshToken)).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	loggedOut := testLogin(t, db, user)
+	if w := callHandler(t, Logout, nil, gin.H{"refresh_token": loggedOut.RefreshToken}); w.Code != http.StatusOK {
+		t.Fatalf("logout: %d", w.Code)
+	}
+
+	for name, token := range map[string]string{"unknown": "not-a-token", "expired": expired.RefreshToken, "logged out": loggedOut.RefreshToken} {
+		if code, _ := refresh(t, token); code != http.StatusUnauthorized {
+			t.Errorf("%s token: %d, want 401", name, code)
+		}
+	}
+	if code, _ := refresh(t, ""); code != http.StatusBadRequest {
+		t.Errorf("missing token: %d, want 400", code)
+	}
+}
+
+func TestTokenVersion(t *testing.T) {
+	db, user := openSessionDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", middleware.AuthRequired(), func(c *gin.Context) { c.String(http.StatusOK, c.GetString("username")) })
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	session := testLogin(t, db, user)
+	if code := get(session.Token); code != http.StatusOK {
+		t.Fatalf("fresh access token: %d", code)
+	}
+	mfa, err := middleware.GenerateMFAToken(user.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := get(mfa); code != http.StatusUnauthorized {
+		t.Errorf("MFA token as access token: %d, want 401", code)
+	}
+
+	if err := bumpTokenVersion(db, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(session.Token); code != http.StatusUnauthorized {
+		t.Errorf("access token after a version bump: %d, want 401", code)
+	}
+	// The refresh token still works and picks up the new version
+	code, renewed := refresh(t, session.RefreshToken)
+	if code != http.StatusOK || get(renewed.Token) != http.StatusOK {
+		t.Errorf("renewed session: refresh %d", code)
+	}
+
+	// Logging out everywhere ends access and refresh tokens alike
+	if w := callHandler(t, Logout, nil, gin.H{"refresh_token": renewed.RefreshToken, "all": true}); w.Code != http.StatusOK {
+		t.Fatalf("logout all: %d", w.Code)
+	}
+	if code := get(renewed.Token); code != http.StatusUnauthorized {
+		t.Errorf("access token after logout all: %d, want 401", code)
+	}
+	if code, _ := refresh(t, renewed.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout all: %d, want 401", code)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sortKey is a single parsed entry of the sort parameter
+type sortKey struct {
+	Col  columnInfo
+	Desc bool
+}
+
+// orderTerm is one expression of the final ORDER BY clause. A FlexDate or
+// nullable sort key expands into two terms: an "is empty" flag followed by
+// the column. FlexDate columns read NULL as an empty string, so the NULLs of
+// columns added by a migration page like the empty dates they stand for.
+type orderTerm struct {
+	Col       columnInfo
+	Expr      string
+	Desc      bool
+	EmptyFlag bool
+}
+
+// idColumn is the tiebreaker appended to every sort
+var idColumn = columnInfo{JSON: "id", Column: "id", Kind: kindInt}
+
+// parseSort parses a sort parameter such as "-date,brand" into sort keys.
+// A leading "-" sorts descending. Every key must be a known model column.
+func parseSort(raw string, cols map[string]columnInfo) ([]sortKey, error) {
+	var keys []sortKey
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+		col, ok := cols[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		keys = append(keys, sortKey{Col: col, Desc: desc})
+	}
+	if len(unknown) > 0 {
+		return nil, &QueryParamError{UnknownSortKeys: unknown}
+	}
+	return keys, nil
+}
+
+// orderTerms expands sort keys into ORDER BY expressions, followed by id as
+// a tiebreaker so paging is stable. FlexDate columns hold normalized
+// "YYYY-MM-DD[ HH:MM:SS]" text, which sorts chronologically; empty dates,
+// like NULLs of nullable columns, are pushed to the end regardless of
+// direction, which also keeps the order the same on every database.
+func orderTerms(query *gorm.DB, keys []sortKey) []orderTerm {
+	var terms []orderTerm
+	hasID := false
+	for _, k := range keys {
+		col := query.Statement.Quote(k.Col.Column)
+		if k.Col.Kind == kindFlexDate {
+			col = fmt.Sprintf("COALESCE(%s, '')", col)
+			terms = append(terms, orderTerm{
+				Col:       k.Col,
+				Expr:      fmt.Sprintf("CASE WHEN %s = '' THEN 1 ELSE 0 END", col),
+				EmptyFlag: true,
+			})
+		} else if k.Col.Nullable {
+			terms = append(terms, orderTerm{
+				Col:       k.Col,
+				Expr:      fmt.Sprintf("CASE WHEN %s IS NULL THEN 1 ELSE 0 END", col),
+				EmptyFlag: true,
+			})
+		}
+		terms = append(terms, orderTerm{Col: k.Col, Expr: col, Desc: k.Desc})
+		if k.Col.Column == idColumn.Column {
+			hasID = true
+		}
+	}
+	if !hasID {
+		terms = append(terms, orderTerm{Col: idColumn, Expr: query.Statement.Quote(idColumn.Column), Desc: true})
+	}
+	return terms
+}
+
+// applySort adds the ORDER BY clauses for the given keys
+func applySort(query *gorm.DB, keys []sortKey) *gorm.DB {
+	for _, t := range orderTerms(query, keys) {
+		dir := "ASC"
+		if t.Desc {
+			dir = "DESC"
+		}
+		query = query.Order(fmt.Sprintf("%s %s", t.Expr, dir))
+	}
+	return query
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestParseSort(t *testing.T) {
+	cols := getModelColumns(new(cursorRow))
+	tests := []struct {
+		raw     string
+		keys    []string // column, with a leading "-" when descending
+		unknown []string
+	}{
+		{raw: "", keys: nil},
+		{raw: "name", keys: []string{"name"}},
+		{raw: "-date,name", keys: []string{"-date", "name"}},
+		{raw: " +qty , -rate ", keys: []string{"qty", "-rate"}},
+		{raw: "name,-name", keys: []string{"name"}},
+		{raw: "seen_at,,id", keys: []string{"seen_at", "id"}},
+		{raw: "colour", unknown: []string{"colour"}},
+		{raw: "-colour,name,Size", unknown: []string{"colour", "Size"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			keys, err := parseSort(tt.raw, cols)
+			if tt.unknown != nil {
+				var qerr *QueryParamError
+				if !errors.As(err, &qerr) || !reflect.DeepEqual(qerr.UnknownSortKeys, tt.unknown) {
+					t.Fatalf("error %v, want unknown sort keys %q", err, tt.unknown)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, k := range keys {
+				name := k.Col.Column
+				if k.Desc {
+					name = "-" + name
+				}
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("keys %q, want %q", got, tt.keys)
+			}
+		})
+	}
+}
+
+func TestApplySort(t *testing.T) {
+	db := openTestDB(t)
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"", "`id` DESC"},
+		{"name", "`name` ASC,`id` DESC"},
+		{"-qty", "CASE WHEN `qty` IS NULL THEN 1 ELSE 0 END ASC,`qty` DESC,`id` DESC"},
+		{"date,-id", "CASE WHEN COALESCE(`date`, '') = '' THEN 1 ELSE 0 END ASC,COALESCE(`date`, '') ASC,`id` DESC"},
+		{"id", "`id` ASC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return applySort(tx.Model(&cursorRow{}), cursorSort(t, tt.sort)).Find(&[]cursorRow{})
+			})
+			_, order, _ := strings.Cut(sql, "ORDER BY ")
+			if order != tt.want {
+				t.Errorf("ORDER BY %s, want %s", order, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// syncSnapshotKeep is how many snapshots are kept per resource
+const syncSnapshotKeep = 5
+
+// syncPreviewLimit caps the rows listed per section of a dry-run diff
+const syncPreviewLimit = 500
+
+// diffIgnoredFields are server-managed and never count as a change
+var diffIgnoredFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"updated_by": true,
+}
+
+// WithNaturalKey sets the json fields that identify a record independently of
+// its ID (e.g. "location" for Location). Sync dry-runs diff on this key and
+// BatchImport/ImportFile use it as the conflict key for upserts.
+func (h *ResourceHandler[T]) WithNaturalKey(fields ...string) *ResourceHandler[T] {
+	h.NaturalKey = fields
+	return h
+}
+
+// naturalKeyOf returns the natural key of a record as a single string
+func (h *ResourceHandler[T]) naturalKeyOf(item *T) string {
+	v := reflect.ValueOf(item).Elem()
+	parts := make([]string, len(h.NaturalKey))
+	for i, name := range h.NaturalKey {
+		f, _ := fieldByJSON(v, name)
+		parts[i] = exportValue(f)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// FieldChange is the old and new value of one field in a sync diff
+type FieldChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// SyncUpdate is one record that a sync would change
+type SyncUpdate struct {
+	Key     string                 `json:"key"`
+	ID      interface{}            `json:"id"`
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// SyncDiff is the dry-run preview of a sync
+type SyncDiff struct {
+	NaturalKey    []string      `json:"natural_key"`
+	ExistingCount int           `json:"existing_count"`
+	NewCount      int           `json:"new_count"`
+	InsertCount   int           `json:"insert_count"`
+	UpdateCount   int           `json:"update_count"`
+	DeleteCount   int           `json:"delete_count"`
+	Unchanged     int           `json:"unchanged"`
+	DuplicateKeys []string      `json:"duplicate_keys"`
+	Inserts       []interface{} `json:"inserts"`
+	Updates       []SyncUpdate  `json:"updates"`
+	Deletes       []interface{} `json:"deletes"`
+	Truncated     bool          `json:"truncated"`
+}
+
+// diffSync compares incoming data against the current rows by natural key.
+// Without a natural key every existing row counts as deleted and every
+// incoming row as inserted, which is what Sync actually does.
+func (h *ResourceHandler[T]) diffSync(existing, incoming []T) SyncDiff {
+	diff := SyncDiff{
+		NaturalKey:    h.NaturalKey,
+		ExistingCount: len(existing),
+		NewCount:      len(incoming),
+		DuplicateKeys: []string{},
+		Inserts:       []interface{}{},
+		Updates:       []SyncUpdate{},
+		Deletes:       []interface{}{},
+	}
+	if len(h.NaturalKey) == 0 {
+		diff.InsertCount = len(incoming)
+		diff.DeleteCount = len(existing)
+		return diff
+	}
+
+	cols := getExportColumns(reflect.TypeOf(new(T)).Elem())
+	current := make(map[string]*T, len(existing))
+	for i := range existing {
+		current[h.naturalKeyOf(&existing[i])] = &existing[i]
+	}
+
+	seen := make(map[string]bool, len(incoming))
+	for i := range incoming {
+		item := &incoming[i]
+		key := h.naturalKeyOf(item)
+		if seen[key] {
+			diff.DuplicateKeys = append(diff.DuplicateKeys, key)
+			continue
+		}
+		seen[key] = true
+
+		old, ok := current[key]
+		if !ok {
+			diff.InsertCount++
+			if len(diff.Inserts) < syncPreviewLimit {
+				diff.Inserts = append(diff.Inserts, item)
+			}
+			continue
+		}
+
+		oldRow := exportRow(old, cols)
+		newRow := exportRow(item, cols)
+		changes := make(map[string]FieldChange)
+		for j, col := range cols {
+			if diffIgnoredFields[col.Header] || h.isProtected(col.Header) || oldRow[j] == newRow[j] {
+				continue
+			}
+			changes[col.Header] = FieldChange{Old: oldRow[j], New: newRow[j]}
+		}
+		if len(changes) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.UpdateCount++
+		if len(diff.Updates) < syncPreviewLimit {
+			id, _ := fieldByJSON(reflect.ValueOf(old).Elem(), "id")
+			diff.Updates = append(diff.Updates, SyncUpdate{Key: key, ID: id.Interface(), Changes: changes})
+		}
+	}
+
+	for i := range existing {
+		if seen[h.naturalKeyOf(&existing[i])] {
+			continue
+		}
+		diff.DeleteCount++
+		if len(diff.Deletes) < syncPreviewLimit {
+			diff.Deletes = append(diff.Deletes, &existing[i])
+		}
+	}
+
+	diff.Truncated = diff.InsertCount > len(diff.Inserts) ||
+		diff.UpdateCount > len(diff.Updates) ||
+		diff.DeleteCount > len(diff.Deletes)
+	return diff
+}
+
+// encodeSnapshotRows marshals rows for a snapshot. deleted_at is not part of
+// the JSON of a record, so it is added to the rows that are in the trash.
+func encodeSnapshotRows[T any](rows []T) ([]byte, error) {
+	out := make([]json.RawMessage, len(rows))
+	for i := range rows {
+		raw, err := json.Marshal(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		deleted, ok := reflect.ValueOf(&rows[i]).Elem().FieldByName("DeletedAt").Interface().(gorm.DeletedAt)
+		if ok && deleted.Valid {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return nil, err
+			}
+			if fields["deleted_at"], err = json.Marshal(deleted.Time); err != nil {
+				return nil, err
+			}
+			if raw, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+		out[i] = raw
+	}
+	return json.Marshal(out)
+}
+
+// decodeSnapshotRows reads the rows of a snapshot back, trashed ones with
+// their deleted_at
+func decodeSnapshotRows[T any](data string) ([]T, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &raws); err != nil {
+		return nil, err
+	}
+	rows := make([]T, len(raws))
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &rows[i]); err != nil {
+			return nil, err
+		}
+		var trashed struct {
+			DeletedAt *time.Time `json:"deleted_at"`
+		}
+		if err := json.Unmarshal(raw, &trashed); err != nil {
+			return nil, err
+		}
+		if trashed.DeletedAt != nil {
+			if f := reflect.ValueOf(&rows[i]).Elem().FieldByName("DeletedAt"); f.IsValid() {
+				f.Set(reflect.ValueOf(gorm.DeletedAt{Time: *trashed.DeletedAt, Valid: true}))
+			}
+		}
+	}
+	return rows, nil
+}
+
+// saveSyncSnapshot stores the rows about to be replaced, trash included, and
+// prunes old snapshots
+func (h *ResourceHandler[T]) saveSyncSnapshot(tx *gorm.DB, rows []T, username string) (*models.SyncSnapshot, error) {
+	data, err := encodeSnapshotRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	snap := models.SyncSnapshot{
+		Resource:  h.Name,
+		RowCount:  len(rows),
+		Data:      string(data),
+		CreatedBy: username,
+	}
+	if err := tx.Create(&snap).Error; err != nil {
+		return nil, err
+	}
+
+	// Keep only the newest snapshots for this resource
+	var ids []uint
+	if err := tx.Model(&models.SyncSnapshot{}).
+		Where("resource = ?", h.Name).
+		Order("id DESC").
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) > syncSnapshotKeep {
+		if err := tx.Delete(&models.SyncSnapshot{}, ids[syncSnapshotKeep:]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &snap, nil
+}
+
+// replaceAll snapshots the current rows, including those in the trash, then
+// hard-deletes the table and inserts data in its place. Rows of data that
+// replace a current row keep its protected fields; the others start them at
+// zero and go through the create hooks, unless data is a snapshot of stored
+// rows (stored), which is written back as it is.
+func (h *ResourceHandler[T]) replaceAll(tx *gorm.DB, data []T, username string, stored bool) (*models.SyncSnapshot, error) {
+	var current []T
+	if err := tx.Unscoped().Find(&current).Error; err != nil {
+		return nil, err
+	}
+	if !stored {
+		h.clearProtected(data)
+	}
+	added := h.keepCurrentProtected(current, data)
+	if !stored {
+		// Before the delete, so the hooks still see the numbers in use
+		for _, i := range added {
+			if err := h.runCreateHooks(tx, &data[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	snap, err := h.saveSyncSnapshot(tx, current, username)
+	if err != nil {
+		return nil, err
+	}
+	// Delete all existing records (hard delete)
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(new(T)).Error; err != nil {
+		return nil, err
+	}
+	// Insert all new records
+	if len(data) > 0 {
+		if err := tx.CreateInBatches(&data, 500).Error; err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// keepCurrentProtected copies the protected fields of the current rows onto
+// the rows of data that replace them, matched by natural key, or by ID when
+// the resource has none. It returns the indexes of the rows of data that
+// replace none.
+func (h *ResourceHandler[T]) keepCurrentProtected(current, data []T) []int {
+	keyOf := h.naturalKeyOf
+	if len(h.NaturalKey) == 0 {
+		keyOf = func(item *T) string {
+			id := reflect.ValueOf(item).Elem().FieldByName("ID")
+			if id.IsZero() {
+				return ""
+			}
+			return fmt.Sprint(id.Interface())
+		}
+	}
+	byKey := make(map[string]*T, len(current))
+	for i := range current {
+		byKey[keyOf(&current[i])] = &current[i]
+	}
+	var added []int
+	for i := range data {
+		match, ok := byKey[keyOf(&data[i])]
+		if !ok {
+			added = append(added, i)
+			continue
+		}
+		h.keepProtected(&data[i], match)
+	}
+	return added
+}
+
+// syncAudit builds the audit row for a Sync or snapshot restore. It is kept
+// per resource (record_id 0) rather than per record; the replaced rows
+// themselves are in the snapshot.
+func (h *ResourceHandler[T]) syncAudit(actor string, replaced int64, inserted int, snapshotID uint) models.AuditLog {
+	return h.newAuditLog(AuditSync, actor, 0, map[string]FieldChange{
+		"row_count":   {Old: fmt.Sprint(replaced), New: fmt.Sprint(inserted)},
+		"snapshot_id": {New: fmt.Sprint(snapshotID)},
+	})
+}
+
+// ListSyncSnapshots lists the restorable snapshots for this resource
+func (h *ResourceHandler[T]) ListSyncSnapshots(c *gin.Context) {
+	var snaps []models.SyncSnapshot
+	if err := warehouseDB(c).Omit("data").Where("resource = ?", h.Name).Order("id DESC").Find(&snaps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snaps)
+}
+
+// RestoreSyncSnapshot replaces the table with the rows of a snapshot, putting
+// the rows that were in the trash back in the trash. The current rows are
+// snapshotted first, so a restore can itself be undone.
+// REQUIRES "confirm": true in request body.
+func (h *ResourceHandler[T]) RestoreSyncSnapshot(c *gin.Context) {
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	var snap models.SyncSnapshot
+	if err := warehouseDB(c).Where("resource = ?", h.Name).First(&snap, c.Param("snapshotId")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.Confirm {
+		var existingCount int64
+		warehouseDB(c).Model(new(T)).Count(&existingCount)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":          "Restore requires confirm: true. This will DELETE all existing data and replace it with the snapshot.",
+			"existing_count": existingCount,
+			"new_count":      snap.RowCount,
+		})
+		return
+	}
+
+	rows, err := decodeSnapshotRows[T](snap.Data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("snapshot is unreadable: %v", err)})
+		return
+	}
+
+	username := c.GetString("username")
+	var backup *models.SyncSnapshot
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if backup, err = h.replaceAll(tx, rows, username, true); err != nil {
+			return err
+		}
+		return writeAudit(tx, h.syncAudit(username, int64(backup.RowCount), len(rows), backup.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[SYNC] %s: restored snapshot #%d (%d records) by %s", h.Name, snap.ID, len(rows), username)
+	c.JSON(http.StatusOK, gin.H{
+		"restored":    len(rows),
+		"snapshot_id": backup.ID,
+	})
+}
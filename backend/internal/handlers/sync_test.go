@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callHandler runs a handler on a JSON request as user "tester", with any
+// further context keys set, and returns the response
+func callHandler(t *testing.T, handler gin.HandlerFunc, params gin.Params, body interface{}, keys ...gin.H) *httptest.ResponseRecorder {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(raw))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("username", "tester")
+	for _, set := range keys {
+		for k, v := range set {
+			c.Set(k, v)
+		}
+	}
+	handler(c)
+	return w
+}
+
+func TestSyncKeepsTrash(t *testing.T) {
+	db := openTestDB(t, &models.Location{}, &models.SyncSnapshot{}, &models.AuditLog{})
+	h := NewResource[models.Location]("locations").WithNaturalKey("location")
+	locations := []models.Location{{Location: "A"}, {Location: "B", Zone: "old"}, {Location: "C"}}
+	if err := db.Create(&locations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&locations[1]).Error; err != nil {
+		t.Fatal(err)
+	}
+	state := func(t *testing.T) (live, trashed []string) {
+		t.Helper()
+		var rows []models.Location
+		if err := db.Unscoped().Order("location").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rows {
+			if r.DeletedAt.Valid {
+				trashed = append(trashed, r.Location+" "+r.Zone)
+			} else {
+				live = append(live, r.Location+" "+r.Zone)
+			}
+		}
+		return live, trashed
+	}
+
+	w := callHandler(t, h.Sync, nil, gin.H{"confirm": true, "data": []gin.H{{"location": "A"}, {"location": "B"}, {"location": "D"}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("sync: %d %s", w.Code, w.Body)
+	}
+	var synced struct {
+		SnapshotID uint `json:"snapshot_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &synced); err != nil {
+		t.Fatal(err)
+	}
+	if live, trashed := state(t); len(live) != 3 || len(trashed) != 0 {
+		t.Fatalf("after sync live %q trashed %q", live, trashed)
+	}
+
+	w = callHandler(t, h.RestoreSyncSnapshot, gin.Params{{Key: "snapshotId", Value: fmt.Sprint(synced.SnapshotID)}}, gin.H{"confirm": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", w.Code, w.Body)
+	}
+	live, trashed := state(t)
+	sort.Strings(live)
+	if len(live) != 2 || live[0] != "A " || live[1] != "C " {
+		t.Errorf("restored live %q, want A and C", live)
+	}
+	if len(trashed) != 1 || trashed[0] != "B old" {
+		t.Errorf("restored trash %q, want B old", trashed)
+	}
+
+	// The restore snapshotted the synced rows, so it can be undone as well
+	var snaps []models.SyncSnapshot
+	if err := db.Order("id").Find(&snaps).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 || snaps[0].RowCount != 3 || snaps[1].RowCount != 3 {
+		t.Errorf("snapshots %+v, want two of 3 rows", snaps)
+	}
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashPurger is implemented by every ResourceHandler so the retention job
+// can reach all resources without knowing their model types
+type trashPurger interface {
+	resourceName() string
+	purgeTrashBefore(cutoff time.Time) (int64, error)
+}
+
+var (
+	trashMu        sync.Mutex
+	trashResources = map[string]trashPurger{}
+)
+
+// registerTrash adds a resource to the retention job. Handlers sharing a
+// name (e.g. public read-only copies) share one table, so one entry is enough.
+func registerTrash(p trashPurger) {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	trashResources[p.resourceName()] = p
+}
+
+func (h *ResourceHandler[T]) resourceName() string {
+	return h.Name
+}
+
+// purgeTrashBefore hard-deletes records soft-deleted before cutoff
+func (h *ResourceHandler[T]) purgeTrashBefore(cutoff time.Time) (int64, error) {
+	res := database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// StartTrashRetention hard-deletes trash older than days from every
+// registered resource, once at startup and then every 24 hours.
+// days <= 0 disables the job. Purges by the job are logged but not audited;
+// the delete that put each record in the trash already is.
+func StartTrashRetention(days int) {
+	if days <= 0 {
+		log.Printf("[TRASH] Retention disabled")
+		return
+	}
+	log.Printf("[TRASH] Retention: purging soft-deleted records older than %d days", days)
+	go func() {
+		for {
+			runTrashRetention(days)
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
+
+// runTrashRetention performs one pass of the retention job
+func runTrashRetention(days int) {
+	cutoff := time.Now().AddDate(0, 0, -days)
+	trashMu.Lock()
+	resources := make([]trashPurger, 0, len(trashResources))
+	for _, p := range trashResources {
+		resources = append(resources, p)
+	}
+	trashMu.Unlock()
+
+	for _, p := range resources {
+		n, err := p.purgeTrashBefore(cutoff)
+		if err != nil {
+			log.Printf("[TRASH] %s: retention purge failed: %v", p.resourceName(), err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("[TRASH] %s: purged %d records deleted before %s", p.resourceName(), n, cutoff.Format("2006-01-02"))
+		}
+	}
+}
+
+// ListTrash returns soft-deleted records, most recently deleted first.
+// Supports page/pageSize like List; without page every record is returned.
+func (h *ResourceHandler[T]) ListTrash(c *gin.Context) {
+	query := warehouseDB(c).Unscoped().Model(new(T)).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query = query.Order("deleted_at DESC")
+	if pageStr := c.Query("page"); pageStr != "" {
+		page, _ := strconv.Atoi(pageStr)
+		if page < 1 {
+			page = 1
+		}
+		pageSize := pageSizeParam(c)
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+
+	var items []T
+	if err := query.Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items, "total": total})
+}
+
+// restoreIDs clears deleted_at on the given trashed records. Records whose
+// natural key is already used by a live record are left in the trash and
+// reported as conflicts, since restoring them would create a duplicate.
+func (h *ResourceHandler[T]) restoreIDs(tx *gorm.DB, ids []uint, username string) (int64, []string, error) {
+	var trashed []T
+	if err := tx.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", ids).Find(&trashed).Error; err != nil {
+		return 0, nil, err
+	}
+
+	conflicts := []string{}
+	restorable := make([]interface{}, 0, len(trashed))
+	if len(h.NaturalKey) > 0 && len(trashed) > 0 {
+		cols := getModelColumns(new(T))
+		firstKey, ok := cols[h.NaturalKey[0]]
+		if !ok {
+			return 0, nil, fmt.Errorf("%s: unknown natural key field %s", h.Name, h.NaturalKey[0])
+		}
+		var firstValues []string
+		for i := range trashed {
+			f, _ := fieldByJSON(reflect.ValueOf(&trashed[i]).Elem(), firstKey.JSON)
+			firstValues = append(firstValues, exportValue(f))
+		}
+		var live []T
+		if err := tx.Where(fmt.Sprintf("%s IN ?", tx.Statement.Quote(firstKey.Column)), firstValues).
+			Find(&live).Error; err != nil {
+			return 0, nil, err
+		}
+		taken := make(map[string]bool, len(live))
+		for i := range live {
+			taken[h.naturalKeyOf(&live[i])] = true
+		}
+		for i := range trashed {
+			key := h.naturalKeyOf(&trashed[i])
+			if taken[key] {
+				conflicts = append(conflicts, key)
+				continue
+			}
+			// Two trashed copies of the same key: only the first comes back
+			taken[key] = true
+			restorable = append(restorable, reflect.ValueOf(trashed[i]).FieldByName("ID").Interface())
+		}
+	} else {
+		for i := range trashed {
+			restorable = append(restorable, reflect.ValueOf(trashed[i]).FieldByName("ID").Interface())
+		}
+	}
+
+	if len(restorable) == 0 {
+		return 0, conflicts, nil
+	}
+	updates := map[string]interface{}{"deleted_at": nil}
+	if _, ok := getModelColumns(new(T))["updated_by"]; ok && username != "" {
+		updates["updated_by"] = username
+	}
+	res := tx.Unscoped().Model(new(T)).Where("id IN ?", restorable).Updates(updates)
+	if res.Error != nil {
+		return 0, nil, res.Error
+	}
+	entries := make([]models.AuditLog, len(restorable))
+	for i, id := range restorable {
+		entries[i] = h.newAuditLog(AuditRestore, username, id.(uint), nil)
+	}
+	return res.RowsAffected, conflicts, writeAudit(tx, entries...)
+}
+
+// Restore brings one soft-deleted record back
+func (h *ResourceHandler[T]) Restore(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var restored int64
+	var conflicts []string
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, conflicts, err = h.restoreIDs(tx, []uint{uint(id)}, c.GetString("username"))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A live record with the same key already exists", "key": conflicts[0]})
+		return
+	}
+	if restored == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found in trash"})
+		return
+	}
+	var item T
+	warehouseDB(c).First(&item, id)
+	log.Printf("[TRASH] %s: restored #%d by %s", h.Name, id, c.GetString("username"))
+	c.JSON(http.StatusOK, item)
+}
+
+// BulkRestore brings multiple soft-deleted records back by IDs
+func (h *ResourceHandler[T]) BulkRestore(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var restored int64
+	var conflicts []string
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, conflicts, err = h.restoreIDs(tx, req.IDs, c.GetString("username"))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[TRASH] %s: restored %d records by %s", h.Name, restored, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"restored": restored, "conflicts": conflicts})
+}
+
+// PurgeTrash permanently deletes trashed records by IDs (supervisor/leader only).
+// Only records already in the trash can be purged.
+func (h *ResourceHandler[T]) PurgeTrash(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	username := c.GetString("username")
+	var purged int
+	err := warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		var items []T
+		if err := tx.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", req.IDs).Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := tx.Unscoped().Delete(&items).Error; err != nil {
+			return err
+		}
+		// The audit row keeps the last values, since the record itself is gone
+		entries := make([]models.AuditLog, len(items))
+		for i := range items {
+			entries[i] = h.auditRecord(AuditPurge, username, &items[i], nil)
+		}
+		purged = len(items)
+		return writeAudit(tx, entries...)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[TRASH] %s: purged %d records by %s", h.Name, purged, username)
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// TOTP parameters (RFC 6238 defaults, understood by every authenticator app)
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // accept one step before and after, for clock drift
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpIssuer is the account label shown in authenticator apps (TOTP_ISSUER)
+func totpIssuer() string {
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		return v
+	}
+	return "Warehouse Report Monitoring"
+}
+
+// newTOTPSecret returns a random 160-bit base32 secret
+func newTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpCode computes the code of a secret for one time step (RFC 4226)
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// verifyTOTP checks a code and returns the time step it matched. Steps at
+// or before lastStep are refused so a code cannot be used twice.
+func verifyTOTP(secret, code string, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		want, err := totpCode(secret, step)
+		if err == nil && hmac.Equal([]byte(want), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// otpauthURI builds the enrollment URI that authenticator apps scan as a QR code
+func otpauthURI(secret, username string) string {
+	issuer := totpIssuer()
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + username)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// newRecoveryCodes replaces a user's recovery codes and returns the new ones
+// in clear text; only their hashes are stored
+func newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(b))
+		codes[i] = raw[:4] + "-" + raw[4:]
+		rows[i] = models.RecoveryCode{UserID: userID, Hash: hashToken(raw)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode consumes a recovery code, reporting whether it was valid
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) bool {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	res := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hashToken(code)).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// checkSecondFactor verifies a TOTP code or a recovery code for user
+func checkSecondFactor(user models.User, code, recoveryCode string) bool {
+	if recoveryCode != "" {
+		return useRecoveryCode(database.DB, user.ID, recoveryCode)
+	}
+	step, ok := verifyTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	// Only the first request to record this step wins
+	res := database.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// LoginTwoFactor is the second login step for accounts with 2FA enabled.
+// It takes the mfa_token from Login plus a TOTP code or a recovery code.
+// Wrong codes count towards the account lockout like wrong passwords.
+func LoginTwoFactor(c *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token dan kode wajib diisi"})
+		return
+	}
+	claims, ok := middleware.ParseMFAToken(req.MFAToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi login kedaluwarsa, silakan login ulang"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.Version || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi login kedaluwarsa, silakan login ulang"})
+		return
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		c.JSON(http.StatusLocked, gin.H{
+			"error":       "Akun terkunci karena terlalu banyak percobaan login gagal. Silakan coba lagi nanti.",
+			"retry_after": int(time.Until(*user.LockedUntil).Seconds()),
+		})
+		return
+	}
+	if !checkSecondFactor(user, req.Code, req.RecoveryCode) {
+		recordFailedLogin(user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kode 2FA salah"})
+		return
+	}
+	if user.FailedAttempts > 0 || user.LockedUntil != nil {
+		database.DB.Model(&user).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil})
+	}
+	if req.RecoveryCode != "" {
+		log.Printf("[AUTH] %s logged in with a recovery code", user.Username)
+	}
+
+	resp, _, err := issueSession(c, database.DB, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetupTwoFactor starts enrollment: it stores a new secret for the current
+// user and returns it with the otpauth URI. 2FA is only switched on once
+// EnableTwoFactor confirms a code from the app.
+func SetupTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA sudah aktif"})
+		return
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": otpauthURI(secret, user.Username),
+	})
+}
+
+// EnableTwoFactor confirms enrollment with a code from the app, switches
+// 2FA on and returns the recovery codes. They are shown only this once.
+func EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode wajib diisi"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA sudah aktif"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mulai setup 2FA terlebih dahulu"})
+		return
+	}
+	step, ok := verifyTOTP(user.TOTPSecret, req.Code, 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode 2FA salah"})
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[AUTH] %s enabled 2FA", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "2FA aktif", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes.
+// Requires a valid TOTP code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode wajib diisi"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA belum aktif"})
+		return
+	}
+	if !checkSecondFactor(user, req.Code, "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode 2FA salah"})
+		return
+	}
+	codes, err := newRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor switches 2FA off for the current user. Requires the
+// password and a TOTP or recovery code; refused when the role makes 2FA
+// mandatory.
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password wajib diisi"})
+		return
+	}
+	if middleware.RoleRequires2FA(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "2FA wajib untuk role ini"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA belum aktif"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password salah"})
+		return
+	}
+	if !checkSecondFactor(user, req.Code, req.RecoveryCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode 2FA salah"})
+		return
+	}
+	if err := resetTwoFactor(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[AUTH] %s disabled 2FA", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "2FA nonaktif"})
+}
+
+// resetTwoFactor removes a user's TOTP secret and recovery codes
+func resetTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// ResetUserTwoFactor clears another user's 2FA, e.g. after a lost phone
+// (supervisor/leader only). The user has to enroll again on next login if
+// their role requires it.
+func ResetUserTwoFactor(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := resetTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[AUTH] 2FA of %s reset by %s", user.Username, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "2FA user berhasil direset"})
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, cut to six digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	for step, want := range map[int64]string{1: "287082", 37037036: "081804", 37037037: "050471", 41152263: "005924"} {
+		got, err := totpCode(secret, step)
+		if err != nil || got != want {
+			t.Errorf("step %d: %q %v, want %q", step, got, err, want)
+		}
+	}
+	if got, _ := totpCode(strings.ToLower(secret), 1); got != "287082" {
+		t.Errorf("lowercase secret: %q, want 287082", got)
+	}
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Error("invalid secret: no error")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix() / totpPeriod
+	code := func(step int64) string {
+		c, err := totpCode(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	for _, step := range []int64{now - 1, now, now + 1} {
+		if got, ok := verifyTOTP(secret, code(step), 0); !ok || got != step {
+			t.Errorf("code of step now%+d: step %d %v", step-now, got, ok)
+		}
+	}
+	for _, step := range []int64{now - 3, now + 3} {
+		if _, ok := verifyTOTP(secret, code(step), 0); ok {
+			t.Errorf("code of step now%+d accepted", step-now)
+		}
+	}
+	spaced := code(now)[:3] + " " + code(now)[3:] + " "
+	if _, ok := verifyTOTP(secret, spaced, 0); !ok {
+		t.Errorf("code with spaces %q refused", spaced)
+	}
+	for _, bad := range []string{"", "12345", "1234567", code(now) + "0"} {
+		if _, ok := verifyTOTP(secret, bad, 0); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+
+	// A step that was already used, or one before it, is refused
+	if _, ok := verifyTOTP(secret, code(now), now); ok {
+		t.Error("code of the last used step accepted")
+	}
+	if _, ok := verifyTOTP(secret, code(now-1), now); ok {
+		t.Error("code of a step before the last used one accepted")
+	}
+	if got, ok := verifyTOTP(secret, code(now+1), now); !ok || got != now+1 {
+		t.Errorf("code of the step after the last used one: step %d %v", got, ok)
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	db, user := openSessionDB(t)
+	if err := db.AutoMigrate(&models.RecoveryCode{}); err != nil {
+		t.Fatal(err)
+	}
+	setPassword(t, db, user, "Gudang-2024")
+	me := gin.H{"user_id": user.ID, "role": user.Role}
+
+	// Enrollment: setup stores a secret, enable confirms it with a code
+	w := callHandler(t, SetupTwoFactor, nil, nil, me)
+	var setup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &setup); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("setup: %d %s", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/") || !strings.Contains(setup.URI, "secret="+setup.Secret) {
+		t.Errorf("otpauth URI %q", setup.URI)
+	}
+	now := time.Now().Unix() / totpPeriod
+	code, _ := totpCode(setup.Secret, now)
+	stale, _ := totpCode(setup.Secret, now-5)
+	if w := callHandler(t, EnableTwoFactor, nil, gin.H{"code": stale}, me); w.Code != http.StatusBadRequest {
+		t.Errorf("enable with a wrong code: %d, want 400", w.Code)
+	}
+	w = callHandler(t, EnableTwoFactor, nil, gin.H{"code": code}, me)
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &enabled); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("enable: %d %s", w.Code, w.Body)
+	}
+	if len(enabled.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(enabled.RecoveryCodes), recoveryCodeCount)
+	}
+
+	// The password alone only earns an MFA token
+	mfaToken := func() string {
+		t.Helper()
+		w := login(t, "alice", "Gudang-2024")
+		var resp struct {
+			Required bool   `json:"mfa_required"`
+			Token    string `json:"mfa_token"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || !resp.Required || resp.Token == "" {
+			t.Fatalf("login with 2FA on: %d %s", w.Code, w.Body)
+		}
+		return resp.Token
+	}
+	second := func(body gin.H) int {
+		t.Helper()
+		body["mfa_token"] = mfaToken()
+		return callHandler(t, LoginTwoFactor, nil, body).Code
+	}
+
+	// The code that confirmed enrollment cannot log in again
+	if got := second(gin.H{"code": code}); got != http.StatusUnauthorized {
+		t.Errorf("enrollment code replayed: %d, want 401", got)
+	}
+	next, _ := totpCode(setup.Secret, now+1)
+	if got := second(gin.H{"code": next}); got != http.StatusOK {
+		t.Fatalf("fresh code: %d", got)
+	}
+	if got := second(gin.H{"code": next}); got != http.StatusUnauthorized {
+		t.Errorf("code replayed: %d, want 401", got)
+	}
+	var stored models.User
+	db.First(&stored, user.ID)
+	if stored.TOTPLastStep != now+1 {
+		t.Errorf("totp_last_step %d, want %d", stored.TOTPLastStep, now+1)
+	}
+	if stored.FailedAttempts != 1 {
+		t.Errorf("%d failed attempts after a replayed code, want 1", stored.FailedAttempts)
+	}
+
+	// Recovery codes work once each, written with or without the dash
+	recovery := enabled.RecoveryCodes[0]
+	if got := second(gin.H{"recovery_code": strings.ToUpper(strings.ReplaceAll(recovery, "-", ""))}); got != http.StatusOK {
+		t.Fatalf("recovery code: %d", got)
+	}
+	if got := second(gin.H{"recovery_code": recovery}); got != http.StatusUnauthorized {
+		t.Errorf("used recovery code: %d, want 401", got)
+	}
+	if got := second(gin.H{"recovery_code": enabled.RecoveryCodes[1]}); got != http.StatusOK {
+		t.Errorf("second recovery code: %d", got)
+	}
+	var unused int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&unused)
+	if unused != recoveryCodeCount-2 {
+		t.Errorf("%d unused recovery codes, want %d", unused, recoveryCodeCount-2)
+	}
+
+	if got := callHandler(t, LoginTwoFactor, nil, gin.H{"mfa_token": "forged", "code": next}).Code; got != http.StatusUnauthorized {
+		t.Errorf("forged MFA token: %d, want 401", got)
+	}
+	if got := callHandler(t, LoginTwoFactor, nil, gin.H{"mfa_token": mfaToken()}).Code; got != http.StatusBadRequest {
+		t.Errorf("no code: %d, want 400", got)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"strconv"
 	"time"
 
-	"warehouse-report-monitoring/internal/database"
 	minioClient "warehouse-report-monitoring/internal/minio"
 	"warehouse-report-monitoring/internal/models"
 
@@ -22,12 +21,28 @@ func NewUnboxingHandler() *UnboxingHandler {
 	return &UnboxingHandler{}
 }
 
+// unboxingSort parses the sort parameter, defaulting to newest first
+func unboxingSort(c *gin.Context) ([]sortKey, error) {
+	cols := getModelColumns(&models.ReturnUnboxing{})
+	raw := c.Query("sort")
+	if raw == "" {
+		raw = "-created_at"
+	}
+	return parseSort(raw, cols)
+}
+
 // List returns all unboxing records
 func (h *UnboxingHandler) List(c *gin.Context) {
+	keys, err := unboxingSort(c)
+	if err != nil {
+		respondQueryError(c, err)
+		return
+	}
+
 	pageStr := c.Query("page")
 	if pageStr == "" {
 		var items []models.ReturnUnboxing
-		if err := database.DB.Order("created_at DESC").Find(&items).Error; err != nil {
+		if err := applySort(warehouseDB(c), keys).Find(&items).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -40,7 +55,7 @@ func (h *UnboxingHandler) List(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
 	if pageSize < 1 { pageSize = 50 }
 
-	query := database.DB.Model(&models.ReturnUnboxing{})
+	query := warehouseDB(c).Model(&models.ReturnUnboxing{})
 
 	search := c.Query("search")
 	if search != "" {
@@ -62,7 +77,7 @@ func (h *UnboxingHandler) List(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 	var items []models.ReturnUnboxing
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
+	if err := applySort(query, keys).Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -133,7 +148,7 @@ func (h *UnboxingHandler) Upload(c *gin.Context) {
 		UpdatedBy:  username,
 	}
 
-	if err := database.DB.Create(&record).Error; err != nil {
+	if err := warehouseDB(c).Create(&record).Error; err != nil {
 		// Clean up uploaded video on DB failure
 		_ = minioClient.DeleteVideo(objectKey)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -149,7 +164,7 @@ func (h *UnboxingHandler) Upload(c *gin.Context) {
 func (h *UnboxingHandler) GetVideo(c *gin.Context) {
 	id := c.Param("id")
 	var record models.ReturnUnboxing
-	if err := database.DB.First(&record, id).Error; err != nil {
+	if err := warehouseDB(c).First(&record, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 			return
@@ -182,7 +197,7 @@ func (h *UnboxingHandler) GetVideo(c *gin.Context) {
 func (h *UnboxingHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	var record models.ReturnUnboxing
-	if err := database.DB.First(&record, id).Error; err != nil {
+	if err := warehouseDB(c).First(&record, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 			return
@@ -199,7 +214,7 @@ func (h *UnboxingHandler) Delete(c *gin.Context) {
 	}
 
 	// Soft-delete record
-	if err := database.DB.Delete(&record).Error; err != nil {
+	if err := warehouseDB(c).Delete(&record).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Sources of a daily unloading figure
+const (
+	UnloadingFromEvents = "events"
+	UnloadingManual     = "manual"
+)
+
+// UnloadingEventTime is an unloading event with how long the vehicle took
+// to unload, zero until it is finished
+type UnloadingEventTime struct {
+	models.UnloadingEvent
+	Minutes int `json:"minutes"`
+}
+
+// timeUnloading measures an unloading event
+func timeUnloading(e models.UnloadingEvent) UnloadingEventTime {
+	out := UnloadingEventTime{UnloadingEvent: e}
+	if e.StartTime.Valid && e.FinishTime.Valid {
+		if d := e.FinishTime.Time.Sub(e.StartTime.Time); d > 0 {
+			out.Minutes = int(d / time.Minute)
+		}
+	}
+	return out
+}
+
+// loadUnloadingEvents reads the events of the given receipts, keyed by receipt
+func loadUnloadingEvents(db *gorm.DB, receiptKeys []string) (map[string][]models.UnloadingEvent, error) {
+	byKey := map[string][]models.UnloadingEvent{}
+	for _, chunk := range chunkKeys(receiptKeys) {
+		var events []models.UnloadingEvent
+		if err := db.Where("LOWER(TRIM(receipt_no)) IN ?", chunk).Order("start_time, id").Find(&events).Error; err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			key := receiptKey(e.ReceiptNo)
+			byKey[key] = append(byKey[key], e)
+		}
+	}
+	return byKey, nil
+}
+
+// ArrivalUnloading is an arrival with the vehicles unloaded for its receipt.
+// UnloadingMinutes runs from the first vehicle starting to the last one
+// finishing, once every vehicle is finished.
+type ArrivalUnloading struct {
+	models.Arrival
+	Vehicles         int                  `json:"vehicles"`
+	Finished         int                  `json:"finished_vehicles"`
+	FirstStart       models.FlexDate      `json:"first_start"`
+	LastFinish       models.FlexDate      `json:"last_finish"`
+	UnloadingMinutes int                  `json:"unloading_minutes"`
+	Events           []UnloadingEventTime `json:"events"`
+}
+
+// summarizeUnloading adds up the unloading events of an arrival
+func summarizeUnloading(a models.Arrival, events []models.UnloadingEvent) ArrivalUnloading {
+	out := ArrivalUnloading{Arrival: a, Vehicles: len(events), Events: make([]UnloadingEventTime, len(events))}
+	for i, e := range events {
+		out.Events[i] = timeUnloading(e)
+		if e.StartTime.Valid && (!out.FirstStart.Valid || e.StartTime.Time.Before(out.FirstStart.Time)) {
+			out.FirstStart = e.StartTime
+		}
+		if e.FinishTime.Valid {
+			out.Finished++
+			if !out.LastFinish.Valid || e.FinishTime.Time.After(out.LastFinish.Time) {
+				out.LastFinish = e.FinishTime
+			}
+		}
+	}
+	if out.Vehicles > 0 && out.Finished == out.Vehicles && out.FirstStart.Valid {
+		if d := out.LastFinish.Time.Sub(out.FirstStart.Time); d > 0 {
+			out.UnloadingMinutes = int(d / time.Minute)
+		}
+	}
+	return out
+}
+
+// ListArrivalUnloading serves GET /arrivals/unloading: arrivals with their
+// unloaded vehicles, matched on receipt_no trimmed and case-insensitively.
+// It takes the same filter, search, date and sort parameters as the arrivals
+// list; without page it returns a flat array, with page/pageSize
+// {"data", "total"}.
+func ListArrivalUnloading(h *ResourceHandler[models.Arrival]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := h.listQuery(c)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+		keys, err := h.sortKeys(c)
+		if err != nil {
+			respondQueryError(c, err)
+			return
+		}
+
+		var total int64
+		paged := c.Query("page") != ""
+		if paged {
+			page, _ := strconv.Atoi(c.Query("page"))
+			if page < 1 {
+				page = 1
+			}
+			pageSize := pageSizeParam(c)
+			if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+		}
+		var arrivals []models.Arrival
+		if err := applySort(query, keys).Find(&arrivals).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var receiptKeys []string
+		for _, a := range arrivals {
+			if key := receiptKey(a.ReceiptNo); key != "" {
+				receiptKeys = append(receiptKeys, key)
+			}
+		}
+		events, err := loadUnloadingEvents(warehouseDB(c), receiptKeys)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items := make([]ArrivalUnloading, len(arrivals))
+		for i, a := range arrivals {
+			var mine []models.UnloadingEvent
+			if key := receiptKey(a.ReceiptNo); key != "" {
+				mine = events[key]
+			}
+			items[i] = summarizeUnloading(a, mine)
+		}
+
+		if !paged {
+			c.JSON(http.StatusOK, items)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": items, "total": total})
+	}
+}
+
+// UnloadingDaily is the unloading of a brand and vehicle type on one day.
+// Figures come from the unloading events when there are any for the day and
+// brand, and from the hand-entered unloadings otherwise. Minutes figures
+// cover finished vehicles only.
+type UnloadingDaily struct {
+	Date          models.FlexDate `json:"date"`
+	Brand         string          `json:"brand"`
+	VehicleType   string          `json:"vehicle_type"`
+	TotalVehicles int             `json:"total_vehicles"`
+	Finished      int             `json:"finished_vehicles"`
+	AvgMinutes    float64         `json:"avg_minutes"`
+	MaxMinutes    int             `json:"max_minutes"`
+	CrewCount     int             `json:"crew_count"`
+	Source        string          `json:"source"`
+	minutes       int
+}
+
+// withDateRange limits a query to startDate..endDate on its date column
+func withDateRange(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if v := c.Query("startDate"); v != "" {
+		query = query.Where("date >= ?", v)
+	}
+	if v := c.Query("endDate"); v != "" {
+		query = query.Where("date <= ?", v+" 23:59:59")
+	}
+	return query
+}
+
+// dayOf returns the YYYY-MM-DD day of a date, or "" when it is not set
+func dayOf(d models.FlexDate) string {
+	if !d.Valid {
+		return ""
+	}
+	return d.Time.Format("2006-01-02")
+}
+
+// DailyUnloading serves GET /unloadings/daily: the unloading figures per day,
+// brand and vehicle type, derived from the unloading events. Events without
+// a brand take the brand of the arrival on their receipt. Hand-entered
+// unloadings are kept for the days and brands without any event. Takes
+// startDate and endDate (YYYY-MM-DD) and brand.
+func DailyUnloading(c *gin.Context) {
+	db := warehouseDB(c)
+	brand := strings.TrimSpace(c.Query("brand"))
+
+	var events []models.UnloadingEvent
+	if err := withDateRange(c, db.Model(&models.UnloadingEvent{})).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var receiptKeys []string
+	seen := map[string]bool{}
+	for _, e := range events {
+		if key := receiptKey(e.ReceiptNo); key != "" && strings.TrimSpace(e.Brand) == "" && !seen[key] {
+			seen[key] = true
+			receiptKeys = append(receiptKeys, key)
+		}
+	}
+	arrivalBrand := map[string]string{}
+	for _, chunk := range chunkKeys(receiptKeys) {
+		var arrivals []models.Arrival
+		if err := db.Select("receipt_no, brand").Where("LOWER(TRIM(receipt_no)) IN ?", chunk).Order("id").Find(&arrivals).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, a := range arrivals {
+			if key := receiptKey(a.ReceiptNo); arrivalBrand[key] == "" {
+				arrivalBrand[key] = strings.TrimSpace(a.Brand)
+			}
+		}
+	}
+
+	groups := map[string]*UnloadingDaily{}
+	covered := map[string]bool{}
+	for _, e := range events {
+		b := strings.TrimSpace(e.Brand)
+		if b == "" {
+			b = arrivalBrand[receiptKey(e.ReceiptNo)]
+		}
+		if brand != "" && !strings.EqualFold(b, brand) {
+			continue
+		}
+		day := dayOf(e.Date)
+		vehicle := strings.TrimSpace(e.VehicleType)
+		key := strings.ToLower(day + "|" + b + "|" + vehicle)
+		g := groups[key]
+		if g == nil {
+			g = &UnloadingDaily{Date: models.ParseFlexDate(day), Brand: b, VehicleType: vehicle, Source: UnloadingFromEvents}
+			groups[key] = g
+		}
+		covered[strings.ToLower(day+"|"+b)] = true
+		g.TotalVehicles++
+		g.CrewCount += e.CrewCount
+		if e.FinishTime.Valid {
+			minutes := timeUnloading(e).Minutes
+			g.Finished++
+			g.minutes += minutes
+			g.MaxMinutes = max(g.MaxMinutes, minutes)
+			g.AvgMinutes = float64(g.minutes) / float64(g.Finished)
+		}
+	}
+
+	manualQuery := withDateRange(c, db.Model(&models.Unloading{}))
+	if brand != "" {
+		manualQuery = manualQuery.Where("LOWER(TRIM(brand)) = ?", strings.ToLower(brand))
+	}
+	var manual []models.Unloading
+	if err := manualQuery.Find(&manual).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]UnloadingDaily, 0, len(groups)+len(manual))
+	for _, u := range manual {
+		day := dayOf(u.Date)
+		if covered[strings.ToLower(day+"|"+strings.TrimSpace(u.Brand))] {
+			continue
+		}
+		out = append(out, UnloadingDaily{
+			Date:          u.Date,
+			Brand:         u.Brand,
+			VehicleType:   u.VehicleType,
+			TotalVehicles: u.TotalVehicles,
+			Source:        UnloadingManual,
+		})
+	}
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if di, dj := out[i].Date.String(), out[j].Date.String(); di != dj {
+			return di < dj
+		}
+		if out[i].Brand != out[j].Brand {
+			return out[i].Brand < out[j].Brand
+		}
+		return out[i].VehicleType < out[j].VehicleType
+	})
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errConflict   = errors.New("record was modified by someone else")
+	errValidation = errors.New("validation failed")
+)
+
+// patchProtectedFields cannot be changed through PUT or PATCH
+var patchProtectedFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// WithProtectedFields sets json fields that the generic writes leave alone:
+// a new record starts them at their zero value (or column default) and a
+// write to an existing record keeps the stored value. This holds for Create,
+// Update and Patch as well as for BatchImport, ImportFile, Sync and snapshot
+// restore. Use it for fields that only a dedicated endpoint may change, such
+// as a workflow status.
+func (h *ResourceHandler[T]) WithProtectedFields(fields ...string) *ResourceHandler[T] {
+	h.Protected = append(h.Protected, fields...)
+	return h
+}
+
+// isProtected reports whether a json field is protected on this resource
+func (h *ResourceHandler[T]) isProtected(field string) bool {
+	for _, f := range h.Protected {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// keepProtected copies the protected fields of src onto item
+func (h *ResourceHandler[T]) keepProtected(item, src *T) {
+	if len(h.Protected) == 0 {
+		return
+	}
+	dst, from := reflect.ValueOf(item).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		name := strings.Split(dst.Type().Field(i).Tag.Get("json"), ",")[0]
+		if h.isProtected(name) {
+			dst.Field(i).Set(from.Field(i))
+		}
+	}
+}
+
+// clearProtected resets the protected fields of items to their zero values,
+// as Create does for a single record
+func (h *ResourceHandler[T]) clearProtected(items []T) {
+	var zero T
+	for i := range items {
+		h.keepProtected(&items[i], &zero)
+	}
+}
+
+// etagOf derives a record's ETag from its ID and updated_at. Microsecond
+// precision matches what PostgreSQL stores, so the tag survives a reload.
+func etagOf(item interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	id := v.FieldByName("ID").Interface()
+	updatedAt, _ := v.FieldByName("UpdatedAt").Interface().(time.Time)
+	return fmt.Sprintf(`"%v-%d"`, id, updatedAt.UnixMicro())
+}
+
+// etagMatches checks an If-Match header against the current ETag
+func etagMatches(ifMatch, current string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// update applies the request body to a record inside a transaction with the
+// row locked. If-Match is optional; when sent and stale, a 409 is returned
+// with the current row so the client can merge. With partial set, only the
+// columns present in the body (plus updated_by/updated_at) are written.
+func (h *ResourceHandler[T]) update(c *gin.Context, partial bool) {
+	id := c.Param("id")
+	ifMatch := c.GetHeader("If-Match")
+
+	body, keys, err := readBodyWithUpdatedBy(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var columns []string
+	if partial {
+		cols := getModelColumns(new(T))
+		var unknown []string
+		for _, k := range keys {
+			if patchProtectedFields[k] || h.isProtected(k) {
+				continue
+			}
+			col, ok := cols[k]
+			if !ok {
+				unknown = append(unknown, k)
+				continue
+			}
+			columns = append(columns, col.Column)
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown fields: " + strings.Join(unknown, ", "), "unknown_fields": unknown})
+			return
+		}
+		columns = append(columns, "updated_at")
+	}
+
+	var item T
+	var verrs []FieldError
+	err = warehouseDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, id).Error; err != nil {
+			return err
+		}
+		if ifMatch != "" && !etagMatches(ifMatch, etagOf(&item)) {
+			return errConflict
+		}
+
+		before := item
+		v := reflect.ValueOf(&item).Elem()
+		origID := v.FieldByName("ID").Interface()
+		origCreated := v.FieldByName("CreatedAt").Interface()
+		if err := json.Unmarshal(body, &item); err != nil {
+			return err
+		}
+		// The URL decides which record is written, not the body
+		v.FieldByName("ID").Set(reflect.ValueOf(origID))
+		v.FieldByName("CreatedAt").Set(reflect.ValueOf(origCreated))
+		h.keepProtected(&item, &before)
+
+		if verrs = h.validate(&item); len(verrs) > 0 {
+			return errValidation
+		}
+		var write *gorm.DB
+		if partial {
+			write = tx.Model(&item).Select(columns).Updates(&item)
+		} else {
+			write = tx.Save(&item)
+		}
+		if write.Error != nil {
+			return write.Error
+		}
+		if changes := h.auditDiff(&before, &item); len(changes) > 0 {
+			return writeAudit(tx, h.newAuditLog(AuditUpdate, c.GetString("username"), recordID(&item), changes))
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		c.Header("ETag", etagOf(&item))
+		c.JSON(http.StatusOK, item)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	case errors.Is(err, errConflict):
+		c.Header("ETag", etagOf(&item))
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Record was modified by someone else. Reload and try again.",
+			"current": item,
+		})
+	case errors.Is(err, errValidation):
+		respondValidationError(c, verrs)
+	default:
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
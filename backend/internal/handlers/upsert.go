@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Import modes for rows whose natural key already exists
+const (
+	ImportInsertOnly   = "insert-only"
+	ImportUpsert       = "upsert"
+	ImportSkipExisting = "skip-existing"
+)
+
+// ImportResult counts what an import did with each row
+type ImportResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// resolveImportMode validates the requested mode. The default is upsert for
+// resources with a natural key and insert-only for the rest.
+func (h *ResourceHandler[T]) resolveImportMode(mode string) (string, error) {
+	if mode == "" {
+		if len(h.NaturalKey) > 0 {
+			return ImportUpsert, nil
+		}
+		return ImportInsertOnly, nil
+	}
+	switch mode {
+	case ImportInsertOnly:
+		return mode, nil
+	case ImportUpsert, ImportSkipExisting:
+		if len(h.NaturalKey) == 0 {
+			return "", fmt.Errorf("%s has no natural key, only %s is supported", h.Name, ImportInsertOnly)
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("import_mode must be %s, %s or %s", ImportInsertOnly, ImportUpsert, ImportSkipExisting)
+	}
+}
+
+// importBatches writes data in batches of 500 inside the given transaction.
+// For upsert and skip-existing, rows are matched to existing records by
+// natural key (including soft-deleted ones, which are revived on match so
+// unique indexes are not violated). Within one import a later row with the
+// same key replaces an earlier one on upsert and is skipped otherwise.
+// Inserted rows start the protected fields at zero and go through the create
+// hooks; overwritten rows keep their stored values. Every inserted or
+// overwritten record is audited under actor.
+func (h *ResourceHandler[T]) importBatches(tx *gorm.DB, data []T, mode, actor string) (ImportResult, error) {
+	var res ImportResult
+	batchSize := 500
+	h.clearProtected(data)
+
+	if mode == ImportInsertOnly {
+		for i := 0; i < len(data); i += batchSize {
+			end := i + batchSize
+			if end > len(data) {
+				end = len(data)
+			}
+			batch := data[i:end]
+			for j := range batch {
+				if err := h.runCreateHooks(tx, &batch[j]); err != nil {
+					return res, err
+				}
+			}
+			if err := tx.Create(&batch).Error; err != nil {
+				return res, err
+			}
+			if err := writeAudit(tx, h.auditCreates(batch, actor)...); err != nil {
+				return res, err
+			}
+			res.Inserted += len(batch)
+		}
+		return res, nil
+	}
+
+	cols := getModelColumns(new(T))
+	firstKey, ok := cols[h.NaturalKey[0]]
+	if !ok {
+		return res, fmt.Errorf("%s: unknown natural key field %s", h.Name, h.NaturalKey[0])
+	}
+
+	for i := 0; i < len(data); i += batchSize {
+		end := i + batchSize
+		if end > len(data) {
+			end = len(data)
+		}
+		batch := data[i:end]
+
+		// Load candidates by the first key column, then match on the full key
+		var firstValues []string
+		for j := range batch {
+			f, _ := fieldByJSON(reflect.ValueOf(&batch[j]).Elem(), firstKey.JSON)
+			firstValues = append(firstValues, exportValue(f))
+		}
+		var candidates []T
+		if err := tx.Unscoped().Where(fmt.Sprintf("%s IN ?", tx.Statement.Quote(firstKey.Column)), firstValues).
+			Find(&candidates).Error; err != nil {
+			return res, err
+		}
+		existing := make(map[string]*T, len(candidates))
+		for j := range candidates {
+			existing[h.naturalKeyOf(&candidates[j])] = &candidates[j]
+		}
+
+		var inserts []T
+		var audits []models.AuditLog
+		insertAt := make(map[string]int)
+		for j := range batch {
+			item := batch[j]
+			key := h.naturalKeyOf(&item)
+
+			if idx, dup := insertAt[key]; dup {
+				if mode == ImportUpsert {
+					inserts[idx] = item
+				} else {
+					res.Skipped++
+				}
+				continue
+			}
+
+			match, found := existing[key]
+			if !found {
+				insertAt[key] = len(inserts)
+				inserts = append(inserts, item)
+				continue
+			}
+
+			matchVal := reflect.ValueOf(match).Elem()
+			deleted := !matchVal.FieldByName("DeletedAt").FieldByName("Valid").IsZero()
+			if mode == ImportSkipExisting && !deleted {
+				res.Skipped++
+				continue
+			}
+
+			// Overwrite the matched row, keeping its identity
+			before := *match
+			itemVal := reflect.ValueOf(&item).Elem()
+			itemVal.FieldByName("ID").Set(matchVal.FieldByName("ID"))
+			itemVal.FieldByName("CreatedAt").Set(matchVal.FieldByName("CreatedAt"))
+			h.keepProtected(&item, match)
+			if err := tx.Unscoped().Save(&item).Error; err != nil {
+				return res, err
+			}
+			*match = item
+			if deleted {
+				res.Inserted++
+				audits = append(audits, h.auditRecord(AuditRestore, actor, &before, &item))
+			} else {
+				res.Updated++
+				audits = append(audits, h.auditRecord(AuditUpdate, actor, &before, &item))
+			}
+		}
+
+		if len(inserts) > 0 {
+			for j := range inserts {
+				if err := h.runCreateHooks(tx, &inserts[j]); err != nil {
+					return res, err
+				}
+			}
+			if err := tx.Create(&inserts).Error; err != nil {
+				return res, err
+			}
+			res.Inserted += len(inserts)
+			audits = append(audits, h.auditCreates(inserts, actor)...)
+		}
+		if err := writeAudit(tx, audits...); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// auditCreates builds the create audit rows for freshly inserted records
+func (h *ResourceHandler[T]) auditCreates(items []T, actor string) []models.AuditLog {
+	entries := make([]models.AuditLog, len(items))
+	for i := range items {
+		entries[i] = h.auditRecord(AuditCreate, actor, nil, &items[i])
+	}
+	return entries
+}
@@ -1,25 +1,48 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/middleware"
 	"warehouse-report-monitoring/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-// isSuperRole checks if the role is supervisor or leader
+// isSuperRole checks if the role is a super role (roles.is_super, e.g. supervisor and leader)
 func isSuperRole(role string) bool {
-	return role == "supervisor" || role == "leader"
+	return middleware.IsSuperRole(role)
+}
+
+// roleExists checks that a role is defined in the roles table
+func roleExists(name string) bool {
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
 }
 
 // UserResponse is the public user representation (no password)
 type UserResponse struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Role               string     `json:"role"`
+	LockedUntil        *time.Time `json:"locked_until,omitempty"`
+	MustChangePassword bool       `json:"must_change_password"`
+	Warehouses         []string   `json:"warehouses"`
+}
+
+// toUserResponse hides the password and reports a lock only while it lasts
+func toUserResponse(u models.User) UserResponse {
+	resp := UserResponse{ID: u.ID, Username: u.Username, Role: u.Role, MustChangePassword: u.MustChangePassword, Warehouses: userWarehouses(u.ID)}
+	if u.LockedUntil != nil && time.Now().Before(*u.LockedUntil) {
+		resp.LockedUntil = u.LockedUntil
+	}
+	return resp
 }
 
 // ListUsers returns all users (supervisor/leader only)
@@ -38,12 +61,13 @@ func ListUsers(c *gin.Context) {
 
 	var result []UserResponse
 	for _, u := range users {
-		result = append(result, UserResponse{ID: u.ID, Username: u.Username, Role: u.Role})
+		result = append(result, toUserResponse(u))
 	}
 	c.JSON(http.StatusOK, result)
 }
 
-// CreateUser creates a new user (supervisor/leader only)
+// CreateUser creates a new user (supervisor/leader only). The user is
+// assigned to the given warehouses, or to the default warehouse if none.
 func CreateUser(c *gin.Context) {
 	role := c.GetString("role")
 	if !isSuperRole(role) {
@@ -52,14 +76,32 @@ func CreateUser(c *gin.Context) {
 	}
 
 	var req struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-		Role     string `json:"role" binding:"required"`
+		Username   string   `json:"username" binding:"required"`
+		Password   string   `json:"password" binding:"required"`
+		Role       string   `json:"role" binding:"required"`
+		Warehouses []string `json:"warehouses"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username, password, dan role wajib diisi"})
 		return
 	}
+	if !roleExists(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role tidak dikenal"})
+		return
+	}
+	if len(req.Warehouses) == 0 {
+		req.Warehouses = []string{database.DefaultWarehouse}
+	}
+	for _, code := range req.Warehouses {
+		if !warehouseExists(code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gudang tidak dikenal: " + code})
+			return
+		}
+	}
+	if problems := checkPasswordPolicy(req.Password, req.Username); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password tidak memenuhi kebijakan", "problems": problems})
+		return
+	}
 
 	// Check if username already exists
 	var existing models.User
@@ -79,17 +121,25 @@ func CreateUser(c *gin.Context) {
 		Password: string(hash),
 		Role:     req.Role,
 	}
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return assignWarehouses(tx, user.ID, req.Warehouses)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, UserResponse{ID: user.ID, Username: user.Username, Role: user.Role})
+	c.JSON(http.StatusCreated, toUserResponse(user))
 }
 
 // ChangePassword changes a user's password
 // - All roles can change their own password
 // - Supervisor/leader can change any user's password
+// The new password must meet the password policy and differ from the last
+// PASSWORD_HISTORY passwords.
 func ChangePassword(c *gin.Context) {
 	role := c.GetString("role")
 	currentUserID := c.GetUint("user_id")
@@ -128,16 +178,64 @@ func ChangePassword(c *gin.Context) {
 		}
 	}
 
+	if problems := checkPasswordPolicy(req.NewPassword, user.Username); len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password tidak memenuhi kebijakan", "problems": problems})
+		return
+	}
+	reused, err := passwordReused(database.DB, user, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if reused {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Password tidak boleh sama dengan %d password terakhir", currentPasswordPolicy().History)})
+		return
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal hash password"})
 		return
 	}
 
-	database.DB.Model(&user).Update("password", string(hash))
+	// End every session of the user so the old password's tokens stop working.
+	// A password set by someone else must be changed by the user at next login
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := rememberPassword(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password":             string(hash),
+			"password_changed_at":  time.Now(),
+			"must_change_password": user.ID != currentUserID,
+		}).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diubah"})
 }
 
+// UnlockUser clears a login lockout and the failed-attempt counter
+// (supervisor/leader only)
+func UnlockUser(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	database.DB.Model(&user).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil})
+	c.JSON(http.StatusOK, gin.H{"message": "User berhasil dibuka"})
+}
+
 // ChangeRole changes a user's role (supervisor/leader only)
 func ChangeRole(c *gin.Context) {
 	role := c.GetString("role")
@@ -154,6 +252,10 @@ func ChangeRole(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Role wajib diisi"})
 		return
 	}
+	if !roleExists(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role tidak dikenal"})
+		return
+	}
 
 	var user models.User
 	if err := database.DB.First(&user, targetID).Error; err != nil {
@@ -161,7 +263,17 @@ func ChangeRole(c *gin.Context) {
 		return
 	}
 
-	database.DB.Model(&user).Update("role", req.Role)
+	// Expire the user's access tokens so the new role applies on their next refresh
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return bumpTokenVersion(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Role berhasil diubah"})
 }
 
@@ -187,6 +299,8 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.RefreshToken{})
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.UserWarehouse{})
 	database.DB.Unscoped().Delete(&user)
 	c.JSON(http.StatusOK, gin.H{"message": "User berhasil dihapus"})
 }
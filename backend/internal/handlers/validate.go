@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes a single failed validation rule on a model field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// init registers FlexDate support on gin's validator before any request is
+// bound, since the validator is shared by every binding in the process.
+// FlexDate is validated as its normalized string, so binding:"required" on a
+// date field rejects empty or unparseable dates. Error field names are the
+// json names.
+func init() {
+	v := validatorEngine()
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if fd, ok := field.Interface().(models.FlexDate); ok {
+			return fd.String()
+		}
+		return nil
+	}, models.FlexDate{})
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// validatorEngine returns gin's validator
+func validatorEngine() *validator.Validate {
+	return binding.Validator.Engine().(*validator.Validate)
+}
+
+// validateRecord checks a record against its binding tags
+func validateRecord(item interface{}) []FieldError {
+	err := validatorEngine().Struct(item)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []FieldError{{Message: err.Error()}}
+	}
+	out := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		out = append(out, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return out
+}
+
+// fieldErrorMessage renders a readable message for a validator error
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", fe.Field(), fe.Param())
+	default:
+		return fmt.Sprintf("%s failed %s validation", fe.Field(), fe.Tag())
+	}
+}
+
+// WithValidator adds a cross-field rule checked on every write path
+// (Create, Update, BatchImport, ImportFile and Sync), after the binding tags.
+func (h *ResourceHandler[T]) WithValidator(rule func(item *T) []FieldError) *ResourceHandler[T] {
+	h.Validators = append(h.Validators, rule)
+	return h
+}
+
+// validate checks a record against its binding tags and the resource's rules
+func (h *ResourceHandler[T]) validate(item *T) []FieldError {
+	errs := validateRecord(item)
+	for _, rule := range h.Validators {
+		errs = append(errs, rule(item)...)
+	}
+	return errs
+}
+
+// validateAll checks every record of a batch. Row is the 1-based position
+// of the record in the batch.
+func (h *ResourceHandler[T]) validateAll(items []T) []RowError {
+	var out []RowError
+	for i := range items {
+		if errs := h.validate(&items[i]); len(errs) > 0 {
+			out = append(out, RowError{Row: i + 1, Errors: errs})
+		}
+	}
+	return out
+}
+
+// respondValidationError writes a 400 listing the failed fields
+func respondValidationError(c *gin.Context, errs []FieldError) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "Validation failed",
+		"fields": errs,
+	})
+}
+
+// respondBatchValidationError writes a 400 listing the failed rows of a batch
+func respondBatchValidationError(c *gin.Context, rows []RowError) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":        "Validation failed",
+		"invalid_rows": len(rows),
+		"rows":         rows,
+	})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// warehouseDB returns the database confined to the request's active warehouse
+// (see middleware.ActiveWarehouse and database.WithWarehouse)
+func warehouseDB(c *gin.Context) *gorm.DB {
+	return database.DB.WithContext(database.WithWarehouse(c.Request.Context(), c.GetString("warehouse")))
+}
+
+// warehouseExists checks that a warehouse is defined and active
+func warehouseExists(code string) bool {
+	var count int64
+	database.DB.Model(&models.Warehouse{}).Where("code = ? AND is_active = ?", code, true).Count(&count)
+	return count > 0
+}
+
+// WarehouseRequest is the body of CreateWarehouse and UpdateWarehouse.
+// The code cannot be changed once records refer to it.
+type WarehouseRequest struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// ListWarehouses returns every warehouse (supervisor/leader only)
+func ListWarehouses(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var warehouses []models.Warehouse
+	if err := database.DB.Order("code").Find(&warehouses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// CreateWarehouse adds a warehouse (supervisor/leader only)
+func CreateWarehouse(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode gudang wajib diisi"})
+		return
+	}
+	w := models.Warehouse{
+		Code:      strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:      strings.TrimSpace(req.Name),
+		Address:   req.Address,
+		IsActive:  req.IsActive == nil || *req.IsActive,
+		UpdatedBy: c.GetString("username"),
+	}
+	if w.Name == "" {
+		w.Name = w.Code
+	}
+	var count int64
+	database.DB.Model(&models.Warehouse{}).Where("code = ?", w.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kode gudang sudah digunakan"})
+		return
+	}
+	if err := database.DB.Create(&w).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// GORM skips a false is_active on insert in favour of the column default
+	if !w.IsActive {
+		database.DB.Model(&w).Update("is_active", false)
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+// UpdateWarehouse changes a warehouse's name, address or active flag
+// (supervisor/leader only). An inactive warehouse cannot be selected.
+func UpdateWarehouse(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var w models.Warehouse
+	if err := database.DB.First(&w, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Gudang tidak ditemukan"})
+		return
+	}
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code != "" && !strings.EqualFold(strings.TrimSpace(req.Code), w.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode gudang tidak bisa diubah"})
+		return
+	}
+
+	updates := map[string]interface{}{"address": req.Address, "updated_by": c.GetString("username")}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if err := database.DB.Model(&w).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	database.DB.First(&w, w.ID)
+	c.JSON(http.StatusOK, w)
+}
+
+// SetUserWarehouses replaces the warehouses a user is assigned to
+// (supervisor/leader only). Body: {"warehouses": ["WH-JC", ...]}
+func SetUserWarehouses(c *gin.Context) {
+	if !isSuperRole(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	var req struct {
+		Warehouses []string `json:"warehouses"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, code := range req.Warehouses {
+		if !warehouseExists(code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gudang tidak dikenal: " + code})
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return assignWarehouses(tx, user.ID, req.Warehouses)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Gudang user berhasil diubah", "warehouses": userWarehouses(user.ID)})
+}
+
+// assignWarehouses replaces a user's warehouse assignments
+func assignWarehouses(tx *gorm.DB, userID uint, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserWarehouse{}).Error; err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		if err := tx.Create(&models.UserWarehouse{UserID: userID, WarehouseCode: code}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userWarehouses returns the codes of the warehouses a user is assigned to
+func userWarehouses(userID uint) []string {
+	codes := []string{}
+	database.DB.Model(&models.UserWarehouse{}).Where("user_id = ?", userID).Order("warehouse_code").Pluck("warehouse_code", &codes)
+	return codes
+}
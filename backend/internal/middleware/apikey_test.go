@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiKeyRouter serves the key's warehouse on a few permission-guarded
+// routes, the way the protected API group does
+func apiKeyRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/", AuthRequired(), ActiveWarehouse())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("warehouse")) }
+	api.GET("/arrivals", RequirePermission("arrivals", ActionList), ok)
+	api.DELETE("/arrivals", RequirePermission("arrivals", ActionDelete), ok)
+	api.GET("/soh", RequirePermission("soh", ActionList), ok)
+	api.POST("/soh", RequirePermission("soh", ActionCreate), ok)
+	return r
+}
+
+// createAPIKey stores a key with the given scopes in warehouse WH-JC
+func createAPIKey(t *testing.T, db *gorm.DB, key string, scopes ...models.APIKeyScope) models.APIKey {
+	t.Helper()
+	apiKey := models.APIKey{Name: key, KeyHash: HashAPIKey(key), Warehouse: "WH-JC", Scopes: scopes}
+	if err := db.Create(&apiKey).Error; err != nil {
+		t.Fatal(err)
+	}
+	return apiKey
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	db := openTestDB(t, &models.APIKey{}, &models.APIKeyScope{}, &models.APIKeyUsage{},
+		&models.Warehouse{}, &models.Role{}, &models.Permission{})
+	warehouses := []models.Warehouse{{Code: "WH-JC"}, {Code: "WH-SBY"}, {Code: "WH-OLD"}}
+	if err := db.Create(&warehouses).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&warehouses[2]).Update("is_active", false)
+
+	reader := createAPIKey(t, db, "reader", models.APIKeyScope{Resource: "arrivals", Action: ActionList})
+	createAPIKey(t, db, "lister", models.APIKeyScope{Resource: Wildcard, Action: ActionList})
+	createAPIKey(t, db, "soh", models.APIKeyScope{Resource: "soh", Action: Wildcard})
+	surabaya := createAPIKey(t, db, "surabaya", models.APIKeyScope{Resource: Wildcard, Action: Wildcard})
+	db.Model(&surabaya).Update("warehouse", "WH-SBY")
+	old := createAPIKey(t, db, "old", models.APIKeyScope{Resource: Wildcard, Action: Wildcard})
+	db.Model(&old).Update("warehouse", "WH-OLD")
+	expired := createAPIKey(t, db, "expired", models.APIKeyScope{Resource: Wildcard, Action: Wildcard})
+	db.Model(&expired).Update("expires_at", time.Now().Add(-time.Minute))
+	later := createAPIKey(t, db, "later", models.APIKeyScope{Resource: Wildcard, Action: Wildcard})
+	db.Model(&later).Update("expires_at", time.Now().Add(time.Hour))
+	revoked := createAPIKey(t, db, "revoked", models.APIKeyScope{Resource: Wildcard, Action: Wildcard})
+	db.Model(&revoked).Update("revoked_at", time.Now())
+
+	r := apiKeyRouter()
+	tests := []struct {
+		key, method, path, warehouse string
+		want                         int
+		body                         string
+	}{
+		{"reader", http.MethodGet, "/arrivals", "", http.StatusOK, "WH-JC"},
+		{"reader", http.MethodDelete, "/arrivals", "", http.StatusForbidden, ""},
+		{"reader", http.MethodGet, "/soh", "", http.StatusForbidden, ""},
+		{"lister", http.MethodGet, "/soh", "", http.StatusOK, "WH-JC"},
+		{"lister", http.MethodPost, "/soh", "", http.StatusForbidden, ""},
+		{"soh", http.MethodPost, "/soh", "", http.StatusOK, "WH-JC"},
+		{"soh", http.MethodGet, "/arrivals", "", http.StatusForbidden, ""},
+
+		// A key works only in its own warehouse, and only while it is active
+		{"reader", http.MethodGet, "/arrivals", "WH-JC", http.StatusOK, "WH-JC"},
+		{"reader", http.MethodGet, "/arrivals", "WH-SBY", http.StatusForbidden, ""},
+		{"surabaya", http.MethodGet, "/arrivals", "", http.StatusOK, "WH-SBY"},
+		{"surabaya", http.MethodGet, "/arrivals", "WH-JC", http.StatusForbidden, ""},
+		{"old", http.MethodGet, "/arrivals", "", http.StatusForbidden, ""},
+
+		{"later", http.MethodGet, "/arrivals", "", http.StatusOK, "WH-JC"},
+		{"expired", http.MethodGet, "/arrivals", "", http.StatusUnauthorized, ""},
+		{"revoked", http.MethodGet, "/arrivals", "", http.StatusUnauthorized, ""},
+		{"unknown", http.MethodGet, "/arrivals", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set(APIKeyHeader, tt.key)
+		if tt.warehouse != "" {
+			req.Header.Set(WarehouseHeader, tt.warehouse)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %s %s with key %q: %d %s, want %d %s", tt.method, tt.path, tt.warehouse, tt.key, w.Code, w.Body, tt.want, tt.body)
+		}
+	}
+
+	// Every authenticated request is logged, refused ones included
+	var usage []models.APIKeyUsage
+	db.Where("api_key_id = ?", reader.ID).Order("id").Find(&usage)
+	if len(usage) != 5 {
+		t.Fatalf("%d usage rows for the reader key, want 5", len(usage))
+	}
+	if u := usage[1]; u.Method != http.MethodDelete || u.Path != "/arrivals" || u.Status != http.StatusForbidden {
+		t.Errorf("usage row %+v, want DELETE /arrivals 403", u)
+	}
+	db.First(&reader, reader.ID)
+	if reader.LastUsedAt == nil {
+		t.Error("last_used_at not set")
+	}
+	var refused int64
+	db.Model(&models.APIKeyUsage{}).Where("api_key_id IN ?", []uint{expired.ID, revoked.ID}).Count(&refused)
+	if refused != 0 {
+		t.Errorf("%d usage rows for refused keys, want 0", refused)
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	scopes := map[string]bool{"arrivals:list": true, "soh:*": true, "*:get": true}
+	tests := []struct {
+		resource, action string
+		want             bool
+	}{
+		{"arrivals", ActionList, true},
+		{"arrivals", ActionDelete, false},
+		{"soh", ActionSync, true},
+		{"locations", ActionGet, true},
+		{"locations", ActionList, false},
+	}
+	for _, tt := range tests {
+		if got := scopeAllows(scopes, tt.resource, tt.action); got != tt.want {
+			t.Errorf("scopeAllows(%q, %q) = %v, want %v", tt.resource, tt.action, got, tt.want)
+		}
+	}
+	if !scopeAllows(map[string]bool{"*:*": true}, "anything", ActionImport) {
+		t.Error("*:* does not allow everything")
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -25,21 +28,52 @@ func init() {
 	jwtSecret = []byte(secret)
 }
 
+// AccessTokenTTL is the lifetime of an access token; clients renew it with
+// a refresh token
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Version  int    `json:"ver"` // users.token_version when the token was issued
+	// Purpose is empty for access tokens. MFA tokens (PurposeMFA) only prove
+	// the password step of a login and are refused by AuthRequired.
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a JWT token for a user
-func GenerateToken(userID uint, username, role string) (string, error) {
+// PurposeMFA marks the token handed out between the password and TOTP steps
+const PurposeMFA = "mfa"
+
+// mfaTokenTTL is how long a user has to enter their TOTP code
+const mfaTokenTTL = 5 * time.Minute
+
+// GenerateToken creates a short-lived JWT access token for a user
+func GenerateToken(userID uint, username, role string, version int) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		Version:  version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// GenerateMFAToken creates the short-lived token that carries a user from
+// the password step of a login to the TOTP step
+func GenerateMFAToken(userID uint, version int) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Version: version,
+		Purpose: PurposeMFA,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -47,9 +81,29 @@ func GenerateToken(userID uint, username, role string) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
-// AuthRequired validates JWT token
+// ParseMFAToken validates an MFA token and returns its claims
+func ParseMFAToken(tokenString string) (*Claims, bool) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != PurposeMFA {
+		return nil, false
+	}
+	return claims, true
+}
+
+// AuthRequired validates JWT token and checks it against the user's current
+// token version, so deleting a user or changing their role or password
+// revokes their tokens immediately. The role is taken from the database.
+// Requests with an X-API-Key header are authenticated by the key instead.
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -63,15 +117,56 @@ func AuthRequired() gin.HandlerFunc {
 			return jwtSecret, nil
 		})
 
-		if err != nil || !token.Valid {
+		if err != nil || !token.Valid || claims.Purpose != "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
 		}
 
+		var user models.User
+		if err := database.DB.Select("id", "username", "role", "token_version", "must_change_password", "totp_enabled").
+			First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.Version {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please log in again"})
+			return
+		}
+
 		// Store user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("must_change_password", user.MustChangePassword)
+		c.Set("mfa_setup_required", !user.TOTPEnabled && RoleRequires2FA(user.Role))
+		c.Next()
+	}
+}
+
+// PasswordChanged refuses requests from accounts that must change their
+// password first. Routes registered on the group before this middleware
+// (e.g. the password change itself) stay reachable.
+func PasswordChanged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("must_change_password") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":                "Password harus diganti sebelum melanjutkan",
+				"must_change_password": true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TwoFactorEnrolled refuses requests from accounts whose role requires 2FA
+// but who have not enrolled yet. Routes registered on the group before this
+// middleware (e.g. 2FA enrollment) stay reachable.
+func TwoFactorEnrolled() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mfa_setup_required") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":              "Two-factor authentication wajib diaktifkan untuk role ini",
+				"mfa_setup_required": true,
+			})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"testing"
+
+	"warehouse-report-monitoring/internal/database"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB installs a fresh in-memory database as database.DB, migrates
+// the given models into it and empties the role cache
+func openTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	InvalidatePermissions()
+	return db
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Resource actions checked by RequirePermission
+const (
+	ActionList   = "list"
+	ActionGet    = "get"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionSync   = "sync"
+	ActionImport = "import"
+)
+
+// Actions lists every resource action, in display order
+var Actions = []string{ActionList, ActionGet, ActionCreate, ActionUpdate, ActionDelete, ActionSync, ActionImport}
+
+// Wildcard matches any resource or any action in a permission
+const Wildcard = "*"
+
+// policyTTL bounds how stale the cached roles can get if the table is
+// changed outside the API
+const policyTTL = time.Minute
+
+type rolePolicy struct {
+	super      bool
+	require2FA bool
+	perms      map[string]bool // "resource:action"
+}
+
+var (
+	policyMu       sync.RWMutex
+	policies       map[string]*rolePolicy
+	policyLoadedAt time.Time
+)
+
+// InvalidatePermissions drops the cached roles so the next check reloads them
+func InvalidatePermissions() {
+	policyMu.Lock()
+	policies = nil
+	policyMu.Unlock()
+}
+
+// loadPolicies returns the cached roles, reloading them from the database
+// when the cache is empty or expired
+func loadPolicies() map[string]*rolePolicy {
+	policyMu.RLock()
+	if policies != nil && time.Since(policyLoadedAt) < policyTTL {
+		p := policies
+		policyMu.RUnlock()
+		return p
+	}
+	policyMu.RUnlock()
+
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		log.Printf("[AUTHZ] Failed to load roles: %v", err)
+		return map[string]*rolePolicy{}
+	}
+	loaded := make(map[string]*rolePolicy, len(roles))
+	for _, r := range roles {
+		p := &rolePolicy{super: r.IsSuper, require2FA: r.Require2FA, perms: make(map[string]bool, len(r.Permissions))}
+		for _, perm := range r.Permissions {
+			p.perms[perm.Resource+":"+perm.Action] = true
+		}
+		loaded[r.Name] = p
+	}
+
+	policyMu.Lock()
+	policies = loaded
+	policyLoadedAt = time.Now()
+	policyMu.Unlock()
+	return loaded
+}
+
+// IsSuperRole reports whether the role has full access
+func IsSuperRole(role string) bool {
+	p, ok := loadPolicies()[role]
+	return ok && p.super
+}
+
+// RoleRequires2FA reports whether users of the role must enroll in TOTP 2FA
+func RoleRequires2FA(role string) bool {
+	p, ok := loadPolicies()[role]
+	return ok && p.require2FA
+}
+
+// HasPermission reports whether the role may perform action on resource
+func HasPermission(role, resource, action string) bool {
+	p, ok := loadPolicies()[role]
+	if !ok {
+		return false
+	}
+	return p.super ||
+		p.perms[resource+":"+action] ||
+		p.perms[resource+":"+Wildcard] ||
+		p.perms[Wildcard+":"+action] ||
+		p.perms[Wildcard+":"+Wildcard]
+}
+
+// PermissionsOf returns the resource actions a role may perform, keyed by
+// resource ("*" for grants on every resource). Super roles get {"*": ["*"]}.
+func PermissionsOf(role string) map[string][]string {
+	out := map[string][]string{}
+	p, ok := loadPolicies()[role]
+	if !ok {
+		return out
+	}
+	if p.super {
+		out[Wildcard] = []string{Wildcard}
+		return out
+	}
+	for key := range p.perms {
+		resource, action, _ := strings.Cut(key, ":")
+		out[resource] = append(out[resource], action)
+	}
+	for resource := range out {
+		sort.Strings(out[resource])
+	}
+	return out
+}
+
+// RequirePermission allows the request only if the caller's role (or, for
+// API keys, the key's scopes) may perform action on resource. Must run after
+// AuthRequired.
+func RequirePermission(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := false
+		if scopes, ok := c.Get("api_key_scopes"); ok {
+			allowed = scopeAllows(scopes.(map[string]bool), resource, action)
+		} else {
+			allowed = HasPermission(c.GetString("role"), resource, action)
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":    "Akses ditolak",
+				"resource": resource,
+				"action":   action,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"warehouse-report-monitoring/internal/database"
+	"warehouse-report-monitoring/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seedRoles creates the roles used by the permission tests
+func seedRoles(t *testing.T) {
+	t.Helper()
+	db := openTestDB(t, &models.Role{}, &models.Permission{})
+	roles := []models.Role{
+		{Name: "supervisor", IsSuper: true},
+		{Name: "staff", Permissions: []models.Permission{
+			{Resource: "arrivals", Action: ActionList},
+			{Resource: "arrivals", Action: ActionGet},
+			{Resource: "locations", Action: Wildcard},
+		}},
+		{Name: "viewer", Require2FA: true, Permissions: []models.Permission{{Resource: Wildcard, Action: ActionList}}},
+		{Name: "admin", Permissions: []models.Permission{{Resource: Wildcard, Action: Wildcard}}},
+		{Name: "nobody"},
+	}
+	if err := db.Create(&roles).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	seedRoles(t)
+	tests := []struct {
+		role, resource, action string
+		want                   bool
+	}{
+		{"supervisor", "arrivals", ActionDelete, true},
+		{"supervisor", "anything", ActionSync, true},
+		{"staff", "arrivals", ActionList, true},
+		{"staff", "arrivals", ActionGet, true},
+		{"staff", "arrivals", ActionUpdate, false},
+		{"staff", "locations", ActionDelete, true},
+		{"staff", "locations", ActionSync, true},
+		{"staff", "soh", ActionList, false},
+		{"viewer", "soh", ActionList, true},
+		{"viewer", "arrivals", ActionList, true},
+		{"viewer", "arrivals", ActionGet, false},
+		{"admin", "soh", ActionImport, true},
+		{"nobody", "arrivals", ActionList, false},
+		{"missing", "arrivals", ActionList, false},
+		{"", "arrivals", ActionList, false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.resource, tt.action); got != tt.want {
+			t.Errorf("HasPermission(%q, %q, %q) = %v, want %v", tt.role, tt.resource, tt.action, got, tt.want)
+		}
+	}
+
+	if !IsSuperRole("supervisor") || IsSuperRole("admin") || IsSuperRole("missing") {
+		t.Error("only supervisor is a super role")
+	}
+	if !RoleRequires2FA("viewer") || RoleRequires2FA("staff") {
+		t.Error("only viewer requires 2FA")
+	}
+	if got, want := PermissionsOf("supervisor"), map[string][]string{"*": {"*"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PermissionsOf(supervisor) = %v, want %v", got, want)
+	}
+	want := map[string][]string{"arrivals": {ActionGet, ActionList}, "locations": {Wildcard}}
+	if got := PermissionsOf("staff"); !reflect.DeepEqual(got, want) {
+		t.Errorf("PermissionsOf(staff) = %v, want %v", got, want)
+	}
+	if got := PermissionsOf("missing"); len(got) != 0 {
+		t.Errorf("PermissionsOf(missing) = %v, want none", got)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	seedRoles(t)
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		keys   gin.H
+		action string
+		status int
+	}{
+		{"role allowed", gin.H{"role": "staff"}, ActionList, http.StatusOK},
+		{"role denied", gin.H{"role": "staff"}, ActionDelete, http.StatusForbidden},
+		{"super role", gin.H{"role": "supervisor"}, ActionDelete, http.StatusOK},
+		{"no role", gin.H{}, ActionList, http.StatusForbidden},
+		{"key scope allowed", gin.H{"role": "", "api_key_scopes": map[string]bool{"arrivals:list": true}}, ActionList, http.StatusOK},
+		{"key scope denied", gin.H{"role": "", "api_key_scopes": map[string]bool{"arrivals:list": true}}, ActionGet, http.StatusForbidden},
+		{"key wildcard scope", gin.H{"role": "", "api_key_scopes": map[string]bool{"*:get": true}}, ActionGet, http.StatusOK},
+		// A key is limited to its scopes whatever role the request carries
+		{"key ignores role", gin.H{"role": "supervisor", "api_key_scopes": map[string]bool{}}, ActionList, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				for k, v := range tt.keys {
+					c.Set(k, v)
+				}
+			}, RequirePermission("arrivals", tt.action), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestInvalidatePermissions(t *testing.T) {
+	seedRoles(t)
+	if HasPermission("staff", "soh", ActionList) {
+		t.Fatal("staff may not list soh yet")
+	}
+	var staff models.Role
+	if err := database.DB.Where("name = ?", "staff").First(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Create(&models.Permission{RoleID: staff.ID, Resource: "soh", Action: ActionList}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if HasPermission("staff", "soh", ActionList) {
+		t.Error("the cached roles were reloaded before being invalidated")
+	}
+	InvalidatePermissions()
+	if !HasPermission("staff", "soh", ActionList) {
+		t.Error("the new permission is not seen after InvalidatePermissions")
+	}
+}
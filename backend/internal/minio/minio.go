@@ -2,6 +2,7 @@ package minio
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -98,6 +99,33 @@ func DeleteVideo(objectKey string) error {
 	return Client.RemoveObject(ctx, BucketName, objectKey, minio.RemoveObjectOptions{})
 }
 
+// errNotConnected is returned when storage was not initialised
+var errNotConnected = errors.New("file storage is not available")
+
+// UploadFile uploads a document (e.g. a case attachment) to MinIO
+func UploadFile(objectKey string, reader io.Reader, size int64, contentType string) error {
+	if Client == nil {
+		return errNotConnected
+	}
+	return UploadVideo(objectKey, reader, size, contentType)
+}
+
+// GetFileURL generates a presigned URL to download a document (24h expiry)
+func GetFileURL(objectKey string, contentDisposition string) (string, error) {
+	if Client == nil {
+		return "", errNotConnected
+	}
+	return GetVideoURL(objectKey, contentDisposition)
+}
+
+// DeleteFile removes a document from MinIO
+func DeleteFile(objectKey string) error {
+	if Client == nil {
+		return errNotConnected
+	}
+	return DeleteVideo(objectKey)
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -29,6 +29,7 @@ type Arrival struct {
 	DatePublishDO        FlexDate       `gorm:"column:date_publish_do;type:text;index" json:"date_publish_do"`
 	RemarksPublishDO     string         `gorm:"column:remarks_publish_do" json:"remarks_publish_do"`
 	Urgensi              string         `gorm:"column:urgensi" json:"urgensi"`
+	Warehouse            string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy            string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt            time.Time      `json:"created_at"`
 	UpdatedAt            time.Time      `json:"updated_at"`
@@ -45,6 +46,7 @@ type Transaction struct {
 	OperateType     string         `gorm:"column:operate_type" json:"operate_type"`
 	Qty             int            `gorm:"column:qty" json:"qty" binding:"min=0"`
 	Operator        string         `gorm:"column:operator" json:"operator"`
+	Warehouse       string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy       string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -61,6 +63,7 @@ type ReturnTransaction struct {
 	OperateType     string         `gorm:"column:operate_type" json:"operate_type"`
 	Qty             int            `gorm:"column:qty" json:"qty" binding:"min=0"`
 	Operator        string         `gorm:"column:operator" json:"operator"`
+	Warehouse       string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy       string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -84,6 +87,7 @@ type ReturnReceive struct {
 	Operator     string         `gorm:"column:operator" json:"operator"`
 	ReturnReason string         `gorm:"column:return_reason" json:"return_reason"`
 	ReasonGroup  string         `gorm:"column:reason_group" json:"reason_group"`
+	Warehouse    string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy    string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -103,6 +107,7 @@ type RejectReturn struct {
 	WhNote    string         `gorm:"column:wh_note" json:"wh_note"`
 	CsName    string         `gorm:"column:cs_name" json:"cs_name"`
 	Status    string         `gorm:"column:status" json:"status"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -116,6 +121,7 @@ type OrderPerBrand struct {
 	Brand      string         `gorm:"column:brand" json:"brand"`
 	OrderCount int            `gorm:"column:order_count" json:"order_count"`
 	Qty        int            `gorm:"column:qty" json:"qty"`
+	Warehouse  string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy  string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
@@ -135,6 +141,7 @@ type Vas struct {
 	Operator  string         `gorm:"column:operator" json:"operator"`
 	ItemType  string         `gorm:"column:item_type;default:Barang Jual" json:"item_type"`
 	Status    string         `gorm:"column:status;default:completed" json:"status"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -159,6 +166,7 @@ type Dcc struct {
 	ReconcileSysQty   *int           `gorm:"column:reconcile_sys_qty" json:"reconcile_sys_qty"`
 	ReconcilePhyQty   *int           `gorm:"column:reconcile_phy_qty" json:"reconcile_phy_qty"`
 	ReconcileVariance *int           `gorm:"column:reconcile_variance" json:"reconcile_variance"`
+	Warehouse         string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy         string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
@@ -178,6 +186,7 @@ type Damage struct {
 	Operator     string         `gorm:"column:operator" json:"operator"`
 	Owner        string         `gorm:"column:owner" json:"owner"`
 	QcBy         string         `gorm:"column:qc_by" json:"qc_by"`
+	Warehouse    string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy    string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -204,6 +213,7 @@ type Soh struct {
 	BatchNo          string         `gorm:"column:batch_no" json:"batch_no"`
 	DamageType       string         `gorm:"column:damage_type" json:"damage_type"`
 	UpdateDate       FlexDate       `gorm:"column:update_date;type:text;index" json:"update_date"`
+	Warehouse        string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy        string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
@@ -225,6 +235,7 @@ type QcReturn struct {
 	ToLoc       string         `gorm:"column:to_loc" json:"to_loc"`
 	Status      string         `gorm:"column:status" json:"status"`
 	Operator    string         `gorm:"column:operator" json:"operator"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -234,12 +245,13 @@ type QcReturn struct {
 // Location represents master location data
 type Location struct {
 	ID               uint           `gorm:"primaryKey" json:"id"`
-	Location         string         `gorm:"column:location;uniqueIndex" json:"location" binding:"required"`
+	Location         string         `gorm:"column:location;uniqueIndex:idx_locations_warehouse_location,priority:2" json:"location" binding:"required"`
 	LocationCategory string         `gorm:"column:location_category" json:"location_category"`
 	Zone             string         `gorm:"column:zone" json:"zone"`
 	LocationType     string         `gorm:"column:location_type" json:"location_type"`
 	LocationGroup    string         `gorm:"column:location_group" json:"location_group"`
 	DamageType       string         `gorm:"column:damage_type" json:"damage_type"`
+	Warehouse        string         `gorm:"column:warehouse;default:WH-JC;uniqueIndex:idx_locations_warehouse_location,priority:1" json:"warehouse"`
 	UpdatedBy        string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
@@ -255,6 +267,7 @@ type MasterItem struct {
 	SkuCategory string         `gorm:"column:sku_category" json:"sku_category"`
 	ItemClass   string         `gorm:"column:item_class" json:"item_class"`
 	Owner       string         `gorm:"column:owner" json:"owner"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -273,6 +286,7 @@ type Attendance struct {
 	Status         string         `gorm:"column:status" json:"status"`
 	ApprovalStatus string         `gorm:"column:approval_status;default:''" json:"approval_status"`
 	ApprovalNote   string         `gorm:"column:approval_note" json:"approval_note"`
+	Warehouse      string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy      string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
@@ -286,6 +300,7 @@ type Employee struct {
 	Name      string         `gorm:"column:name" json:"name" binding:"required"`
 	Status    string         `gorm:"column:status" json:"status"`
 	IsActive  string         `gorm:"column:is_active;default:Active" json:"is_active"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -302,6 +317,7 @@ type ProjectProductivity struct {
 	Qty       int            `gorm:"column:qty" json:"qty" binding:"min=0"`
 	Duration  string         `gorm:"column:duration" json:"duration"`
 	Status    string         `gorm:"column:status" json:"status"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -315,18 +331,69 @@ type Unloading struct {
 	Brand         string         `gorm:"column:brand" json:"brand" binding:"required"`
 	VehicleType   string         `gorm:"column:vehicle_type" json:"vehicle_type"`
 	TotalVehicles int            `gorm:"column:total_vehicles" json:"total_vehicles" binding:"min=0"`
+	Warehouse     string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy     string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// UnloadingEvent is the unloading of one vehicle, tied to its arrival by
+// receipt_no. The daily unloading figures are derived from these events.
+type UnloadingEvent struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Date        FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
+	ReceiptNo   string         `gorm:"column:receipt_no;index" json:"receipt_no"`
+	Brand       string         `gorm:"column:brand" json:"brand"`
+	PlateNo     string         `gorm:"column:plate_no;index" json:"plate_no" binding:"required"`
+	VehicleType string         `gorm:"column:vehicle_type" json:"vehicle_type"`
+	DockCode    string         `gorm:"column:dock_code" json:"dock_code"`
+	StartTime   FlexDate       `gorm:"column:start_time;type:text" json:"start_time"`
+	FinishTime  FlexDate       `gorm:"column:finish_time;type:text" json:"finish_time"`
+	Crew        string         `gorm:"column:crew" json:"crew"`
+	CrewCount   int            `gorm:"column:crew_count" json:"crew_count" binding:"min=0"`
+	Note        string         `gorm:"column:note" json:"note"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
 // User represents auth user for JWT login
 type User struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Username string `gorm:"uniqueIndex;not null" json:"username"`
-	Password string `gorm:"not null" json:"-"`
-	Role     string `gorm:"not null" json:"role"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"uniqueIndex;not null" json:"username"`
+	Password     string `gorm:"not null" json:"-"`
+	Role         string `gorm:"not null" json:"role"`
+	TokenVersion int    `gorm:"column:token_version;not null;default:0" json:"-"` // bumped to revoke all tokens
+
+	FailedAttempts     int        `gorm:"column:failed_attempts;not null;default:0" json:"-"`
+	LockedUntil        *time.Time `gorm:"column:locked_until" json:"-"`
+	MustChangePassword bool       `gorm:"column:must_change_password;not null;default:false" json:"-"`
+	PasswordChangedAt  *time.Time `gorm:"column:password_changed_at" json:"-"`
+
+	TOTPSecret   string `gorm:"column:totp_secret" json:"-"` // base32, set at enrollment
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;not null;default:false" json:"-"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step;not null;default:0" json:"-"` // blocks code replay
+}
+
+// RecoveryCode is a single-use 2FA backup code. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"column:user_id;index;not null" json:"user_id"`
+	Hash      string     `gorm:"column:hash;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordHistory keeps the hashes of a user's previous passwords so they
+// cannot be reused
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"column:user_id;index;not null" json:"user_id"`
+	Hash      string    `gorm:"column:hash;not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Schedule represents manpower weekly schedule
@@ -338,6 +405,7 @@ type Schedule struct {
 	Jobdesc   string         `gorm:"column:jobdesc" json:"jobdesc"`
 	ClockIn   string         `gorm:"column:clock_in" json:"clock_in"`
 	ClockOut  string         `gorm:"column:clock_out" json:"clock_out"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -355,7 +423,7 @@ type BeritaAcara struct {
 	Dari      string         `gorm:"column:dari" json:"dari"`
 	Items     string         `gorm:"column:items;type:text" json:"items"`
 	Notes     string         `gorm:"column:notes;type:text" json:"notes"`
-	Warehouse string         `gorm:"column:warehouse;default:WH-JC" json:"warehouse"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	PicName   string         `gorm:"column:pic_name" json:"pic_name"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -363,6 +431,33 @@ type BeritaAcara struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// DocNumberFormat sets how Berita Acara documents of one type are numbered
+// in a warehouse. Placeholders: {YYYY}, {YY}, {MM}, {ROMAN} (month in Roman
+// numerals), {WH} and {SEQ} or {SEQ:n} (the sequence, zero-padded to n digits).
+type DocNumberFormat struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	DocType   string         `gorm:"column:doc_type;index" json:"doc_type" binding:"required"`
+	Format    string         `gorm:"column:format" json:"format" binding:"required"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// DocNumberSequence is the last sequence number handed out to Berita Acara
+// documents numbered with a format in a warehouse and month (Period,
+// YYYY-MM). Document types sharing a format share the sequence.
+type DocNumberSequence struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Format    string    `gorm:"column:format;uniqueIndex:idx_doc_number_sequences_series" json:"format"`
+	Warehouse string    `gorm:"column:warehouse;uniqueIndex:idx_doc_number_sequences_series" json:"warehouse"`
+	Period    string    `gorm:"column:period;uniqueIndex:idx_doc_number_sequences_series" json:"period"`
+	LastSeq   int       `gorm:"column:last_seq;not null;default:0" json:"last_seq"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // StockOpname represents stock opname records (same structure as Dcc)
 type StockOpname struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
@@ -378,6 +473,7 @@ type StockOpname struct {
 	PhyQty      int            `gorm:"column:phy_qty" json:"phy_qty"`
 	Variance    int            `gorm:"column:variance" json:"variance"`
 	Operator    string         `gorm:"column:operator" json:"operator"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -390,6 +486,7 @@ type AdditionalMp struct {
 	Date         FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
 	AdditionalMp int            `gorm:"column:additional_mp" json:"additional_mp"`
 	Tasks        string         `gorm:"column:tasks;type:text" json:"tasks"`
+	Warehouse    string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy    string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -401,31 +498,90 @@ type InboundRejection struct {
 	ID           uint           `gorm:"primaryKey" json:"id"`
 	Date         FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
 	Brand        string         `gorm:"column:brand" json:"brand"`
+	ReceiptNo    string         `gorm:"column:receipt_no;index" json:"receipt_no"`
 	Sku          string         `gorm:"column:sku" json:"sku"`
 	SerialNumber string         `gorm:"column:serial_number" json:"serial_number"`
 	Catatan      string         `gorm:"column:catatan" json:"catatan"`
 	Qty          int            `gorm:"column:qty" json:"qty" binding:"min=0"`
 	SourceDocNo  string         `gorm:"column:source_doc_no" json:"source_doc_no"` // BA doc_number ref
+	Warehouse    string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy    string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// InboundCase represents inbound case tracking data
+// InboundCase represents inbound case tracking data. Case holds the case
+// type (see InboundCaseType). Status, the resolve/close times and the
+// escalation fields are only changed by the case workflow endpoints.
 type InboundCase struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	Date       FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
-	ReceiptNo  string         `gorm:"column:receipt_no" json:"receipt_no"`
-	Sku        string         `gorm:"column:sku" json:"sku"`
-	Brand      string         `gorm:"column:brand" json:"brand"`
-	Case       string         `gorm:"column:case" json:"case"`
-	Operator   string         `gorm:"column:operator" json:"operator"`
-	Qty        int            `gorm:"column:qty" json:"qty" binding:"min=0"`
-	Keterangan string         `gorm:"column:keterangan" json:"keterangan"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Date            FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
+	ReceiptNo       string         `gorm:"column:receipt_no" json:"receipt_no"`
+	Sku             string         `gorm:"column:sku" json:"sku"`
+	Brand           string         `gorm:"column:brand" json:"brand"`
+	Case            string         `gorm:"column:case" json:"case"`
+	Operator        string         `gorm:"column:operator" json:"operator"`
+	Qty             int            `gorm:"column:qty" json:"qty" binding:"min=0"`
+	Keterangan      string         `gorm:"column:keterangan" json:"keterangan"`
+	Status          string         `gorm:"column:status;default:Open;index" json:"status"`
+	Assignee        string         `gorm:"column:assignee;index" json:"assignee"`
+	DueDate         FlexDate       `gorm:"column:due_date;type:text;index" json:"due_date"`
+	Resolution      string         `gorm:"column:resolution" json:"resolution"`
+	ResolvedAt      FlexDate       `gorm:"column:resolved_at;type:text" json:"resolved_at"`
+	ClosedAt        FlexDate       `gorm:"column:closed_at;type:text" json:"closed_at"`
+	EscalationLevel int            `gorm:"column:escalation_level;default:0" json:"escalation_level"`
+	EscalatedAt     FlexDate       `gorm:"column:escalated_at;type:text" json:"escalated_at"`
+	Warehouse       string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy       string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// InboundCaseType is a kind of inbound case. A case of this type without a
+// due date is due DueHours after its date, and escalates one level more for
+// every EscalateEveryHours it stays overdue.
+type InboundCaseType struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Code               string         `gorm:"column:code;index" json:"code" binding:"required"`
+	Name               string         `gorm:"column:name" json:"name"`
+	DueHours           int            `gorm:"column:due_hours;default:48" json:"due_hours" binding:"min=0"`
+	EscalateEveryHours int            `gorm:"column:escalate_every_hours;default:24" json:"escalate_every_hours" binding:"min=0"`
+	IsActive           bool           `gorm:"column:is_active;not null;default:true" json:"is_active"`
+	Warehouse          string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy          string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// InboundCaseComment is an entry in the timeline of a case: a comment by a
+// user, or a note of a status change, assignment or escalation
+type InboundCaseComment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CaseID    uint           `gorm:"column:case_id;index" json:"case_id"`
+	Kind      string         `gorm:"column:kind;default:comment" json:"kind"`
+	Body      string         `gorm:"column:body;type:text" json:"body"`
+	Author    string         `gorm:"column:author" json:"author"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// InboundCaseAttachment is a file (photo, supplier document) attached to a
+// case, stored in MinIO under ObjectKey
+type InboundCaseAttachment struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	CaseID      uint           `gorm:"column:case_id;index" json:"case_id"`
+	FileName    string         `gorm:"column:file_name" json:"file_name"`
+	ObjectKey   string         `gorm:"column:object_key" json:"-"`
+	ContentType string         `gorm:"column:content_type" json:"content_type"`
+	Size        int64          `gorm:"column:size" json:"size"`
+	UploadedBy  string         `gorm:"column:uploaded_by" json:"uploaded_by"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	CreatedAt   time.Time      `json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // Workflow represents a warehouse process workflow/flowchart
@@ -433,6 +589,7 @@ type Workflow struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
 	Name      string         `gorm:"column:name" json:"name" binding:"required"`
 	Steps     string         `gorm:"column:steps;type:text" json:"steps"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -447,6 +604,7 @@ type InventoryProject struct {
 	Task        string         `gorm:"column:task" json:"task"`
 	TargetDate  FlexDate       `gorm:"column:target_date;type:text;index" json:"target_date"`
 	Status      string         `gorm:"column:status;default:Open" json:"status"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -456,14 +614,124 @@ type InventoryProject struct {
 // HeatmapOverride represents a manual location override in the storage heatmap
 type HeatmapOverride struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
-	Location  string         `gorm:"column:location;uniqueIndex" json:"location" binding:"required"`
+	Location  string         `gorm:"column:location;uniqueIndex:idx_heatmap_overrides_warehouse_location,priority:2" json:"location" binding:"required"`
 	Note      string         `gorm:"column:note" json:"note"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;uniqueIndex:idx_heatmap_overrides_warehouse_location,priority:1" json:"warehouse"`
 	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// InboundSla sets the dock-to-stock lead time targets, in minutes, for the
+// arrivals of a brand and urgency. An empty brand or urgency matches any, and
+// a zero target leaves that stage untracked.
+type InboundSla struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Brand              string         `gorm:"column:brand;index" json:"brand"`
+	Urgensi            string         `gorm:"column:urgensi" json:"urgensi"`
+	UnloadingMinutes   int            `gorm:"column:unloading_minutes" json:"unloading_minutes" binding:"min=0"`
+	ReceiveMinutes     int            `gorm:"column:receive_minutes" json:"receive_minutes" binding:"min=0"`
+	PutawayMinutes     int            `gorm:"column:putaway_minutes" json:"putaway_minutes" binding:"min=0"`
+	DockToStockMinutes int            `gorm:"column:dock_to_stock_minutes" json:"dock_to_stock_minutes" binding:"min=0"`
+	AtRiskPercent      int            `gorm:"column:at_risk_percent;default:80" json:"at_risk_percent" binding:"min=0,max=100"`
+	Note               string         `gorm:"column:note" json:"note"`
+	Warehouse          string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy          string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Dock is an unloading dock that inbound appointments are booked on
+type Dock struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Code      string         `gorm:"column:code;index" json:"code" binding:"required"`
+	Name      string         `gorm:"column:name" json:"name"`
+	IsActive  bool           `gorm:"column:is_active;not null;default:true" json:"is_active"`
+	Note      string         `gorm:"column:note" json:"note"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// DockSlot is a daily time slot of a dock (HH:MM, end exclusive) and the
+// number of appointments it takes
+type DockSlot struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	DockCode  string         `gorm:"column:dock_code;index" json:"dock_code" binding:"required"`
+	StartTime string         `gorm:"column:start_time" json:"start_time" binding:"required"`
+	EndTime   string         `gorm:"column:end_time" json:"end_time" binding:"required"`
+	Capacity  int            `gorm:"column:capacity;default:1" json:"capacity" binding:"min=1"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// DockAppointment is a booking of a dock slot on a date for the delivery of
+// a brand/supplier. PoNo holds one or more PO numbers, comma-separated.
+// Check-in links the appointment to its Arrival.
+type DockAppointment struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Date        FlexDate       `gorm:"column:date;type:text;index" json:"date" binding:"required"`
+	SlotID      uint           `gorm:"column:slot_id;index" json:"slot_id" binding:"required"`
+	DockCode    string         `gorm:"column:dock_code;index" json:"dock_code"`
+	SlotStart   string         `gorm:"column:slot_start" json:"slot_start"`
+	SlotEnd     string         `gorm:"column:slot_end" json:"slot_end"`
+	Brand       string         `gorm:"column:brand" json:"brand" binding:"required"`
+	Supplier    string         `gorm:"column:supplier" json:"supplier"`
+	PoNo        string         `gorm:"column:po_no" json:"po_no"`
+	VehicleType string         `gorm:"column:vehicle_type" json:"vehicle_type"`
+	Status      string         `gorm:"column:status;default:Booked;index" json:"status"`
+	CheckInTime FlexDate       `gorm:"column:check_in_time;type:text" json:"check_in_time"`
+	ArrivalID   *uint          `gorm:"column:arrival_id;index" json:"arrival_id"`
+	Note        string         `gorm:"column:note" json:"note"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Asn is an advance shipping notice from a supplier: the SKUs it announced
+// for a delivery. It is attached to the Arrival of that delivery, whose
+// receipt_no the receiving transactions are matched on.
+type Asn struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	AsnNo     string         `gorm:"column:asn_no;index" json:"asn_no" binding:"required"`
+	Date      FlexDate       `gorm:"column:date;type:text;index" json:"date"`
+	Supplier  string         `gorm:"column:supplier" json:"supplier"`
+	Brand     string         `gorm:"column:brand" json:"brand"`
+	PoNo      string         `gorm:"column:po_no" json:"po_no"`
+	ReceiptNo string         `gorm:"column:receipt_no;index" json:"receipt_no"`
+	ArrivalID *uint          `gorm:"column:arrival_id;index" json:"arrival_id"`
+	Note      string         `gorm:"column:note" json:"note"`
+	Warehouse string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AsnLine is one SKU announced on an ASN
+type AsnLine struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	AsnID       uint           `gorm:"column:asn_id;index" json:"asn_id" binding:"required"`
+	Sku         string         `gorm:"column:sku;index" json:"sku" binding:"required"`
+	ExpectedQty int            `gorm:"column:expected_qty" json:"expected_qty" binding:"min=0"`
+	BatchNo     string         `gorm:"column:batch_no" json:"batch_no"`
+	ExpiryDate  FlexDate       `gorm:"column:expiry_date;type:text" json:"expiry_date"`
+	Warehouse   string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
+	UpdatedBy   string         `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
 // ReturnUnboxing represents a return order unboxing session with video recording
 type ReturnUnboxing struct {
 	ID         uint           `gorm:"primaryKey" json:"id"`
@@ -475,8 +743,127 @@ type ReturnUnboxing struct {
 	VideoKey   string         `gorm:"column:video_key" json:"video_key"`
 	Status     string         `gorm:"column:status;default:completed" json:"status"`
 	Notes      string         `gorm:"column:notes" json:"notes"`
+	Warehouse  string         `gorm:"column:warehouse;default:WH-JC;index" json:"warehouse"`
 	UpdatedBy  string         `gorm:"column:updated_by" json:"updated_by"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// SyncSnapshot stores the rows replaced by a resource Sync so they can be restored
+type SyncSnapshot struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Resource  string    `gorm:"column:resource;index" json:"resource"`
+	Warehouse string    `gorm:"column:warehouse;index" json:"warehouse"`
+	RowCount  int       `gorm:"column:row_count" json:"row_count"`
+	Data      string    `gorm:"column:data;type:text" json:"-"`
+	CreatedBy string    `gorm:"column:created_by" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditLog records one change made to a resource record through the API.
+// Changes holds a JSON object of field -> {"old", "new"}.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Resource  string    `gorm:"column:resource;index:idx_audit_record" json:"resource"`
+	RecordID  uint      `gorm:"column:record_id;index:idx_audit_record" json:"record_id"`
+	Warehouse string    `gorm:"column:warehouse;index" json:"warehouse"`
+	Action    string    `gorm:"column:action;index" json:"action"`
+	Actor     string    `gorm:"column:actor;index" json:"actor"`
+	Changes   string    `gorm:"column:changes;type:text" json:"-"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Role is a user role. Super roles have every permission and manage users,
+// roles and the audit log; other roles get only the permissions listed.
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"column:description" json:"description"`
+	IsSuper     bool         `gorm:"column:is_super" json:"is_super"`
+	Require2FA  bool         `gorm:"column:require_2fa" json:"require_2fa"`
+	Permissions []Permission `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Permission grants a role one action on one resource. "*" matches any
+// resource or any action.
+type Permission struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	RoleID   uint   `gorm:"uniqueIndex:idx_role_permission;not null" json:"-"`
+	Resource string `gorm:"uniqueIndex:idx_role_permission;not null" json:"resource"`
+	Action   string `gorm:"uniqueIndex:idx_role_permission;not null" json:"action"`
+}
+
+// RefreshToken is a server-side refresh token. Only the SHA-256 hash of the
+// token is stored. Tokens rotate on every use; all tokens descending from
+// one login share a Family so a reused (stolen) token revokes the family.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"column:user_id;index;not null" json:"user_id"`
+	TokenHash  string     `gorm:"column:token_hash;uniqueIndex;not null" json:"-"`
+	Family     string     `gorm:"column:family;index;not null" json:"-"`
+	UserAgent  string     `gorm:"column:user_agent" json:"user_agent"`
+	IP         string     `gorm:"column:ip" json:"ip"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	ReplacedBy uint       `gorm:"column:replaced_by" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKey lets a script or integration call the API without a user account.
+// Only the SHA-256 hash of the key is stored; Prefix identifies it in lists.
+type APIKey struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	Name       string        `gorm:"column:name;not null" json:"name"`
+	Prefix     string        `gorm:"column:prefix;index" json:"prefix"`
+	KeyHash    string        `gorm:"column:key_hash;uniqueIndex;not null" json:"-"`
+	Scopes     []APIKeyScope `gorm:"foreignKey:APIKeyID;constraint:OnDelete:CASCADE" json:"scopes"`
+	Warehouse  string        `gorm:"column:warehouse;not null;default:WH-JC" json:"warehouse"` // the only warehouse the key works in
+	ExpiresAt  *time.Time    `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt *time.Time    `gorm:"column:last_used_at" json:"last_used_at"`
+	LastUsedIP string        `gorm:"column:last_used_ip" json:"last_used_ip"`
+	RevokedAt  *time.Time    `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedBy  string        `gorm:"column:created_by" json:"created_by"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// APIKeyScope grants an API key one action on one resource ("*" wildcards
+// work as in Permission)
+type APIKeyScope struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	APIKeyID uint   `gorm:"column:api_key_id;uniqueIndex:idx_api_key_scope;not null" json:"-"`
+	Resource string `gorm:"uniqueIndex:idx_api_key_scope;not null" json:"resource"`
+	Action   string `gorm:"uniqueIndex:idx_api_key_scope;not null" json:"action"`
+}
+
+// APIKeyUsage logs one request made with an API key
+type APIKeyUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	APIKeyID  uint      `gorm:"column:api_key_id;index;not null" json:"api_key_id"`
+	Method    string    `gorm:"column:method" json:"method"`
+	Path      string    `gorm:"column:path" json:"path"`
+	Status    int       `gorm:"column:status" json:"status"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Warehouse is a site run from this deployment. Every resource record carries
+// the code of the warehouse it belongs to.
+type Warehouse struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"column:code;uniqueIndex;not null" json:"code"`
+	Name      string    `gorm:"column:name" json:"name"`
+	Address   string    `gorm:"column:address" json:"address"`
+	IsActive  bool      `gorm:"column:is_active;not null;default:true" json:"is_active"`
+	UpdatedBy string    `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserWarehouse assigns a user to a warehouse they may work in
+type UserWarehouse struct {
+	UserID        uint   `gorm:"column:user_id;primaryKey" json:"user_id"`
+	WarehouseCode string `gorm:"column:warehouse_code;primaryKey" json:"warehouse_code"`
+}
@@ -13,17 +13,69 @@ api.interceptors.request.use((config) => {
     if (token) {
         config.headers.Authorization = `Bearer ${token}`;
     }
+    // Resource data is scoped to the warehouse picked in the header bar
+    const warehouse = localStorage.getItem('warehouse');
+    if (warehouse) {
+        config.headers['X-Warehouse'] = warehouse;
+    }
     return config;
 });
 
-// Handle 401 errors (token expired)
+// Clear the stored session and go back to the login page
+function endSession() {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('user');
+    localStorage.removeItem('warehouse');
+    window.location.href = '/login';
+}
+
+// A single in-flight refresh shared by every request that hit a 401
+let refreshing: Promise<string> | null = null;
+
+function refreshAccessToken(): Promise<string> {
+    if (!refreshing) {
+        const refreshToken = localStorage.getItem('refresh_token');
+        refreshing = (refreshToken
+            ? axios.post(`${API_BASE}/auth/refresh`, { refresh_token: refreshToken }).then((res) => {
+                localStorage.setItem('token', res.data.token);
+                localStorage.setItem('refresh_token', res.data.refresh_token);
+                const saved = localStorage.getItem('user');
+                if (saved) {
+                    localStorage.setItem('user', JSON.stringify({ ...JSON.parse(saved), role: res.data.role, token: res.data.token }));
+                }
+                return res.data.token as string;
+            })
+            : Promise.reject(new Error('no refresh token'))
+        ).finally(() => {
+            refreshing = null;
+        });
+    }
+    return refreshing;
+}
+
+// Handle 401 errors: renew the access token once with the refresh token,
+// then retry; if that fails the session is over
 api.interceptors.response.use(
     (response) => response,
-    (error) => {
-        if (error.response?.status === 401) {
-            localStorage.removeItem('token');
-            localStorage.removeItem('user');
-            window.location.href = '/login';
+    async (error) => {
+        const original = error.config;
+        const isAuthCall = original?.url?.startsWith('/auth/');
+        if (error.response?.status === 401 && original && !original._retried && !isAuthCall) {
+            original._retried = true;
+            try {
+                const token = await refreshAccessToken();
+                original.headers.Authorization = `Bearer ${token}`;
+                return api(original);
+            } catch {
+                endSession();
+            }
+        } else if (error.response?.status === 401 && !isAuthCall) {
+            endSession();
+        } else if (error.response?.status === 403 && error.response.data?.must_change_password
+            && window.location.pathname !== '/settings') {
+            // Seeded accounts must change their password before anything else
+            window.location.href = '/settings';
         }
         return Promise.reject(error);
     }
@@ -33,6 +85,11 @@ api.interceptors.response.use(
 export const authApi = {
     login: (username: string, password: string) =>
         api.post('/auth/login', { username, password }),
+    loginTwoFactor: (mfaToken: string, code: string) =>
+        api.post('/auth/login/2fa', code.includes('-')
+            ? { mfa_token: mfaToken, recovery_code: code }
+            : { mfa_token: mfaToken, code }),
+    logout: (refreshToken: string) => api.post('/auth/logout', { refresh_token: refreshToken }),
     me: () => api.get('/auth/me'),
 };
 
@@ -51,7 +108,21 @@ export function createResourceApi(resource: string) {
 }
 
 // Pre-built resource APIs
-export const arrivalsApi = createResourceApi('arrivals');
+export const arrivalsApi = {
+    ...createResourceApi('arrivals'),
+    // Arrivals with receive/putaway progress and status computed on the server
+    status: (params?: Record<string, any>) => api.get('/arrivals/status', { params }),
+    statusSummary: (params?: Record<string, any>) => api.get('/arrivals/status/summary', { params }),
+    // Dock-to-stock lead times against the inbound SLAs; startDate/endDate (YYYY-MM-DD) are required
+    sla: (params: { startDate: string; endDate: string; [key: string]: any }) => api.get('/arrivals/sla', { params }),
+    slaStats: (params: { startDate: string; endDate: string; [key: string]: any }) => api.get('/arrivals/sla/stats', { params }),
+    // PO receipt reconciliation; export returns a CSV/XLSX blob for supplier claims
+    reconciliation: (params?: Record<string, any>) => api.get('/arrivals/reconciliation', { params }),
+    exportReconciliation: (params?: Record<string, any>) =>
+        api.get('/arrivals/reconciliation/export', { params, responseType: 'blob' }),
+    // Vehicles unloaded per arrival, matched on receipt_no
+    unloading: (params?: Record<string, any>) => api.get('/arrivals/unloading', { params }),
+};
 export const transactionsApi = createResourceApi('transactions');
 export const vasApi = createResourceApi('vas');
 export const dccApi = createResourceApi('dcc');
@@ -62,14 +133,45 @@ export const locationsApi = createResourceApi('locations');
 export const attendancesApi = createResourceApi('attendances');
 export const employeesApi = createResourceApi('employees');
 export const productivityApi = createResourceApi('project-productivities');
-export const unloadingsApi = createResourceApi('unloadings');
+// Unloadings: hand-entered daily figures; daily() derives them from the
+// per-vehicle unloading events
+export const unloadingsApi = {
+    ...createResourceApi('unloadings'),
+    daily: (params?: { startDate?: string; endDate?: string; brand?: string }) => api.get('/unloadings/daily', { params }),
+};
+export const unloadingEventsApi = createResourceApi('unloading-events');
 export const schedulesApi = createResourceApi('schedules');
 export const beritaAcaraApi = createResourceApi('berita-acara');
+export const docNumberFormatsApi = createResourceApi('doc-number-formats');
 export const stockOpnamesApi = createResourceApi('stock-opnames');
 export const additionalMpApi = createResourceApi('additional-mp');
 export const masterItemsApi = createResourceApi('master-items');
 export const inboundRejectionsApi = createResourceApi('inbound-rejections');
-export const inboundCasesApi = createResourceApi('inbound-cases');
+// Inbound cases: lifecycle, assignment, timeline, attachments and escalation
+export const inboundCasesApi = {
+    ...createResourceApi('inbound-cases'),
+    transition: (id: number, data: { status: string; resolution?: string; comment?: string }) =>
+        api.post(`/inbound-cases/${id}/transition`, data),
+    assign: (id: number, assignee: string, comment?: string) =>
+        api.post(`/inbound-cases/${id}/assign`, { assignee, comment }),
+    comments: (id: number) => api.get(`/inbound-cases/${id}/comments`),
+    addComment: (id: number, body: string) => api.post(`/inbound-cases/${id}/comments`, { body }),
+    attachments: (id: number) => api.get(`/inbound-cases/${id}/attachments`),
+    uploadAttachment: (id: number, file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.post(`/inbound-cases/${id}/attachments`, formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    attachmentUrl: (id: number, attachmentId: number, download?: boolean) =>
+        api.get(`/inbound-cases/${id}/attachments/${attachmentId}${download ? '?download=true' : ''}`),
+    removeAttachment: (id: number, attachmentId: number) => api.delete(`/inbound-cases/${id}/attachments/${attachmentId}`),
+    overdue: (params?: Record<string, any>) => api.get('/inbound-cases/overdue', { params }),
+    stats: (params?: Record<string, any>) => api.get('/inbound-cases/stats', { params }),
+    escalate: () => api.post('/inbound-cases/escalate'),
+};
+export const inboundCaseTypesApi = createResourceApi('inbound-case-types');
 export const returnTransactionsApi = createResourceApi('return-transactions');
 export const returnReceivesApi = createResourceApi('return-receives');
 export const rejectReturnsApi = createResourceApi('reject-returns');
@@ -77,6 +179,42 @@ export const orderPerBrandsApi = createResourceApi('order-per-brands');
 export const workflowsApi = createResourceApi('workflows');
 export const inventoryProjectsApi = createResourceApi('inventory-projects');
 export const heatmapOverridesApi = createResourceApi('heatmap-overrides');
+export const inboundSlasApi = createResourceApi('inbound-slas');
+export const docksApi = createResourceApi('docks');
+export const dockSlotsApi = createResourceApi('dock-slots');
+
+// Dock appointments: booking, check-in and the no-show/late report
+export const dockAppointmentsApi = {
+    list: (params?: Record<string, any>) => api.get('/dock-appointments', { params }),
+    get: (id: number) => api.get(`/dock-appointments/${id}`),
+    book: (data: Record<string, unknown>) => api.post('/dock-appointments', data),
+    reschedule: (id: number, data: Record<string, unknown>) => api.put(`/dock-appointments/${id}`, data),
+    cancel: (id: number) => api.post(`/dock-appointments/${id}/cancel`),
+    checkIn: (id: number, data?: { arrival_id?: number; check_in_time?: string }) =>
+        api.post(`/dock-appointments/${id}/check-in`, data || {}),
+    remove: (id: number) => api.delete(`/dock-appointments/${id}`),
+    availability: (date?: string) => api.get('/dock-appointments/availability', { params: { date } }),
+    report: (params?: { from?: string; to?: string; grace?: number }) => api.get('/dock-appointments/report', { params }),
+};
+
+// ASNs: supplier file import, attaching to an arrival and expected vs received
+export const asnsApi = {
+    ...createResourceApi('asns'),
+    importSupplier: (file: File, header: Record<string, string | number | undefined>) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        Object.entries(header).forEach(([key, value]) => {
+            if (value !== undefined && value !== '') formData.append(key, String(value));
+        });
+        return api.post('/asns/import/supplier', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    attach: (id: number, arrivalId: number) => api.post(`/asns/${id}/attach`, { arrival_id: arrivalId }),
+    receiving: (id: number) => api.get(`/asns/${id}/receiving`),
+    receivingByReceipt: (receiptNo: string) => api.get('/asns/receiving', { params: { receipt_no: receiptNo } }),
+};
+export const asnLinesApi = createResourceApi('asn-lines');
 
 // Unboxing API (custom endpoints for video upload)
 export const unboxingApi = {
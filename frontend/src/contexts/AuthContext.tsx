@@ -10,7 +10,9 @@ interface User {
 
 interface AuthContextType {
     user: User | null;
-    login: (username: string, password: string) => Promise<void>;
+    // Resolves to an MFA token when the account needs a 2FA code next
+    login: (username: string, password: string) => Promise<string | null>;
+    loginTwoFactor: (mfaToken: string, code: string) => Promise<void>;
     logout: () => void;
     isAuthenticated: boolean;
 }
@@ -23,27 +25,47 @@ export function AuthProvider({ children }: { children: ReactNode }) {
         return saved ? JSON.parse(saved) : null;
     });
 
-    const login = async (username: string, password: string) => {
-        const res = await authApi.login(username, password);
+    const startSession = (data: any) => {
         const userData: User = {
-            user_id: res.data.user_id,
-            username: res.data.username,
-            role: res.data.role,
-            token: res.data.token,
+            user_id: data.user_id,
+            username: data.username,
+            role: data.role,
+            token: data.token,
         };
         localStorage.setItem('token', userData.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
         localStorage.setItem('user', JSON.stringify(userData));
         setUser(userData);
     };
 
+    const login = async (username: string, password: string) => {
+        const res = await authApi.login(username, password);
+        if (res.data.mfa_required) {
+            return res.data.mfa_token as string;
+        }
+        startSession(res.data);
+        return null;
+    };
+
+    const loginTwoFactor = async (mfaToken: string, code: string) => {
+        const res = await authApi.loginTwoFactor(mfaToken, code);
+        startSession(res.data);
+    };
+
     const logout = () => {
+        const refreshToken = localStorage.getItem('refresh_token');
+        if (refreshToken) {
+            authApi.logout(refreshToken).catch(() => undefined);
+        }
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
         localStorage.removeItem('user');
+        localStorage.removeItem('warehouse');
         setUser(null);
     };
 
     return (
-        <AuthContext.Provider value={{ user, login, logout, isAuthenticated: !!user }}>
+        <AuthContext.Provider value={{ user, login, loginTwoFactor, logout, isAuthenticated: !!user }}>
             {children}
         </AuthContext.Provider>
     );
@@ -1,5 +1,5 @@
 import React, { useState, useEffect, useCallback } from 'react';
-import { Layout, Menu, Typography, Avatar, Dropdown, Space, Button } from 'antd';
+import { Layout, Menu, Typography, Avatar, Dropdown, Space, Button, Select } from 'antd';
 import {
     DashboardOutlined, InboxOutlined, SwapOutlined, ToolOutlined,
     CheckCircleOutlined, WarningOutlined, DatabaseOutlined, RollbackOutlined, AuditOutlined,
@@ -10,6 +10,7 @@ import {
     } from '@ant-design/icons';
 import { useNavigate, useLocation } from 'react-router-dom';
 import { useAuth, hasPageAccess } from '../contexts/AuthContext';
+import { authApi } from '../api/client';
 
 // Lazy imports for all pages
 import DashboardPage from '../pages/DashboardPage';
@@ -238,6 +239,28 @@ export default function AppLayout() {
         return () => clearInterval(interval);
     }, []);
 
+    // Warehouses the user may work in; the pick is sent as X-Warehouse on every request
+    const [warehouses, setWarehouses] = useState<string[]>([]);
+    const [warehouse, setWarehouse] = useState<string>(() => localStorage.getItem('warehouse') || '');
+    useEffect(() => {
+        authApi.me().then((res) => {
+            const list: string[] = res.data.warehouses || [];
+            setWarehouses(list);
+            if (list.length > 0 && !list.includes(localStorage.getItem('warehouse') || '')) {
+                const first = list.includes('WH-JC') ? 'WH-JC' : list[0];
+                localStorage.setItem('warehouse', first);
+                setWarehouse(first);
+            }
+        }).catch(() => undefined);
+    }, []);
+
+    const switchWarehouse = (code: string) => {
+        localStorage.setItem('warehouse', code);
+        setWarehouse(code);
+        // Every open page holds data of the old warehouse
+        window.location.reload();
+    };
+
     const role = user?.role || '';
 
     const filteredItems = NAV_ITEMS.filter((item) => {
@@ -392,6 +415,16 @@ export default function AppLayout() {
                             style={{ color: '#fff', fontSize: 16 }}
                         />
                         <Text style={{ color: 'rgba(255,255,255,0.5)', fontSize: 13 }}>{clock}</Text>
+                        {warehouses.length > 0 && (
+                            <Select
+                                size="small"
+                                value={warehouse || undefined}
+                                onChange={switchWarehouse}
+                                disabled={warehouses.length === 1}
+                                options={warehouses.map((w) => ({ label: w, value: w }))}
+                                style={{ minWidth: 120 }}
+                            />
+                        )}
                     </Space>
                     <Dropdown menu={{
                         items: [
@@ -4,7 +4,7 @@ import {
     PlusOutlined, ReloadOutlined, SearchOutlined, EditOutlined, DeleteOutlined,
     DownloadOutlined, UploadOutlined, ClearOutlined, MinusCircleOutlined,
 } from '@ant-design/icons';
-import { arrivalsApi, transactionsApi, employeesApi } from '../api/client';
+import { arrivalsApi, employeesApi } from '../api/client';
 import { downloadCsvTemplate, normalizeDateTime, normalizeDate } from '../utils/csvTemplate';
 import { useSearchParams } from 'react-router-dom';
 import dayjs from 'dayjs';
@@ -16,7 +16,6 @@ export default function ArrivalsPage() {
     const isSupervisor = user?.role === 'supervisor';
     const [searchParams] = useSearchParams();
     const [data, setData] = useState<any[]>([]);
-    const [transData, setTransData] = useState<any[]>([]);
     const [loading, setLoading] = useState(false);
     const [search, setSearch] = useState(searchParams.get('search') || '');
     const [modalOpen, setModalOpen] = useState(false);
@@ -26,19 +25,15 @@ export default function ArrivalsPage() {
     const [employees, setEmployees] = useState<any[]>([]);
     const [form] = Form.useForm();
 
-    // Fetch arrivals + transactions data
+    // Fetch arrivals with their status
     const fetchAll = useCallback(async (silent = false) => {
         if (!silent) setLoading(true);
         try {
-            const [aRes, tRes, eRes] = await Promise.all([
-                arrivalsApi.list(), 
-                transactionsApi.list(),
+            const [aRes, eRes] = await Promise.all([
+                arrivalsApi.status(),
                 employeesApi.list()
             ]);
-            const arrivals = aRes.data || [];
-            arrivals.sort((a: any, b: any) => b.id - a.id);
-            setData(arrivals);
-            setTransData(tRes.data || []);
+            setData(aRes.data || []);
             setEmployees(eRes.data || []);
         } catch {
             if (!silent) message.error('Gagal memuat data');
@@ -55,60 +50,15 @@ export default function ArrivalsPage() {
         return () => clearInterval(interval);
     }, [fetchAll]);
 
-    // Build lookup: receipt_no → { receiveQty, putawayQty, firstReceiveTime, lastPutawayTime } from transactions
-    const txLookup = useMemo(() => {
-        const map: Record<string, { receiveQty: number; putawayQty: number; firstReceiveTime: string | null; lastPutawayTime: string | null }> = {};
-        transData.forEach((tx: any) => {
-            const key = (tx.receipt_no || '').trim().toLowerCase();
-            if (!key) return;
-            if (!map[key]) map[key] = { receiveQty: 0, putawayQty: 0, firstReceiveTime: null, lastPutawayTime: null };
-            const qty = parseInt(tx.qty) || 0;
-            const type = (tx.operate_type || '').trim().toLowerCase();
-            const txTime = tx.time_transaction || '';
-            if (type === 'receive' || type === 'receiving') {
-                map[key].receiveQty += qty;
-                if (txTime && (!map[key].firstReceiveTime || txTime < map[key].firstReceiveTime!)) {
-                    map[key].firstReceiveTime = txTime;
-                }
-            } else if (type === 'putaway') {
-                map[key].putawayQty += qty;
-                if (txTime && (!map[key].lastPutawayTime || txTime > map[key].lastPutawayTime!)) {
-                    map[key].lastPutawayTime = txTime;
-                }
-            }
-        });
-        return map;
-    }, [transData]);
-
-    // Compute enriched data with Receive Qty, Putaway Qty, Pending Qty, First Receive, Last Putaway, Status
+    // Receive Qty, Putaway Qty, Pending Qty, First Receive, Last Putaway and Status come from the server
     const enrichedData = useMemo(() => {
-        return data.map((row: any) => {
-            const key = (row.receipt_no || '').trim().toLowerCase();
-            const tx = txLookup[key] || { receiveQty: 0, putawayQty: 0, firstReceiveTime: null, lastPutawayTime: null };
-            const poQty = parseInt(row.po_qty) || 0;
-            const receiveQty = tx.receiveQty;
-            const putawayQty = tx.putawayQty;
-            const pendingQty = Math.abs(receiveQty - poQty);
-
-            let status = 'Pending Receive';
-            if (receiveQty === poQty && putawayQty === poQty) {
-                status = 'Completed';
-            } else if (receiveQty === poQty && putawayQty !== poQty) {
-                status = 'Pending Putaway';
-            }
-
-            return {
-                ...row,
-                item_type: row.item_type || 'Barang Jual',
-                receive_qty: receiveQty,
-                putaway_qty: putawayQty,
-                pending_qty: pendingQty,
-                first_receive: tx.firstReceiveTime || '-',
-                last_putaway: tx.lastPutawayTime || '-',
-                status,
-            };
-        });
-    }, [data, txLookup]);
+        return data.map((row: any) => ({
+            ...row,
+            item_type: row.item_type || 'Barang Jual',
+            first_receive: row.first_receive || '-',
+            last_putaway: row.last_putaway || '-',
+        }));
+    }, [data]);
 
     // Filter by date range and search (multi-keyword, separated by newline)
     const searchTerms = useMemo(() =>
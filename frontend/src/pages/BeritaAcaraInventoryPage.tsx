@@ -66,25 +66,6 @@ interface SOSummary {
     unmatched: number;
 }
 
-function generateDocNumber(existingDocs: any[], docType: string): string {
-    const now = dayjs();
-    const prefix = `INV-${now.format('MMYY')}`;
-    const year = now.format('YYYY');
-    let maxSeq = 0;
-    existingDocs
-        .filter(d => d.doc_type === docType)
-        .forEach(d => {
-            const dn = d.doc_number || '';
-            const match = dn.match(/INV-\d{4}-(\d{4})/);
-            if (match) {
-                const seq = parseInt(match[1]) || 0;
-                if (seq > maxSeq) maxSeq = seq;
-            }
-        });
-    const nextSeq = (maxSeq + 1).toString().padStart(4, '0');
-    return `${prefix}-${nextSeq}/WH-JC/${year}`;
-}
-
 function calcSummary(items: SkuItem[]): SOSummary {
     let totalSysQty = 0, totalPhyQty = 0, totalVariance = 0, matched = 0, unmatched = 0;
     const skuSet = new Set<string>();
@@ -234,7 +215,6 @@ export default function BeritaAcaraInventoryPage() {
         const finalItems = isDisposal ? disposalItems : (isTransferDamage ? transferItems : (isStockOpname ? soItems : items));
         if (finalItems.length === 0) { message.warning(isStockOpname ? 'Tidak ada data stock opname di tanggal ini!' : 'Tambahkan minimal 1 SKU!'); return; }
 
-        const docNumber = generateDocNumber(docs, vals.doc_type);
         const summary = isStockOpname ? calcSummary(finalItems as SkuItem[]) : null;
 
         // For Transfer Damage, store photos + security name in notes as JSON
@@ -248,7 +228,6 @@ export default function BeritaAcaraInventoryPage() {
 
         const payload = {
             doc_type: vals.doc_type,
-            doc_number: docNumber,
             date: dayjs(vals.date).format('YYYY-MM-DD'),
             checker: vals.checker,
             kepada: vals.kepada ? `${vals.kepada}:::${vals.kepada_posisi || ''}` : '',
@@ -258,10 +237,10 @@ export default function BeritaAcaraInventoryPage() {
         };
 
         try {
-            await beritaAcaraApi.create(payload);
+            const res = await beritaAcaraApi.create(payload);
             message.success('Berita Acara tersimpan!');
             setPreviewDoc({
-                ...payload, items: finalItems, summary,
+                ...payload, doc_number: res.data.doc_number, items: finalItems, summary,
                 kepadaName: vals.kepada || '', kepadaPosisi: vals.kepada_posisi || '',
                 securityName: vals.security_name || '',
                 photoFisik: isDisposal ? disposalPhotoFisik : photoFisik,
@@ -40,28 +40,6 @@ const WAREHOUSE_OPTIONS = [
     { label: 'WH-JC-02', value: 'WH-JC-02' },
 ];
 
-// Generate doc number: MMYY-XXXX/{warehouse}/YYYY (per doc_type)
-function generateDocNumber(existingDocs: any[], docType: string, warehouse: string): string {
-    const now = dayjs();
-    const prefix = now.format('MMYY');
-    const year = now.format('YYYY');
-
-    // Find the highest sequence number for this month + same doc type + same warehouse
-    let maxSeq = 0;
-    existingDocs
-        .filter(d => d.doc_type === docType && (d.doc_number || '').includes(`/${warehouse}/`))
-        .forEach(d => {
-            const dn = d.doc_number || '';
-            if (dn.startsWith(prefix + '-')) {
-                const seqStr = dn.split('-')[1]?.split('/')[0];
-                const seq = parseInt(seqStr) || 0;
-                if (seq > maxSeq) maxSeq = seq;
-            }
-        });
-    const nextSeq = (maxSeq + 1).toString().padStart(4, '0');
-    return `${prefix}-${nextSeq}/${warehouse}/${year}`;
-}
-
 // Helper: determine warehouse from doc_number for legacy records
 function getWarehouseFromDoc(doc: any): string {
     if (doc.warehouse) return doc.warehouse;
@@ -144,10 +122,8 @@ export default function BeritaAcaraPage() {
         if (items.length === 0) { message.warning('Tambahkan minimal 1 SKU!'); return; }
 
         const wh = vals.warehouse || 'WH-JC';
-        const docNumber = generateDocNumber(docs, vals.doc_type, wh);
         const payload = {
             doc_type: vals.doc_type,
-            doc_number: docNumber,
             date: dayjs(vals.date).format('YYYY-MM-DD'),
             checker: vals.checker,
             kepada: vals.kepada,
@@ -159,11 +135,12 @@ export default function BeritaAcaraPage() {
         };
 
         try {
-            await beritaAcaraApi.create(payload);
+            // The server assigns the document number
+            const res = await beritaAcaraApi.create(payload);
             message.success('Berita Acara tersimpan!');
 
             // Show print preview
-            setPreviewDoc({ ...payload, items });
+            setPreviewDoc({ ...payload, doc_number: res.data.doc_number, items });
             setPreviewOpen(true);
 
             // Reset form
@@ -291,7 +268,7 @@ export default function BeritaAcaraPage() {
                             <Form form={form} layout="vertical" initialValues={{
                                 dari: 'PT. Global Jet Ecommerce',
                                 date: dayjs(),
-                                warehouse: 'WH-JC',
+                                warehouse: localStorage.getItem('warehouse') || 'WH-JC',
                             }}>
                                 <div style={{ display: 'grid', gridTemplateColumns: 'repeat(auto-fit, minmax(240px, 1fr))', gap: 16 }}>
                                     <Form.Item name="doc_type" label="Jenis Berita Acara" rules={[{ required: true, message: 'Pilih jenis' }]}>
@@ -309,8 +286,9 @@ export default function BeritaAcaraPage() {
                                     <Form.Item name="dari" label="Dari">
                                         <Input disabled />
                                     </Form.Item>
+                                    {/* Documents are saved into the active warehouse picked in the header */}
                                     <Form.Item name="warehouse" label="Warehouse" rules={[{ required: true }]}>
-                                        <Select options={WAREHOUSE_OPTIONS} />
+                                        <Select options={WAREHOUSE_OPTIONS} disabled />
                                     </Form.Item>
                                     {isWHJC02 && (
                                         <Form.Item name="pic_name" label="Nama PIC" rules={[{ required: true, message: 'Isi nama PIC' }]}>
@@ -73,7 +73,7 @@ export default function DashboardPage() {
         try {
             if (group === 'inbound') {
                 const [a, t, v, ul, ic, rej, ba] = await Promise.all([
-                    arrivalsApi.list(), transactionsApi.list(), vasApi.list(), unloadingsApi.list(), inboundCasesApi.list(),
+                    arrivalsApi.list(), transactionsApi.list(), vasApi.list(), unloadingsApi.daily(), inboundCasesApi.list(),
                     inboundRejectionsApi.list(), beritaAcaraApi.list(),
                 ]);
                 setArrivals(a.data || []);
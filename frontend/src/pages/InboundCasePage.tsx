@@ -1,4 +1,4 @@
-import { useState, useRef, useCallback, useEffect } from 'react';
+import { useState, useRef } from 'react';
 import { LOGO_BASE64 } from '../assets/logoBase64';
 import DataPage from '../components/DataPage';
 import { inboundCasesApi, beritaAcaraApi } from '../api/client';
@@ -42,6 +42,9 @@ const columns = [
     { title: 'Operator', dataIndex: 'operator', key: 'operator', width: 120 },
     { title: 'Qty', dataIndex: 'qty', key: 'qty', width: 70 },
     { title: 'Keterangan', dataIndex: 'keterangan', key: 'keterangan', width: 200, ellipsis: true },
+    { title: 'Status', dataIndex: 'status', key: 'status', width: 130 },
+    { title: 'Assignee', dataIndex: 'assignee', key: 'assignee', width: 120 },
+    { title: 'Due Date', dataIndex: 'due_date', key: 'due_date', width: 150 },
 ];
 
 const csvHeaders = ['date', 'receipt_no', 'brand', 'sku', 'case', 'operator', 'qty', 'keterangan'];
@@ -72,6 +75,12 @@ const formFields = (
         <Form.Item name="keterangan" label="Keterangan">
             <Input.TextArea rows={2} />
         </Form.Item>
+        <Form.Item name="assignee" label="Assignee">
+            <Input />
+        </Form.Item>
+        <Form.Item name="due_date" label="Due Date">
+            <Input placeholder="YYYY-MM-DD HH:mm:ss (kosongkan untuk SLA jenis case)" />
+        </Form.Item>
     </>
 );
 
@@ -87,29 +96,6 @@ interface BaItem {
     _existing_case_id?: number;
 }
 
-// Generate doc number for BA Case Inbound: MMYY-XXXX/WH-JC/YYYY
-function generateDocNumber(existingDocs: any[]): string {
-    const now = dayjs();
-    const prefix = now.format('MMYY');
-    const year = now.format('YYYY');
-    const docType = 'Berita Acara Case Inbound';
-    const warehouse = 'WH-JC';
-
-    let maxSeq = 0;
-    existingDocs
-        .filter(d => d.doc_type === docType && (d.doc_number || '').includes(`/${warehouse}/`))
-        .forEach(d => {
-            const dn = d.doc_number || '';
-            if (dn.startsWith(prefix + '-')) {
-                const seqStr = dn.split('-')[1]?.split('/')[0];
-                const seq = parseInt(seqStr) || 0;
-                if (seq > maxSeq) maxSeq = seq;
-            }
-        });
-    const nextSeq = (maxSeq + 1).toString().padStart(4, '0');
-    return `${prefix}-${nextSeq}/${warehouse}/${year}`;
-}
-
 // ---- Print styles ----
 const printTh: React.CSSProperties = {
     border: '1px solid #333', padding: '6px 10px', textAlign: 'left',
@@ -130,21 +116,10 @@ export default function InboundCasePage() {
     const [baItems, setBaItems] = useState<BaItem[]>([]);
     const [skuInput, setSkuInput] = useState('');
     const skuRef = useRef<any>(null);
-    const [allBaDocs, setAllBaDocs] = useState<any[]>([]);
     const [previewDoc, setPreviewDoc] = useState<any>(null);
     const [previewOpen, setPreviewOpen] = useState(false);
     const [refreshKey, setRefreshKey] = useState(0);
 
-    // Fetch all berita acara docs for doc number generation
-    const fetchBaDocs = useCallback(async () => {
-        try {
-            const res = await beritaAcaraApi.list();
-            setAllBaDocs(res.data || []);
-        } catch { /* ignore */ }
-    }, []);
-
-    useEffect(() => { fetchBaDocs(); }, [fetchBaDocs]);
-
     // Open BA modal
     const handleOpenBaModal = (selectedRows?: any[]) => {
         baForm.resetFields();
@@ -174,7 +149,6 @@ export default function InboundCasePage() {
         }
 
         setBaModalOpen(true);
-        fetchBaDocs();
     };
 
     // Add SKU item
@@ -240,12 +214,10 @@ export default function InboundCasePage() {
             }
         }
 
-        const docNumber = generateDocNumber(allBaDocs);
         const dateStr = dayjs(vals.date).format('YYYY-MM-DD');
 
         const payload = {
             doc_type: 'Berita Acara Case Inbound',
-            doc_number: docNumber,
             date: dateStr,
             checker: vals.pembuat || '',
             kepada: '-',
@@ -257,8 +229,9 @@ export default function InboundCasePage() {
         };
 
         try {
-            // 1. Save Berita Acara
-            await beritaAcaraApi.create(payload);
+            // 1. Save Berita Acara; the server assigns the document number
+            const res = await beritaAcaraApi.create(payload);
+            const docNumber = res.data.doc_number;
 
             // 2. Auto-insert or update each item to Case Inbound
             for (const item of baItems) {
@@ -286,14 +259,13 @@ export default function InboundCasePage() {
             message.success('Berita Acara tersimpan & data masuk ke Case Inbound!');
 
             // Show print preview
-            setPreviewDoc({ ...payload, items: baItems, pembuat: vals.pembuat || '', receipt_no: vals.receipt_no || '-', brand: vals.brand || '-' });
+            setPreviewDoc({ ...payload, doc_number: docNumber, items: baItems, pembuat: vals.pembuat || '', receipt_no: vals.receipt_no || '-', brand: vals.brand || '-' });
             setPreviewOpen(true);
 
             // Reset form
             setBaModalOpen(false);
             setBaItems([]);
             baForm.resetFields();
-            fetchBaDocs();
 
             // Refresh case inbound data
             setRefreshKey(prev => prev + 1);
@@ -136,7 +136,7 @@ export default function InboundRejectionPage() {
 
     // CSV Export
     const handleExport = () => {
-        const headers = ['date', 'brand', 'sku', 'serial_number', 'catatan', 'qty', 'source_doc_no'];
+        const headers = ['date', 'brand', 'receipt_no', 'sku', 'serial_number', 'catatan', 'qty', 'source_doc_no'];
         const csv = '\uFEFF' + headers.join(',') + '\n' +
             filteredRows.map(r => headers.map(h => `"${r[h] ?? ''}"`).join(',')).join('\n');
         const blob = new Blob([csv], { type: 'text/csv;charset=utf-8;' });
@@ -163,6 +163,7 @@ export default function InboundRejectionPage() {
                 return {
                     date: get(cols, 'date') || dayjs().format('YYYY-MM-DD'),
                     brand: get(cols, 'brand'),
+                    receipt_no: get(cols, 'receipt_no'),
                     sku: get(cols, 'sku'),
                     serial_number: get(cols, 'serial_number'),
                     catatan: get(cols, 'catatan'),
@@ -184,6 +185,7 @@ export default function InboundRejectionPage() {
     const columns = [
         { title: 'Tanggal', dataIndex: 'date', key: 'date', width: 110, sorter: (a: any, b: any) => (a.date || '').localeCompare(b.date || '') },
         { title: 'Brand', dataIndex: 'brand', key: 'brand', width: 110 },
+        { title: 'Receipt No', dataIndex: 'receipt_no', key: 'receipt_no', width: 130 },
         { title: 'SKU', dataIndex: 'sku', key: 'sku', width: 160 },
         { title: 'Serial Number', dataIndex: 'serial_number', key: 'serial_number', width: 160 },
         { title: 'Catatan', dataIndex: 'catatan', key: 'catatan', width: 200, ellipsis: true },
@@ -260,6 +262,9 @@ export default function InboundRejectionPage() {
                     <Form.Item name="brand" label="Brand">
                         <Input />
                     </Form.Item>
+                    <Form.Item name="receipt_no" label="Receipt No (opsional)">
+                        <Input />
+                    </Form.Item>
                     <Form.Item name="sku" label="SKU">
                         <Input />
                     </Form.Item>
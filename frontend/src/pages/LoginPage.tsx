@@ -1,22 +1,27 @@
 import { useState } from 'react';
 import { Form, Input, Button, Card, Typography, Alert, Space } from 'antd';
-import { UserOutlined, LockOutlined } from '@ant-design/icons';
+import { UserOutlined, LockOutlined, SafetyOutlined } from '@ant-design/icons';
 import { useAuth } from '../contexts/AuthContext';
 import { useNavigate } from 'react-router-dom';
 
 const { Title, Text } = Typography;
 
 export default function LoginPage() {
-    const { login } = useAuth();
+    const { login, loginTwoFactor } = useAuth();
     const navigate = useNavigate();
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState('');
+    const [mfaToken, setMfaToken] = useState<string | null>(null);
 
     const onFinish = async (values: { username: string; password: string }) => {
         setLoading(true);
         setError('');
         try {
-            await login(values.username.trim().toLowerCase(), values.password);
+            const pending = await login(values.username.trim().toLowerCase(), values.password);
+            if (pending) {
+                setMfaToken(pending);
+                return;
+            }
             navigate('/');
         } catch (err: any) {
             setError(err.response?.data?.error || 'Login gagal. Periksa username dan password.');
@@ -25,6 +30,22 @@ export default function LoginPage() {
         }
     };
 
+    const onFinishTwoFactor = async (values: { code: string }) => {
+        setLoading(true);
+        setError('');
+        try {
+            await loginTwoFactor(mfaToken!, values.code.trim());
+            navigate('/');
+        } catch (err: any) {
+            if (err.response?.status === 401 && err.response?.data?.error !== 'Kode 2FA salah') {
+                setMfaToken(null);
+            }
+            setError(err.response?.data?.error || 'Kode 2FA salah');
+        } finally {
+            setLoading(false);
+        }
+    };
+
     return (
         <div style={{
             height: '100vh',
@@ -55,20 +76,35 @@ export default function LoginPage() {
 
                     {error && <Alert message={error} type="error" showIcon />}
 
-                    <Form onFinish={onFinish} layout="vertical" size="large">
-                        <Form.Item name="username" rules={[{ required: true, message: 'Username wajib diisi' }]}>
-                            <Input prefix={<UserOutlined />} placeholder="Username" autoFocus />
-                        </Form.Item>
-                        <Form.Item name="password" rules={[{ required: true, message: 'Password wajib diisi' }]}>
-                            <Input.Password prefix={<LockOutlined />} placeholder="Password" />
-                        </Form.Item>
-                        <Form.Item>
-                            <Button type="primary" htmlType="submit" block loading={loading}
-                                style={{ height: 44, borderRadius: 8, fontWeight: 600 }}>
-                                Login
-                            </Button>
-                        </Form.Item>
-                    </Form>
+                    {mfaToken ? (
+                        <Form onFinish={onFinishTwoFactor} layout="vertical" size="large">
+                            <Form.Item name="code" rules={[{ required: true, message: 'Kode 2FA wajib diisi' }]}
+                                extra={<Text style={{ color: 'rgba(255,255,255,0.5)', fontSize: 12 }}>Kode 6 digit dari aplikasi authenticator, atau recovery code</Text>}>
+                                <Input prefix={<SafetyOutlined />} placeholder="Kode 2FA" autoFocus autoComplete="one-time-code" />
+                            </Form.Item>
+                            <Form.Item>
+                                <Button type="primary" htmlType="submit" block loading={loading}
+                                    style={{ height: 44, borderRadius: 8, fontWeight: 600 }}>
+                                    Verifikasi
+                                </Button>
+                            </Form.Item>
+                        </Form>
+                    ) : (
+                        <Form onFinish={onFinish} layout="vertical" size="large">
+                            <Form.Item name="username" rules={[{ required: true, message: 'Username wajib diisi' }]}>
+                                <Input prefix={<UserOutlined />} placeholder="Username" autoFocus />
+                            </Form.Item>
+                            <Form.Item name="password" rules={[{ required: true, message: 'Password wajib diisi' }]}>
+                                <Input.Password prefix={<LockOutlined />} placeholder="Password" />
+                            </Form.Item>
+                            <Form.Item>
+                                <Button type="primary" htmlType="submit" block loading={loading}
+                                    style={{ height: 44, borderRadius: 8, fontWeight: 600 }}>
+                                    Login
+                                </Button>
+                            </Form.Item>
+                        </Form>
+                    )}
 
                 </Space>
             </Card>
@@ -110,7 +110,7 @@ export default function MonthlyReportPage() {
         try {
             const [a, t, v, ul, ic, rej, rr, rjr, opb, rtx, d, s, dm, q, loc, ba, att, emp, sch, addMp] = await Promise.all([
                 arrivalsApi.list(), transactionsApi.list(), vasApi.list(),
-                unloadingsApi.list(), inboundCasesApi.list(), inboundRejectionsApi.list(),
+                unloadingsApi.daily(), inboundCasesApi.list(), inboundRejectionsApi.list(),
                 returnReceivesApi.list(), rejectReturnsApi.list(),
                 orderPerBrandsApi.list(), returnTransactionsApi.list(),
                 dccApi.list(), sohApi.list(), damagesApi.list(),
@@ -30,16 +30,6 @@ const WAREHOUSE_OPTIONS = [
 
 interface SkuItem { do_number?: string; sku: string; description?: string; serial_number: string; qty: number; qty_po: number; qty_actual: number; note: string; }
 
-function generateDocNumber(existingDocs: any[], docType: string, warehouse: string): string {
-    const now = dayjs(); const prefix = now.format('MMYY'); const year = now.format('YYYY');
-    let maxSeq = 0;
-    existingDocs.filter(d => d.doc_type === docType && (d.doc_number || '').includes(`/${warehouse}/`)).forEach(d => {
-        const dn = d.doc_number || '';
-        if (dn.startsWith(prefix + '-')) { const seq = parseInt(dn.split('-')[1]?.split('/')[0]) || 0; if (seq > maxSeq) maxSeq = seq; }
-    });
-    return `${prefix}-${(maxSeq + 1).toString().padStart(4, '0')}/${warehouse}/${year}`;
-}
-
 function getWarehouseFromDoc(doc: any): string {
     if (doc.warehouse) return doc.warehouse;
     if ((doc.doc_number || '').includes('/WH-JC-02/')) return 'WH-JC-02';
@@ -103,17 +93,16 @@ export default function PublicBeritaAcaraPage() {
         try { vals = await form.validateFields(); } catch { message.error('Lengkapi semua field yang wajib!'); return; }
         if (items.length === 0) { message.warning('Tambahkan minimal 1 SKU!'); return; }
         const wh = vals.warehouse || 'WH-JC';
-        const docNumber = generateDocNumber(docs, vals.doc_type, wh);
         const payload = {
-            doc_type: vals.doc_type, doc_number: docNumber, date: dayjs(vals.date).format('YYYY-MM-DD'),
+            doc_type: vals.doc_type, date: dayjs(vals.date).format('YYYY-MM-DD'),
             checker: vals.checker, kepada: vals.kepada, dari: 'PT. Global Jet Ecommerce',
             items: JSON.stringify(items), notes: vals.notes || '',
             warehouse: wh, pic_name: (wh === 'WH-JC-02' || wh === 'HUB-BKI') ? (vals.pic_name || '') : '',
         };
         try {
-            await publicApi.beritaAcaraCreate(payload);
+            const res = await publicApi.beritaAcaraCreate(payload);
             message.success('Berita Acara tersimpan!');
-            setPreviewDoc({ ...payload, items }); setPreviewOpen(true);
+            setPreviewDoc({ ...payload, doc_number: res.data.doc_number, items }); setPreviewOpen(true);
             form.resetFields(); form.setFieldsValue({ dari: 'PT. Global Jet Ecommerce', date: dayjs(), warehouse: 'WH-JC' });
             setItems([]); fetchData();
         } catch (err: any) { message.error(err?.response?.data?.error || 'Gagal menyimpan'); }
@@ -27,7 +27,7 @@ interface UserRecord {
 }
 
 export default function SettingsPage() {
-    const { user } = useAuth();
+    const { user, logout } = useAuth();
     const role = user?.role || '';
     const isSuper = role === 'supervisor' || role === 'leader';
 
@@ -47,8 +47,10 @@ export default function SettingsPage() {
                 current_password: values.current_password,
                 new_password: values.new_password,
             });
-            message.success('Password berhasil diubah');
+            // Changing the password ends every session, so log in again
+            message.success('Password berhasil diubah, silakan login kembali');
             pwForm.resetFields();
+            logout();
         } catch (err: any) {
             message.error(err.response?.data?.error || 'Gagal mengubah password');
         } finally {